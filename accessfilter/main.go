@@ -0,0 +1,135 @@
+// Команда accessfilter пропускает текст через права доступа пользователя
+// и предназначена для использования в конвейерах:
+//
+//	accessfilter -user ivan < in.txt > out.txt
+//
+// Текст читается из stdin и пишется в stdout, статистика в формате JSON
+// выводится в stderr. Коды завершения: 0 - успех, 1 - ошибка, 2 - текст
+// отклонён или (с флагом -strict) содержал запрещённые символы.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"laba3/database"
+	"laba3/filter"
+	"log"
+	"os"
+)
+
+// Пароль можно передать через переменную окружения, чтобы он не попадал
+// в список процессов.
+const passwordEnv = "ACCESSFILTER_PASSWORD"
+
+const (
+	exitError   = 1
+	exitDropped = 2
+)
+
+type summary struct {
+	User      string         `json:"user"`
+	Mode      filter.Mode    `json:"mode"`
+	Kept      int            `json:"kept"`
+	Dropped   int            `json:"dropped"`
+	Rejected  bool           `json:"rejected"`
+	Forbidden map[string]int `json:"forbidden"`
+}
+
+func main() {
+	dbPath := flag.String("db", "data.db", "путь к базе данных")
+	userName := flag.String("user", "", "имя пользователя, права которого применяются")
+	password := flag.String("password", "", "пароль пользователя (или переменная окружения "+passwordEnv+")")
+	modeName := flag.String("mode", string(filter.ModeDelete), "режим обработки запрещённых символов: delete, mask, reject")
+	placeholder := flag.String("placeholder", filter.DefaultPlaceholder, "заполнитель для режима mask")
+	strict := flag.Bool("strict", false, "завершаться с кодом 2, если в тексте были запрещённые символы")
+	verbose := flag.Bool("v", false, "выводить журнал работы с базой данных в stderr")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	code, err := run(*dbPath, *userName, *password, *modeName, *placeholder, *strict)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+func run(dbPath, userName, password, modeName, placeholder string, strict bool) (int, error) {
+	if userName == "" {
+		flag.Usage()
+		return exitError, fmt.Errorf("необходимо указать -user")
+	}
+
+	mode, err := filter.ParseMode(modeName)
+	if err != nil {
+		return exitError, err
+	}
+	if mode == filter.ModeHighlight {
+		return exitError, fmt.Errorf("режим highlight доступен только в приложении пользователя")
+	}
+
+	// Фильтр только читает права: база не создаётся и не обновляется
+	db, err := database.OpenReadOnly(dbPath)
+	if err != nil {
+		return exitError, err
+	}
+	defer db.Close()
+
+	userID, err := database.FindUser(db, userName)
+	if err == sql.ErrNoRows {
+		return exitError, fmt.Errorf("пользователь '%s' не зарегистрирован", userName)
+	}
+	if err != nil {
+		return exitError, err
+	}
+
+	if password == "" {
+		password = os.Getenv(passwordEnv)
+	}
+	if err := database.CheckPassword(db, userID, password); err != nil {
+		return exitError, err
+	}
+
+	textFilter, err := filter.Load(db, userID)
+	if err != nil {
+		return exitError, fmt.Errorf("ошибка загрузки прав доступа: %v", err)
+	}
+
+	stats, err := textFilter.Copy(os.Stdout, os.Stdin, mode, placeholder)
+
+	var rejected *filter.RejectedError
+	isRejected := errors.As(err, &rejected)
+	if err != nil && !isRejected {
+		return exitError, err
+	}
+
+	if err := writeSummary(summary{
+		User:      userName,
+		Mode:      mode,
+		Kept:      stats.Kept,
+		Dropped:   stats.Dropped,
+		Rejected:  isRejected,
+		Forbidden: stats.Forbidden,
+	}); err != nil {
+		return exitError, err
+	}
+
+	if isRejected || (strict && stats.Dropped > 0) {
+		return exitDropped, nil
+	}
+	return 0, nil
+}
+
+func writeSummary(s summary) error {
+	// encoding/json сортирует ключи карты, поэтому запрещённые символы
+	// выводятся в стабильном порядке
+	encoder := json.NewEncoder(os.Stderr)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(s)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// grantDurations - сроки, на которые можно выдать право по запросу.
+var grantDurations = []struct {
+	title string
+	days  int
+}{
+	{"Бессрочно", 0},
+	{"1 день", 1},
+	{"7 дней", 7},
+	{"30 дней", 30},
+	{"90 дней", 90},
+}
+
+func (a *AdminApp) createAccessRequestsTab() fyne.CanvasObject {
+	requests, err := database.GetAccessRequests(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки запросов доступа: %v", err)
+	}
+	selected := -1
+
+	details := widget.NewLabel("Выберите запрос, чтобы увидеть обоснование")
+	details.Wrapping = fyne.TextWrapWord
+
+	requestsList := widget.NewList(
+		func() int {
+			return len(requests)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			request := requests[id]
+			text := fmt.Sprintf("№%d  %s: '%s' - %s, %s", request.ID, request.UserName, displayLetter(request.Letter),
+				request.RequestedAt.Format(sessionTimeLayout), requestStatusNames[request.Status])
+			if request.Status != database.RequestPending {
+				text += fmt.Sprintf(" (%s %s)", request.DecidedBy, request.DecidedAt.Format(sessionTimeLayout))
+			}
+			label := item.(*widget.Label)
+			label.SetText(text)
+			if request.Status == database.RequestPending {
+				label.Importance = widget.MediumImportance
+			} else {
+				label.Importance = widget.LowImportance
+			}
+			label.Refresh()
+		},
+	)
+	requestsList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		request := requests[id]
+		text := "Обоснование: " + request.Justification
+		switch {
+		case request.Status == database.RequestApproved && request.ExpiresAt.IsZero():
+			text += "\nПраво выдано бессрочно"
+		case request.Status == database.RequestApproved:
+			text += "\nПраво выдано до " + request.ExpiresAt.Format(sessionTimeLayout)
+		case request.Comment != "":
+			text += "\nКомментарий: " + request.Comment
+		}
+		details.SetText(text)
+	}
+
+	selectedRequest := func() (database.AccessRequest, bool) {
+		if selected < 0 || selected >= len(requests) || requests[selected].Status != database.RequestPending {
+			dialog.ShowInformation("Внимание", "Выберите ожидающий запрос", a.window)
+			return database.AccessRequest{}, false
+		}
+		return requests[selected], true
+	}
+
+	approveBtn := widget.NewButton("Одобрить...", func() {
+		if request, ok := selectedRequest(); ok {
+			a.showApproveAccessDialog(request)
+		}
+	})
+	approveBtn.Importance = widget.HighImportance
+
+	rejectBtn := widget.NewButton("Отклонить...", func() {
+		if request, ok := selectedRequest(); ok {
+			a.showRejectAccessDialog(request)
+		}
+	})
+
+	return container.NewBorder(
+		container.NewVBox(
+			widget.NewLabelWithStyle("Запросы пользователей на доступ к символам", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			container.NewHBox(approveBtn, rejectBtn),
+		),
+		details,
+		nil, nil,
+		requestsList,
+	)
+}
+
+func (a *AdminApp) showApproveAccessDialog(request database.AccessRequest) {
+	options := make([]string, len(grantDurations))
+	for i, duration := range grantDurations {
+		options[i] = duration.title
+	}
+	durationSelect := widget.NewSelect(options, nil)
+	durationSelect.SetSelectedIndex(0)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Пользователь", widget.NewLabel(request.UserName)),
+		widget.NewFormItem("Символ", widget.NewLabel(displayLetter(request.Letter))),
+		widget.NewFormItem("Обоснование", widget.NewLabel(request.Justification)),
+		widget.NewFormItem("Срок", durationSelect),
+	}
+	dialog.ShowForm("Одобрение запроса", "Одобрить", "Отмена", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		var expires time.Time
+		title := fmt.Sprintf("Одобрен запрос доступа №%d: %s '%s'", request.ID, request.UserName, displayLetter(request.Letter))
+		if days := grantDurations[durationSelect.SelectedIndex()].days; days > 0 {
+			expires = time.Now().AddDate(0, 0, days)
+			title += " до " + expires.Format(sessionTimeLayout)
+		}
+		err := a.execute(title, func(tx *sql.Tx) error {
+			return database.ApproveAccessRequest(tx, request.ID, a.admin, expires)
+		})
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		log.Printf("%s", title)
+		a.refreshAllTabs()
+	}, a.window)
+}
+
+func (a *AdminApp) showRejectAccessDialog(request database.AccessRequest) {
+	commentEntry := widget.NewEntry()
+	commentEntry.SetPlaceHolder("Причина отказа (необязательно)")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Запрос", widget.NewLabel(fmt.Sprintf("%s: '%s'", request.UserName, displayLetter(request.Letter)))),
+		widget.NewFormItem("Комментарий", commentEntry),
+	}
+	dialog.ShowForm("Отклонение запроса", "Отклонить", "Отмена", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if err := database.RejectAccessRequest(a.db, request.ID, a.admin, commentEntry.Text); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.tabs.accessRequests.Content = a.createAccessRequestsTab()
+		a.mainTabs.Refresh()
+	}, a.window)
+}
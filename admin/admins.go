@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"laba3/database"
+	"log"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// displayLogin показывает экран входа администратора. Если в базе ещё нет
+// администраторов, вместо входа заводится первая учётная запись.
+func (a *AdminApp) displayLogin() {
+	count, err := database.CountAdmins(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки администраторов: %v", err)
+	}
+	first := count == 0
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Имя администратора")
+	passwordEntry := widget.NewPasswordEntry()
+	passwordEntry.SetPlaceHolder("Пароль")
+	repeatEntry := widget.NewPasswordEntry()
+	repeatEntry.SetPlaceHolder("Повторите пароль")
+
+	login := func() {
+		name := strings.TrimSpace(nameEntry.Text)
+		if first {
+			if passwordEntry.Text != repeatEntry.Text {
+				dialog.ShowError(fmt.Errorf("пароли не совпадают"), a.window)
+				return
+			}
+			if _, err := database.AddAdmin(a.db, name, passwordEntry.Text); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+		} else if err := database.AuthenticateAdmin(a.db, name, passwordEntry.Text); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.start(name)
+	}
+	nameEntry.OnSubmitted = func(string) { login() }
+	passwordEntry.OnSubmitted = func(string) { login() }
+	repeatEntry.OnSubmitted = func(string) { login() }
+
+	title := "Вход администратора"
+	instruction := "Введите имя и пароль администратора"
+	buttonText := "Войти"
+	form := container.NewVBox(nameEntry, passwordEntry)
+	if first {
+		title = "Первый запуск"
+		instruction = "Администраторов ещё нет: задайте имя и пароль первого администратора"
+		buttonText = "Создать и войти"
+		form.Add(repeatEntry)
+	}
+	loginBtn := widget.NewButton(buttonText, login)
+	loginBtn.Importance = widget.HighImportance
+
+	a.window.SetContent(container.NewCenter(container.NewVBox(
+		widget.NewLabelWithStyle(title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel(instruction),
+		form,
+		loginBtn,
+	)))
+	a.window.Canvas().Focus(nameEntry)
+}
+
+// createAdminsSection возвращает список администраторов с кнопками
+// добавления, удаления и смены собственного пароля.
+func (a *AdminApp) createAdminsSection() fyne.CanvasObject {
+	admins, err := database.GetAdmins(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки администраторов: %v", err)
+	}
+	names := make([]string, len(admins))
+	for i, admin := range admins {
+		names[i] = admin.Name
+	}
+	adminSelect := widget.NewSelect(names, nil)
+	adminSelect.PlaceHolder = "Администратор"
+
+	addBtn := widget.NewButton("Добавить администратора...", func() {
+		nameEntry := widget.NewEntry()
+		passwordEntry := widget.NewPasswordEntry()
+		repeatEntry := widget.NewPasswordEntry()
+		items := []*widget.FormItem{
+			widget.NewFormItem("Имя", nameEntry),
+			widget.NewFormItem("Пароль", passwordEntry),
+			widget.NewFormItem("Повторите пароль", repeatEntry),
+		}
+		dialog.ShowForm("Новый администратор", "Добавить", "Отмена", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			if passwordEntry.Text != repeatEntry.Text {
+				dialog.ShowError(fmt.Errorf("пароли не совпадают"), a.window)
+				return
+			}
+			if _, err := database.AddAdmin(a.db, nameEntry.Text, passwordEntry.Text); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			a.refreshApprovals()
+		}, a.window)
+	})
+
+	deleteBtn := widget.NewButton("Удалить", func() {
+		index := adminSelect.SelectedIndex()
+		if index < 0 {
+			dialog.ShowInformation("Внимание", "Выберите администратора", a.window)
+			return
+		}
+		admin := admins[index]
+		if admin.Name == a.admin {
+			dialog.ShowInformation("Внимание", "Нельзя удалить собственную учётную запись", a.window)
+			return
+		}
+		dialog.ShowConfirm("Удаление администратора", fmt.Sprintf("Удалить администратора %s?", admin.Name), func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := database.DeleteAdmin(a.db, admin.ID); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			a.refreshApprovals()
+		}, a.window)
+	})
+
+	passwordBtn := widget.NewButton("Сменить свой пароль...", func() {
+		oldEntry := widget.NewPasswordEntry()
+		newEntry := widget.NewPasswordEntry()
+		repeatEntry := widget.NewPasswordEntry()
+		items := []*widget.FormItem{
+			widget.NewFormItem("Текущий пароль", oldEntry),
+			widget.NewFormItem("Новый пароль", newEntry),
+			widget.NewFormItem("Повторите пароль", repeatEntry),
+		}
+		dialog.ShowForm("Смена пароля", "Сменить", "Отмена", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			if newEntry.Text != repeatEntry.Text {
+				dialog.ShowError(fmt.Errorf("пароли не совпадают"), a.window)
+				return
+			}
+			if err := database.ChangeAdminPassword(a.db, a.admin, oldEntry.Text, newEntry.Text); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			dialog.ShowInformation("Готово", "Пароль изменён", a.window)
+		}, a.window)
+	})
+
+	return container.NewVBox(
+		widget.NewLabelWithStyle("Администраторы", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel(fmt.Sprintf("%s. При включённом двойном одобрении после второго администратора список меняется только с отключённым одобрением.",
+			strings.Join(names, ", "))),
+		container.NewBorder(nil, nil, nil, container.NewHBox(deleteBtn, addBtn, passwordBtn), adminSelect),
+	)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+var operationNames = map[database.Operation]string{
+	database.OpGrantAll:        "Выдать все права пользователю",
+	database.OpDeleteUser:      "Удалить пользователя",
+	database.OpDeleteLetter:    "Удалить букву",
+	database.OpDisableApproval: "Отключить двойное одобрение",
+}
+
+var requestStatusNames = map[string]string{
+	database.RequestPending:  "ожидает",
+	database.RequestApproved: "одобрен",
+	database.RequestRejected: "отклонён",
+}
+
+// privileged выполняет операцию сразу или, если включено двойное
+// одобрение, создаёт запрос на неё. Возвращает true, если создан запрос.
+func (a *AdminApp) privileged(op database.Operation, targetID int, target string, run func(tx *sql.Tx) error) (bool, error) {
+	required, err := database.GetApprovalRequired(a.db)
+	if err != nil {
+		return false, err
+	}
+	if !required {
+		return false, a.execute(fmt.Sprintf("%s %s", operationNames[op], target), run)
+	}
+	if _, err := database.RequestChange(a.db, op, targetID, target, a.admin); err != nil {
+		return false, err
+	}
+	a.refreshApprovals()
+	return true, nil
+}
+
+// showRequested сообщает о созданном запросе вместо выполненной операции.
+func (a *AdminApp) showRequested(count int) {
+	dialog.ShowInformation("Нужно одобрение",
+		fmt.Sprintf("Создано запросов: %d. Операция будет выполнена, когда её одобрит другой администратор на вкладке «Одобрения».", count),
+		a.window)
+}
+
+func (a *AdminApp) refreshApprovals() {
+	if a.mainTabs != nil {
+		a.tabs.approvals.Content = a.createApprovalsTab()
+		a.mainTabs.Refresh()
+	}
+}
+
+func (a *AdminApp) createApprovalsTab() fyne.CanvasObject {
+	requests, err := database.GetChangeRequests(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки запросов на одобрение: %v", err)
+	}
+	selected := -1
+
+	requestsList := widget.NewList(
+		func() int {
+			return len(requests)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			request := requests[id]
+			text := fmt.Sprintf("№%d  %s: %s - запросил %s %s, %s", request.ID, operationNames[request.Operation], request.Target,
+				request.RequestedBy, request.RequestedAt.Format(sessionTimeLayout), requestStatusNames[request.Status])
+			if request.Status != database.RequestPending {
+				text += fmt.Sprintf(" (%s %s)", request.DecidedBy, request.DecidedAt.Format(sessionTimeLayout))
+			}
+			label := item.(*widget.Label)
+			label.SetText(text)
+			if request.Status == database.RequestPending {
+				label.Importance = widget.MediumImportance
+			} else {
+				label.Importance = widget.LowImportance
+			}
+			label.Refresh()
+		},
+	)
+	requestsList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+	}
+
+	selectedRequest := func() (database.ChangeRequest, bool) {
+		if selected < 0 || selected >= len(requests) || requests[selected].Status != database.RequestPending {
+			dialog.ShowInformation("Внимание", "Выберите ожидающий запрос", a.window)
+			return database.ChangeRequest{}, false
+		}
+		return requests[selected], true
+	}
+
+	approveBtn := widget.NewButton("Одобрить и выполнить", func() {
+		request, ok := selectedRequest()
+		if !ok {
+			return
+		}
+		dialog.ShowConfirm("Одобрение", fmt.Sprintf("%s: %s?", operationNames[request.Operation], request.Target), func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			title := fmt.Sprintf("Одобрен запрос №%d (%s): %s %s", request.ID, request.RequestedBy, operationNames[request.Operation], request.Target)
+			err := a.execute(title, func(tx *sql.Tx) error {
+				return database.ApproveChange(tx, request.ID, a.admin)
+			})
+			if err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			a.refreshAllTabs()
+		}, a.window)
+	})
+	approveBtn.Importance = widget.HighImportance
+
+	rejectBtn := widget.NewButton("Отклонить", func() {
+		request, ok := selectedRequest()
+		if !ok {
+			return
+		}
+		if err := database.RejectChange(a.db, request.ID, a.admin); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.refreshApprovals()
+	})
+
+	return container.NewBorder(
+		container.NewVBox(
+			widget.NewLabelWithStyle("Запросы на привилегированные операции", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabel(fmt.Sprintf("Вы вошли как %s. Одобрить можно только запрос другого администратора; свой запрос можно отклонить.", a.admin)),
+			container.NewHBox(approveBtn, rejectBtn),
+		),
+		container.NewVBox(widget.NewSeparator(), a.createAdminsSection()),
+		nil, nil,
+		requestsList,
+	)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"laba3/database"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+)
+
+var backupFileFilter = storage.NewExtensionFileFilter([]string{".db"})
+
+func (a *AdminApp) createMainMenu() *fyne.MainMenu {
+	return fyne.NewMainMenu(
+		fyne.NewMenu("Файл",
+			fyne.NewMenuItem("Создать резервную копию...", a.showBackupDialog),
+			fyne.NewMenuItem("Восстановить из резервной копии...", a.showRestoreDialog),
+		),
+		fyne.NewMenu("Правка",
+			fyne.NewMenuItem("Отменить (Ctrl+Z)", a.undo),
+			fyne.NewMenuItem("Повторить (Ctrl+Y)", a.redo),
+		),
+	)
+}
+
+func (a *AdminApp) showBackupDialog() {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		// VACUUM INTO пишет файл сам, диалог нужен только для выбора пути
+		writer.Close()
+
+		if err := database.Backup(a.db, writer.URI().Path()); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		dialog.ShowInformation("Готово", fmt.Sprintf("Резервная копия сохранена в %s", writer.URI().Name()), a.window)
+	}, a.window)
+	save.SetFileName("backup-" + time.Now().Format("20060102-150405") + ".db")
+	save.SetFilter(backupFileFilter)
+	save.Show()
+}
+
+func (a *AdminApp) showRestoreDialog() {
+	if err := database.CheckRestoreAllowed(a.db); err != nil {
+		dialog.ShowError(err, a.window)
+		return
+	}
+	open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+		path := reader.URI().Path()
+
+		version, err := database.CheckBackup(path)
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+
+		message := fmt.Sprintf("Копия %s цела (версия схемы %d).\nВсе текущие данные будут заменены данными копии,\n"+
+			"открытые сеансы пользователей будут завершены. Продолжить?", reader.URI().Name(), version)
+		dialog.ShowConfirm("Восстановление базы данных", message, func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := database.Restore(a.db, path); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			a.loadAlphabet()
+			a.clearHistory()
+			a.refreshAllTabs()
+			a.scheduleBackups()
+			dialog.ShowInformation("Готово", "База данных восстановлена из резервной копии", a.window)
+		}, a.window)
+	}, a.window)
+	open.SetFilter(backupFileFilter)
+	open.Show()
+}
+
+// scheduleBackups запускает таймер плановых резервных копий по настройкам.
+// Копии создаются, пока открыта админ-панель; для копий по расписанию без
+// неё подходит cli backup -dir из cron.
+func (a *AdminApp) scheduleBackups() {
+	if a.backupTimer != nil {
+		a.backupTimer.Stop()
+		a.backupTimer = nil
+	}
+
+	schedule, err := database.GetBackupSchedule(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки расписания резервных копий: %v", err)
+		return
+	}
+	if schedule.Interval == 0 {
+		return
+	}
+
+	a.backupTimer = time.AfterFunc(schedule.Interval, func() {
+		path, err := database.RotateBackup(a.db, schedule.Dir, schedule.Keep)
+		if err != nil {
+			log.Printf("Ошибка плановой резервной копии: %v", err)
+		} else {
+			log.Printf("Создана плановая резервная копия %s", path)
+		}
+		fyne.Do(a.scheduleBackups)
+	})
+}
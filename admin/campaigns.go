@@ -0,0 +1,386 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// campaignCheckInterval - как часто админ-панель закрывает кампании с
+// истёкшим сроком.
+const campaignCheckInterval = time.Minute
+
+// campaignDurations - сроки, на которые можно запустить кампанию.
+var campaignDurations = []struct {
+	title string
+	days  int
+}{
+	{"7 дней", 7},
+	{"14 дней", 14},
+	{"30 дней", 30},
+	{"90 дней", 90},
+}
+
+// closeDueCampaigns закрывает кампании, срок которых истёк, и повторяет
+// проверку через campaignCheckInterval. Закрытие идёт через историю
+// операций, поэтому автоматический отзыв прав можно отменить.
+func (a *AdminApp) closeDueCampaigns() {
+	if a.campaignTimer != nil {
+		a.campaignTimer.Stop()
+	}
+	a.campaignTimer = time.AfterFunc(campaignCheckInterval, func() {
+		fyne.Do(a.closeDueCampaigns)
+	})
+
+	campaigns, err := database.DueCampaigns(a.db)
+	if err != nil {
+		log.Printf("Ошибка проверки сроков кампаний: %v", err)
+		return
+	}
+	if len(campaigns) == 0 {
+		return
+	}
+	for _, campaign := range campaigns {
+		var revoked int
+		err := a.execute(fmt.Sprintf("Закрыта по сроку кампания '%s'", campaign.Name), func(tx *sql.Tx) error {
+			var err error
+			revoked, err = database.CloseCampaign(tx, campaign.ID)
+			return err
+		})
+		if err != nil {
+			log.Printf("Ошибка закрытия кампании '%s': %v", campaign.Name, err)
+			continue
+		}
+		log.Printf("Кампания '%s' закрыта по сроку, отозвано прав: %d", campaign.Name, revoked)
+	}
+	if a.mainTabs != nil {
+		a.refreshAllTabs()
+	}
+}
+
+// campaignSummary - строка прогресса кампании.
+func campaignSummary(campaign database.Campaign) string {
+	return fmt.Sprintf("подтверждено %d, отозвано %d, осталось %d из %d",
+		campaign.Confirmed, campaign.Revoked+campaign.AutoRevoked, campaign.Pending(), campaign.Total)
+}
+
+func (a *AdminApp) createCampaignsTab() fyne.CanvasObject {
+	var campaigns []database.Campaign
+	var items, shownItems []database.CampaignItem
+	selectedCampaign := -1
+	selectedItem := -1
+
+	progress := widget.NewProgressBar()
+	summary := widget.NewLabel("Выберите кампанию")
+	summary.Wrapping = fyne.TextWrapWord
+	onlyPending := widget.NewCheck("Только нерассмотренные", nil)
+
+	campaignsList := widget.NewList(
+		func() int {
+			return len(campaigns)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			campaign := campaigns[id]
+			label := item.(*widget.Label)
+			if campaign.Closed() {
+				label.SetText(fmt.Sprintf("%s - закрыта %s", campaign.Name, campaign.ClosedAt.Format(sessionTimeLayout)))
+				label.Importance = widget.LowImportance
+			} else {
+				label.SetText(fmt.Sprintf("%s - до %s, осталось %d из %d", campaign.Name,
+					campaign.Deadline.Format(sessionTimeLayout), campaign.Pending(), campaign.Total))
+				label.Importance = widget.MediumImportance
+			}
+			label.Refresh()
+		},
+	)
+
+	itemsList := widget.NewList(
+		func() int {
+			return len(shownItems)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, object fyne.CanvasObject) {
+			item := shownItems[id]
+			text := fmt.Sprintf("%s: '%s' - %s", item.UserName, displayLetter(item.Letter), database.DecisionNames[item.Decision])
+			if item.Reviewer != "" {
+				text += fmt.Sprintf(" (%s %s)", item.Reviewer, item.DecidedAt.Format(sessionTimeLayout))
+			}
+			label := object.(*widget.Label)
+			label.SetText(text)
+			switch item.Decision {
+			case "":
+				label.Importance = widget.MediumImportance
+			case database.DecisionConfirmed:
+				label.Importance = widget.SuccessImportance
+			default:
+				label.Importance = widget.DangerImportance
+			}
+			label.Refresh()
+		},
+	)
+
+	showItems := func() {
+		shownItems = shownItems[:0]
+		for _, item := range items {
+			if !onlyPending.Checked || item.Decision == "" {
+				shownItems = append(shownItems, item)
+			}
+		}
+		selectedItem = -1
+		itemsList.UnselectAll()
+		itemsList.Refresh()
+	}
+	onlyPending.OnChanged = func(bool) { showItems() }
+
+	loadItems := func() {
+		items = nil
+		if selectedCampaign < 0 || selectedCampaign >= len(campaigns) {
+			progress.SetValue(0)
+			summary.SetText("Выберите кампанию")
+			showItems()
+			return
+		}
+		campaign := campaigns[selectedCampaign]
+		var err error
+		items, err = database.GetCampaignItems(a.db, campaign.ID)
+		if err != nil {
+			log.Printf("Ошибка загрузки прав кампании: %v", err)
+		}
+		if campaign.Total > 0 {
+			progress.SetValue(float64(campaign.Total-campaign.Pending()) / float64(campaign.Total))
+		}
+		text := fmt.Sprintf("%s: %s. Запустил %s %s, срок до %s", campaign.Name, campaignSummary(campaign),
+			campaign.StartedBy, campaign.StartedAt.Format(sessionTimeLayout), campaign.Deadline.Format(sessionTimeLayout))
+		if campaign.AutoRevoke {
+			text += ", нерассмотренные права будут отозваны при закрытии"
+		}
+		summary.SetText(text)
+		showItems()
+	}
+
+	// loadCampaigns перечитывает кампании, сохраняя выбранную
+	loadCampaigns := func() {
+		selectedID := 0
+		if selectedCampaign >= 0 && selectedCampaign < len(campaigns) {
+			selectedID = campaigns[selectedCampaign].ID
+		}
+		var err error
+		campaigns, err = database.GetCampaigns(a.db)
+		if err != nil {
+			log.Printf("Ошибка загрузки кампаний: %v", err)
+		}
+		selectedCampaign = -1
+		for i, campaign := range campaigns {
+			if campaign.ID == selectedID {
+				selectedCampaign = i
+			}
+		}
+		campaignsList.Refresh()
+		loadItems()
+	}
+	campaignsList.OnSelected = func(id widget.ListItemID) {
+		selectedCampaign = id
+		loadItems()
+	}
+	itemsList.OnSelected = func(id widget.ListItemID) {
+		selectedItem = id
+	}
+	loadCampaigns()
+
+	openCampaign := func() (database.Campaign, bool) {
+		if selectedCampaign < 0 || selectedCampaign >= len(campaigns) {
+			dialog.ShowInformation("Внимание", "Выберите кампанию", a.window)
+			return database.Campaign{}, false
+		}
+		campaign := campaigns[selectedCampaign]
+		if campaign.Closed() {
+			dialog.ShowInformation("Внимание", fmt.Sprintf("Кампания '%s' уже закрыта", campaign.Name), a.window)
+			return database.Campaign{}, false
+		}
+		return campaign, true
+	}
+
+	// decide принимает решение по выбранному праву или, если wholeUser, по
+	// всем нерассмотренным правам того же пользователя
+	decide := func(decision string, wholeUser bool) {
+		campaign, ok := openCampaign()
+		if !ok {
+			return
+		}
+		if selectedItem < 0 || selectedItem >= len(shownItems) {
+			dialog.ShowInformation("Внимание", "Выберите право в списке", a.window)
+			return
+		}
+		selected := shownItems[selectedItem]
+
+		var ids []int
+		for _, item := range items {
+			if item.ID == selected.ID || (wholeUser && item.UserID == selected.UserID && item.Decision == "") {
+				ids = append(ids, item.ID)
+			}
+		}
+		title := fmt.Sprintf("Кампания '%s': %s %s '%s'", campaign.Name, database.DecisionNames[decision],
+			selected.UserName, displayLetter(selected.Letter))
+		if wholeUser {
+			title = fmt.Sprintf("Кампания '%s': %s все права %s", campaign.Name, database.DecisionNames[decision], selected.UserName)
+		}
+
+		err := a.execute(title, func(tx *sql.Tx) error {
+			_, err := database.DecideCampaignItems(tx, campaign.ID, ids, a.admin, decision)
+			return err
+		})
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if decision == database.DecisionRevoked {
+			log.Printf("%s", title)
+			a.updateMatrixTable()
+		}
+		loadCampaigns()
+	}
+
+	confirmBtn := widget.NewButton("Подтвердить", func() { decide(database.DecisionConfirmed, false) })
+	confirmBtn.Importance = widget.SuccessImportance
+	revokeBtn := widget.NewButton("Отозвать", func() { decide(database.DecisionRevoked, false) })
+	revokeBtn.Importance = widget.DangerImportance
+	confirmUserBtn := widget.NewButton("Подтвердить все права пользователя", func() { decide(database.DecisionConfirmed, true) })
+	revokeUserBtn := widget.NewButton("Отозвать все права пользователя", func() { decide(database.DecisionRevoked, true) })
+
+	startBtn := widget.NewButton("Новая кампания...", func() {
+		a.showStartCampaignDialog(loadCampaigns)
+	})
+	startBtn.Importance = widget.HighImportance
+
+	closeBtn := widget.NewButton("Закрыть кампанию", func() {
+		campaign, ok := openCampaign()
+		if !ok {
+			return
+		}
+		message := fmt.Sprintf("Закрыть кампанию '%s' (%s)?", campaign.Name, campaignSummary(campaign))
+		if campaign.AutoRevoke && campaign.Pending() > 0 {
+			message += fmt.Sprintf("\nНерассмотренные права (%d) будут отозваны.", campaign.Pending())
+		}
+		dialog.ShowConfirm("Закрытие кампании", message, func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			err := a.execute(fmt.Sprintf("Закрыта кампания '%s'", campaign.Name), func(tx *sql.Tx) error {
+				_, err := database.CloseCampaign(tx, campaign.ID)
+				return err
+			})
+			if err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			a.updateMatrixTable()
+			loadCampaigns()
+		}, a.window)
+	})
+
+	reportBtn := widget.NewButton("Отчёт в CSV...", func() {
+		if selectedCampaign < 0 || selectedCampaign >= len(campaigns) {
+			dialog.ShowInformation("Внимание", "Выберите кампанию", a.window)
+			return
+		}
+		a.showCampaignReportDialog(campaigns[selectedCampaign])
+	})
+
+	left := container.NewBorder(
+		container.NewVBox(
+			widget.NewLabelWithStyle("Кампании пересмотра прав", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			container.NewHBox(startBtn, closeBtn, reportBtn),
+		),
+		nil, nil, nil,
+		campaignsList,
+	)
+	right := container.NewBorder(
+		container.NewVBox(
+			summary,
+			progress,
+			container.NewHBox(confirmBtn, revokeBtn, onlyPending),
+			container.NewHBox(confirmUserBtn, revokeUserBtn),
+		),
+		nil, nil, nil,
+		itemsList,
+	)
+	split := container.NewHSplit(left, right)
+	split.Offset = 0.4
+	return split
+}
+
+func (a *AdminApp) showStartCampaignDialog(onStarted func()) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText("Пересмотр " + time.Now().Format("02.01.2006"))
+
+	options := make([]string, len(campaignDurations))
+	for i, duration := range campaignDurations {
+		options[i] = duration.title
+	}
+	durationSelect := widget.NewSelect(options, nil)
+	durationSelect.SetSelectedIndex(1)
+
+	autoRevokeCheck := widget.NewCheck("Отозвать нерассмотренные права при закрытии", nil)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Название", nameEntry),
+		widget.NewFormItem("Срок", durationSelect),
+		widget.NewFormItem("", autoRevokeCheck),
+	}
+	dialog.ShowForm("Новая кампания пересмотра", "Запустить", "Отмена", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		deadline := time.Now().AddDate(0, 0, campaignDurations[durationSelect.SelectedIndex()].days)
+		var id int
+		err := a.applyChanges(func(db database.Querier) error {
+			var err error
+			id, err = database.StartCampaign(db, nameEntry.Text, a.admin, deadline, autoRevokeCheck.Checked)
+			return err
+		})
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		log.Printf("Запущена кампания пересмотра №%d '%s' до %s", id, nameEntry.Text, deadline.Format(sessionTimeLayout))
+		onStarted()
+	}, a.window)
+}
+
+func (a *AdminApp) showCampaignReportDialog(campaign database.Campaign) {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		items, err := database.GetCampaignItems(a.db, campaign.ID)
+		if err == nil {
+			err = database.WriteCampaignReport(writer, campaign, items)
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("ошибка записи отчёта: %v", err), a.window)
+			return
+		}
+		dialog.ShowInformation("Готово", fmt.Sprintf("Отчёт сохранён в %s", writer.URI().Name()), a.window)
+	}, a.window)
+	save.SetFileName(fmt.Sprintf("campaign-%d.csv", campaign.ID))
+	save.SetFilter(csvFileFilter)
+	save.Show()
+}
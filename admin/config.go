@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+var importModeNames = map[database.ImportMode]string{
+	database.ImportMerge:   "Объединить: только добавить недостающее",
+	database.ImportReplace: "Заменить: привести базу в точное соответствие файлу",
+}
+
+var configFileFilter = storage.NewExtensionFileFilter([]string{".json", ".yaml", ".yml"})
+
+// createConfigTransfer - блок вкладки настроек для выгрузки и загрузки
+// конфигурации доступа.
+func (a *AdminApp) createConfigTransfer() fyne.CanvasObject {
+	exportBtn := widget.NewButton("Экспорт конфигурации...", a.showExportDialog)
+	importBtn := widget.NewButton("Импорт конфигурации...", a.showImportDialog)
+
+	return container.NewVBox(
+		widget.NewLabelWithStyle("Перенос конфигурации", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Пользователи, буквы, права, правила и настройки в формате JSON или YAML (по расширению файла):"),
+		container.NewHBox(exportBtn, importBtn),
+	)
+}
+
+func (a *AdminApp) showExportDialog() {
+	cfg, err := database.Export(a.db)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("ошибка выгрузки конфигурации: %v", err), a.window)
+		return
+	}
+
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if err := database.WriteConfig(writer, cfg, database.FormatFromPath(writer.URI().Name())); err != nil {
+			dialog.ShowError(fmt.Errorf("ошибка записи файла: %v", err), a.window)
+			return
+		}
+		dialog.ShowInformation("Готово", fmt.Sprintf("Конфигурация сохранена в %s: пользователей %d, букв %d",
+			writer.URI().Name(), len(cfg.Users), len(cfg.Letters)), a.window)
+	}, a.window)
+	save.SetFileName("access.json")
+	save.SetFilter(configFileFilter)
+	save.Show()
+}
+
+func (a *AdminApp) showImportDialog() {
+	open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		cfg, err := database.ReadConfig(reader, database.FormatFromPath(reader.URI().Name()))
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.showImportPreview(reader.URI().Name(), cfg)
+	}, a.window)
+	open.SetFilter(configFileFilter)
+	open.Show()
+}
+
+// showImportPreview показывает изменения, которые внесёт импорт в выбранном
+// режиме, и применяет их после подтверждения.
+func (a *AdminApp) showImportPreview(name string, cfg *database.Config) {
+	mode := database.ImportMerge
+
+	changesLabel := widget.NewLabel("")
+	changesLabel.TextStyle = fyne.TextStyle{Monospace: true}
+	preview := func() {
+		changes, err := database.Import(a.db, cfg, mode, true)
+		if err != nil {
+			changesLabel.SetText(fmt.Sprintf("Импорт невозможен: %v", err))
+			return
+		}
+		changesLabel.SetText(changesText(changes))
+	}
+
+	modeOptions := make([]string, len(database.ImportModes))
+	for i, m := range database.ImportModes {
+		modeOptions[i] = importModeNames[m]
+	}
+	modeSelect := widget.NewRadioGroup(modeOptions, func(selected string) {
+		for _, m := range database.ImportModes {
+			if importModeNames[m] == selected {
+				mode = m
+			}
+		}
+		preview()
+	})
+	modeSelect.Required = true
+	modeSelect.SetSelected(importModeNames[mode])
+
+	changesScroll := container.NewScroll(changesLabel)
+	changesScroll.SetMinSize(fyne.NewSize(600, 300))
+
+	content := container.NewBorder(
+		container.NewVBox(modeSelect, widget.NewLabel("Изменения (+ добавить, - удалить, ~ изменить):")),
+		nil, nil, nil,
+		changesScroll,
+	)
+
+	dialog.ShowCustomConfirm("Импорт "+name, "Применить", "Отмена", content, func(apply bool) {
+		if !apply {
+			return
+		}
+		var changes []database.Change
+		err := a.execute("Импорт конфигурации "+name, func(tx *sql.Tx) error {
+			var err error
+			changes, err = database.ImportTx(tx, cfg, mode)
+			return err
+		})
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("ошибка импорта: %v", err), a.window)
+			return
+		}
+		a.loadAlphabet()
+		a.refreshAllTabs()
+		dialog.ShowInformation("Готово", fmt.Sprintf("Импорт выполнен: %d изменений", len(changes)), a.window)
+	}, a.window)
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"laba3/charset"
+	"laba3/database"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+var csvFileFilter = storage.NewExtensionFileFilter([]string{".csv", ".txt"})
+
+// Заголовки первого столбца, по которым распознаётся строка заголовка.
+var csvUserHeaders = []string{"user", "name", "пользователь", "имя"}
+
+// csvRow - строка файла импорта: пользователь и символы, которые ему
+// выдаются. Символы записываются так же, как в форме массового управления,
+// поэтому подходят и файл "пользователь, все символы", и файл с одной
+// парой пользователь-символ в строке.
+type csvRow struct {
+	line    int
+	user    string
+	letters []string
+	err     error
+}
+
+// csvComma выбирает разделитель по первой строке: русский Excel сохраняет
+// CSV через точку с запятой.
+func csvComma(firstLine string) rune {
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		return ';'
+	}
+	return ','
+}
+
+// readGrantsCSV разбирает файл импорта и проверяет каждую строку теми же
+// правилами, что и форма массового управления. Ошибка возвращается только
+// для файла, который не удалось прочитать как CSV.
+func (a *AdminApp) readGrantsCSV(r io.Reader) ([]csvRow, error) {
+	decoded, _, err := charset.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(decoded)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+
+	firstLine, _, _ := strings.Cut(text, "\n")
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = csvComma(firstLine)
+	reader.FieldsPerRecord = -1
+
+	var rows []csvRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if len(rows) == 0 && line == 1 && isCSVHeader(record[0]) {
+			continue
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		rows = append(rows, a.checkCSVRecord(line, record))
+	}
+	return rows, nil
+}
+
+func isCSVHeader(cell string) bool {
+	cell = strings.ToLower(strings.TrimSpace(cell))
+	for _, header := range csvUserHeaders {
+		if cell == header {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AdminApp) checkCSVRecord(line int, record []string) csvRow {
+	row := csvRow{line: line, user: strings.TrimSpace(record[0])}
+	if len(record) > 2 {
+		row.err = fmt.Errorf("ожидается 2 столбца (пользователь, символы), получено %d", len(record))
+		return row
+	}
+
+	if row.user == "" {
+		row.err = fmt.Errorf("имя не может быть пустым")
+		return row
+	}
+	if err := validateUserList(row.user); err != nil {
+		row.err = err
+		return row
+	}
+
+	if len(record) == 2 {
+		if err := a.validateLetters(record[1]); err != nil {
+			row.err = err
+			return row
+		}
+		row.letters = parseLetters(record[1])
+	}
+	return row
+}
+
+// grantsConfig собирает строки в конфигурацию для импорта в режиме merge:
+// строки одного пользователя объединяются.
+func grantsConfig(rows []csvRow) *database.Config {
+	cfg := &database.Config{Version: database.ConfigVersion}
+	index := make(map[string]int)
+	for _, row := range rows {
+		i, ok := index[row.user]
+		if !ok {
+			i = len(cfg.Users)
+			index[row.user] = i
+			cfg.Users = append(cfg.Users, database.UserConfig{Name: row.user, Letters: []string{}})
+		}
+		cfg.Users[i].Letters = append(cfg.Users[i].Letters, row.letters...)
+	}
+	return cfg
+}
+
+func (a *AdminApp) showCSVImportDialog() {
+	open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		rows, err := a.readGrantsCSV(reader)
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if len(rows) == 0 {
+			dialog.ShowInformation("Внимание", "Файл не содержит строк с пользователями", a.window)
+			return
+		}
+		a.showCSVPreview(reader.URI().Name(), rows)
+	}, a.window)
+	open.SetFilter(csvFileFilter)
+	open.Show()
+}
+
+// showCSVPreview показывает строки файла с результатом проверки. Файл
+// применяется целиком в одной транзакции и только если в нём нет ошибок.
+func (a *AdminApp) showCSVPreview(name string, rows []csvRow) {
+	headers := []string{"Строка", "Пользователь", "Символы", "Проверка"}
+
+	var invalid int
+	status := make([]string, len(rows))
+	for i, row := range rows {
+		switch {
+		case row.err != nil:
+			invalid++
+			status[i] = "Ошибка: " + row.err.Error()
+		case a.userExists(row.user):
+			status[i] = "OK, существующий пользователь"
+		default:
+			status[i] = "OK, новый пользователь"
+		}
+	}
+
+	table := widget.NewTable(
+		func() (int, int) {
+			return len(rows) + 1, len(headers)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.TableCellID, cell fyne.CanvasObject) {
+			label := cell.(*widget.Label)
+			label.Importance = widget.MediumImportance
+			if id.Row == 0 {
+				label.SetText(headers[id.Col])
+				label.Importance = widget.HighImportance
+				return
+			}
+
+			row := rows[id.Row-1]
+			switch id.Col {
+			case 0:
+				label.SetText(fmt.Sprint(row.line))
+			case 1:
+				label.SetText(row.user)
+			case 2:
+				letters := make([]string, len(row.letters))
+				for i, letter := range row.letters {
+					letters[i] = displayLetter(letter)
+				}
+				label.SetText(strings.Join(letters, " "))
+			case 3:
+				label.SetText(status[id.Row-1])
+				if row.err != nil {
+					label.Importance = widget.DangerImportance
+				}
+			}
+		},
+	)
+	table.SetColumnWidth(0, 70)
+	table.SetColumnWidth(1, 180)
+	table.SetColumnWidth(2, 220)
+	table.SetColumnWidth(3, 420)
+
+	cfg := grantsConfig(rows)
+	summary := fmt.Sprintf("Строк: %d, с ошибками: %d", len(rows), invalid)
+	if invalid == 0 {
+		changes, err := database.Import(a.db, cfg, database.ImportMerge, true)
+		if err != nil {
+			summary += fmt.Sprintf(". Импорт невозможен: %v", err)
+			invalid = -1
+		} else {
+			summary += fmt.Sprintf(". Изменений в базе: %d", len(changes))
+		}
+	} else {
+		summary += ". Исправьте ошибки в файле: он применяется только целиком"
+	}
+
+	content := container.NewBorder(widget.NewLabel(summary), nil, nil, nil, table)
+
+	var preview dialog.Dialog
+	if invalid != 0 {
+		preview = dialog.NewCustom("Импорт "+name, "Закрыть", content, a.window)
+	} else {
+		preview = dialog.NewCustomConfirm("Импорт "+name, "Применить", "Отмена", content, func(apply bool) {
+			if !apply {
+				return
+			}
+			var changes []database.Change
+			err := a.execute("Импорт из CSV "+name, func(tx *sql.Tx) error {
+				var err error
+				changes, err = database.ImportTx(tx, cfg, database.ImportMerge)
+				return err
+			})
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("ошибка импорта: %v", err), a.window)
+				return
+			}
+			a.refreshAllTabs()
+			dialog.ShowInformation("Готово", fmt.Sprintf("Импорт выполнен: %d изменений", len(changes)), a.window)
+		}, a.window)
+	}
+	preview.Resize(fyne.NewSize(950, 550))
+	preview.Show()
+}
+
+func (a *AdminApp) userExists(name string) bool {
+	_, err := database.FindUser(a.db, name)
+	return err == nil
+}
+
+// escapeLetter записывает пробельные символы так, как их принимает
+// parseLetters.
+func escapeLetter(letter string) string {
+	for escaped, char := range escapedLetters {
+		if char == letter {
+			return escaped
+		}
+	}
+	return letter
+}
+
+// writeMatrixCSV выгружает явно выданные права: пользователь и его символы
+// через пробел. Файл можно загрузить обратно через импорт CSV.
+func (a *AdminApp) writeMatrixCSV(w io.Writer) error {
+	cfg, err := database.Export(a.db)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"user", "letters"}); err != nil {
+		return err
+	}
+	for _, user := range cfg.Users {
+		letters := make([]string, len(user.Letters))
+		for i, letter := range user.Letters {
+			letters[i] = escapeLetter(letter)
+		}
+		if err := writer.Write([]string{user.Name, strings.Join(letters, " ")}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (a *AdminApp) showCSVExportDialog() {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if err := a.writeMatrixCSV(writer); err != nil {
+			dialog.ShowError(fmt.Errorf("ошибка записи файла: %v", err), a.window)
+			return
+		}
+		dialog.ShowInformation("Готово", fmt.Sprintf("Матрица доступа сохранена в %s", writer.URI().Name()), a.window)
+	}, a.window)
+	save.SetFileName("matrix.csv")
+	save.SetFilter(csvFileFilter)
+	save.Show()
+}
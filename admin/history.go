@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// historyLimit - сколько последних операций можно отменить.
+const historyLimit = 100
+
+// command - выполненная операция администратора вместе со строками базы,
+// которые она изменила.
+type command struct {
+	id      int
+	title   string
+	at      time.Time
+	changes database.Changeset
+}
+
+// history - стеки отмены и повтора. Новые операции добавляются в конец
+// done; отменённые переносятся в конец undone и убираются оттуда при
+// следующей новой операции.
+type history struct {
+	done   []command
+	undone []command
+	lastID int
+}
+
+func (a *AdminApp) lastDoneID() int {
+	if len(a.history.done) == 0 {
+		return 0
+	}
+	return a.history.done[len(a.history.done)-1].id
+}
+
+// execute выполняет операцию в транзакции и запоминает внесённые ею
+// изменения, чтобы её можно было отменить. Операции, ничего не
+// изменившие, в историю не попадают.
+func (a *AdminApp) execute(title string, op func(tx *sql.Tx) error) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := database.StartCapture(tx); err != nil {
+		return err
+	}
+	if err := op(tx); err != nil {
+		return err
+	}
+	changes, err := database.FinishCapture(tx)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	a.history.lastID++
+	a.history.done = append(a.history.done, command{id: a.history.lastID, title: title, at: time.Now(), changes: changes})
+	if len(a.history.done) > historyLimit {
+		a.history.done = a.history.done[1:]
+	}
+	a.history.undone = nil
+	a.updateHistory()
+	return nil
+}
+
+// applyChanges применяет изменения операции в одной транзакции.
+func (a *AdminApp) applyChanges(apply func(db database.Querier) error) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := apply(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (a *AdminApp) undo() {
+	if len(a.history.done) == 0 {
+		return
+	}
+	cmd := a.history.done[len(a.history.done)-1]
+	if err := a.applyChanges(cmd.changes.Revert); err != nil {
+		dialog.ShowError(fmt.Errorf("не удалось отменить «%s»: %v", cmd.title, err), a.window)
+		return
+	}
+	a.history.done = a.history.done[:len(a.history.done)-1]
+	a.history.undone = append(a.history.undone, cmd)
+	a.afterHistoryStep(cmd)
+}
+
+func (a *AdminApp) redo() {
+	if len(a.history.undone) == 0 {
+		return
+	}
+	cmd := a.history.undone[len(a.history.undone)-1]
+	if err := a.applyChanges(cmd.changes.Apply); err != nil {
+		dialog.ShowError(fmt.Errorf("не удалось повторить «%s»: %v", cmd.title, err), a.window)
+		return
+	}
+	a.history.undone = a.history.undone[:len(a.history.undone)-1]
+	a.history.done = append(a.history.done, cmd)
+	a.afterHistoryStep(cmd)
+}
+
+// afterHistoryStep обновляет окно после отмены или повтора операции.
+func (a *AdminApp) afterHistoryStep(cmd command) {
+	if cmd.changes.Tables()["settings"] {
+		a.loadAlphabet()
+		a.scheduleBackups()
+	}
+	a.updateHistory()
+	a.refreshAllTabs()
+}
+
+// clearHistory забывает все операции; вызывается, когда база заменена
+// целиком и записанные изменения к ней больше не относятся.
+func (a *AdminApp) clearHistory() {
+	a.history = history{lastID: a.history.lastID}
+	a.updateHistory()
+}
+
+// registerHistoryShortcuts назначает Ctrl+Z, Ctrl+Y и Ctrl+Shift+Z. Они
+// срабатывают, только если их не обработало поле ввода в фокусе, поэтому
+// отмена в тексте поля работает как обычно.
+func (a *AdminApp) registerHistoryShortcuts() {
+	canvas := a.window.Canvas()
+	canvas.AddShortcut(&fyne.ShortcutUndo{}, func(fyne.Shortcut) { a.undo() })
+	canvas.AddShortcut(&fyne.ShortcutRedo{}, func(fyne.Shortcut) { a.redo() })
+	canvas.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift},
+		func(fyne.Shortcut) { a.redo() })
+}
+
+// historyEntry возвращает строку панели истории: сверху отменённые
+// операции, начиная с последней, ниже - выполненные, тоже с последней.
+func (a *AdminApp) historyEntry(index int) (cmd command, undone bool) {
+	if index < len(a.history.undone) {
+		return a.history.undone[index], true
+	}
+	return a.history.done[len(a.history.done)-1-(index-len(a.history.undone))], false
+}
+
+func (a *AdminApp) createHistoryTab() fyne.CanvasObject {
+	selected := -1
+
+	a.historyList = widget.NewList(
+		func() int {
+			return len(a.history.done) + len(a.history.undone)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			cmd, undone := a.historyEntry(id)
+			text := fmt.Sprintf("%s  %s (изменено записей: %d)", cmd.at.Format("15:04:05"), cmd.title, len(cmd.changes))
+			label := item.(*widget.Label)
+			if undone {
+				label.SetText(text + " - отменено")
+				label.Importance = widget.LowImportance
+			} else {
+				label.SetText(text)
+				label.Importance = widget.MediumImportance
+			}
+			label.Refresh()
+		},
+	)
+	a.historyList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+	}
+
+	a.undoBtn = widget.NewButton("Отменить (Ctrl+Z)", a.undo)
+	a.redoBtn = widget.NewButton("Повторить (Ctrl+Y)", a.redo)
+
+	// Отменяет или повторяет операции, пока выбранная не станет последней
+	// выполненной; останавливается на первой ошибке
+	goToBtn := widget.NewButton("Вернуться к выбранной", func() {
+		if selected < 0 || selected >= len(a.history.done)+len(a.history.undone) {
+			dialog.ShowInformation("Внимание", "Выберите операцию в списке", a.window)
+			return
+		}
+		target, undone := a.historyEntry(selected)
+		if undone {
+			for len(a.history.undone) > 0 {
+				next := a.history.undone[len(a.history.undone)-1]
+				a.redo()
+				if next.id == target.id || a.lastDoneID() != next.id {
+					break
+				}
+			}
+		} else {
+			for len(a.history.done) > 0 && a.lastDoneID() != target.id {
+				count := len(a.history.done)
+				a.undo()
+				if len(a.history.done) == count {
+					break
+				}
+			}
+		}
+		a.historyList.UnselectAll()
+	})
+
+	a.updateHistory()
+
+	return container.NewBorder(
+		container.NewVBox(
+			widget.NewLabelWithStyle("История изменений", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabel(fmt.Sprintf("Последние %d операций этого окна; отменённые можно повторить, пока не выполнена новая операция.", historyLimit)),
+			container.NewHBox(a.undoBtn, a.redoBtn, goToBtn),
+		),
+		nil, nil, nil,
+		a.historyList,
+	)
+}
+
+// updateHistory обновляет панель истории и доступность кнопок.
+func (a *AdminApp) updateHistory() {
+	if a.historyList == nil {
+		return
+	}
+	a.historyList.Refresh()
+	if len(a.history.done) == 0 {
+		a.undoBtn.Disable()
+	} else {
+		a.undoBtn.Enable()
+	}
+	if len(a.history.undone) == 0 {
+		a.redoBtn.Disable()
+	} else {
+		a.redoBtn.Enable()
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"fmt"
 	"laba3/database"
+	"laba3/grapheme"
+	"laba3/rules"
 	"log"
 	"strings"
-	"unicode"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -17,10 +19,31 @@ import (
 )
 
 type AdminApp struct {
-	db           *sql.DB
-	window       fyne.Window
-	mainTabs     *container.AppTabs
-	matrixScroll *container.Scroll
+	db            *sql.DB
+	window        fyne.Window
+	mainTabs      *container.AppTabs
+	tabs          adminTabs
+	matrixScroll  *container.Scroll
+	alphabet      rules.Alphabet
+	backupTimer   *time.Timer
+	campaignTimer *time.Timer
+	history       history
+	historyList   *widget.List
+	undoBtn       *widget.Button
+	redoBtn       *widget.Button
+	staging       bool
+	pending       map[pendingKey]pendingChange
+	reviewBtn     *widget.Button
+	discardBtn    *widget.Button
+	admin         string
+}
+
+// adminTabs - вкладки, содержимое которых пересоздаётся при обновлении.
+// Обращение идёт по указателям, а не по номерам, чтобы порядок вкладок
+// можно было менять.
+type adminTabs struct {
+	users, rules, settings, sessions, snapshots *container.TabItem
+	approvals, accessRequests, campaigns        *container.TabItem
 }
 
 func NewAdminApp(db *sql.DB) *AdminApp {
@@ -32,14 +55,44 @@ func NewAdminApp(db *sql.DB) *AdminApp {
 		db:     db,
 		window: window,
 	}
+	adminApp.displayLogin()
+	return adminApp
+}
 
-	adminApp.mainTabs = container.NewAppTabs(
-		container.NewTabItem("Матрица доступа", adminApp.createMatrixTab()),
-		container.NewTabItem("Управление пользователями", adminApp.createUserManagementTab()),
+// start открывает вкладки админ-панели после входа администратора admin.
+func (a *AdminApp) start(admin string) {
+	a.admin = admin
+	a.window.SetTitle("Администратор системы доступа - " + admin)
+	a.loadAlphabet()
+
+	a.tabs = adminTabs{
+		users:          container.NewTabItem("Управление пользователями", a.createUserManagementTab()),
+		rules:          container.NewTabItem("Правила доступа", a.createRulesTab()),
+		settings:       container.NewTabItem("Настройки", a.createSettingsTab()),
+		sessions:       container.NewTabItem("Сеансы", a.createSessionsTab()),
+		snapshots:      container.NewTabItem("Снимки", a.createSnapshotsTab()),
+		approvals:      container.NewTabItem("Одобрения", a.createApprovalsTab()),
+		accessRequests: container.NewTabItem("Запросы доступа", a.createAccessRequestsTab()),
+		campaigns:      container.NewTabItem("Пересмотр прав", a.createCampaignsTab()),
+	}
+	a.mainTabs = container.NewAppTabs(
+		container.NewTabItem("Матрица доступа", a.createMatrixTab()),
+		a.tabs.users,
+		a.tabs.rules,
+		a.tabs.settings,
+		a.tabs.sessions,
+		a.tabs.snapshots,
+		container.NewTabItem("История", a.createHistoryTab()),
+		a.tabs.approvals,
+		a.tabs.accessRequests,
+		a.tabs.campaigns,
 	)
 
-	window.SetContent(adminApp.mainTabs)
-	return adminApp
+	a.window.SetContent(a.mainTabs)
+	a.window.SetMainMenu(a.createMainMenu())
+	a.registerHistoryShortcuts()
+	a.scheduleBackups()
+	a.closeDueCampaigns()
 }
 
 func (a *AdminApp) ShowAndRun() {
@@ -52,9 +105,12 @@ func (a *AdminApp) createMatrixTab() fyne.CanvasObject {
 	table := a.createUserTable()
 	a.matrixScroll = container.NewScroll(table)
 
+	legend := widget.NewLabel("✓ - право выдано явно, ✓~ - право на другой регистр буквы, ✓* - право по правилу, ✗ - нет доступа,\n" +
+		"✓⌛ - временное право, ✗→✓ и ✓→✗ - выдача и снятие права в черновике")
+
 	return container.NewBorder(
 		nil,
-		container.NewHBox(refreshBtn),
+		container.NewHBox(refreshBtn, widget.NewButton("Экспорт в CSV...", a.showCSVExportDialog), a.createStagingControls(), legend),
 		nil, nil,
 		a.matrixScroll,
 	)
@@ -75,9 +131,16 @@ func (a *AdminApp) createUserTable() *widget.Table {
 
 	log.Printf("Загружено пользователей: %d, букв: %d", len(users), len(letters))
 
+	policies, err := database.GetCasePolicies(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки политики регистра: %v", err)
+		policies = map[string]rules.CasePolicy{}
+	}
+	columns := a.matrixColumns(letters, policies)
+
 	table := widget.NewTable(
 		func() (int, int) {
-			return len(users) + 1, len(letters) + 1
+			return len(users) + 1, len(columns) + 1
 		},
 		func() fyne.CanvasObject {
 			return container.NewCenter(widget.NewLabel("---"))
@@ -90,8 +153,8 @@ func (a *AdminApp) createUserTable() *widget.Table {
 				label.SetText("Пользователь \\ Буква")
 				label.Importance = widget.HighImportance
 			} else if id.Row == 0 {
-				if id.Col-1 < len(letters) {
-					label.SetText(letters[id.Col-1])
+				if id.Col-1 < len(columns) {
+					label.SetText(columns[id.Col-1].title)
 					label.Importance = widget.HighImportance
 				} else {
 					label.SetText("")
@@ -104,9 +167,9 @@ func (a *AdminApp) createUserTable() *widget.Table {
 					label.SetText("")
 				}
 			} else {
-				if id.Row-1 < len(users) && id.Col-1 < len(letters) {
+				if id.Row-1 < len(users) && id.Col-1 < len(columns) {
 					userName := users[id.Row-1]
-					letter := letters[id.Col-1]
+					column := columns[id.Col-1]
 
 					userID, err := database.FindUser(a.db, userName)
 					if err != nil {
@@ -115,6 +178,17 @@ func (a *AdminApp) createUserTable() *widget.Table {
 						return
 					}
 
+					if change, ok := a.pending[pendingKey{userID: userID, column: column.title}]; ok {
+						if change.grant {
+							label.SetText("✗→✓")
+							label.Importance = widget.HighImportance
+						} else {
+							label.SetText("✓→✗")
+							label.Importance = widget.DangerImportance
+						}
+						return
+					}
+
 					permissions, err := database.GetPermissions(a.db, userID)
 					if err != nil {
 						label.SetText("❌")
@@ -122,17 +196,56 @@ func (a *AdminApp) createUserTable() *widget.Table {
 						return
 					}
 
-					hasAccess := false
+					if granted := column.granted(permissions); len(granted) > 0 {
+						label.SetText("✓")
+						label.Importance = widget.SuccessImportance
+						expiries, err := database.GetGrantExpiries(a.db, userID)
+						if err != nil {
+							log.Printf("Ошибка загрузки сроков прав: %v", err)
+							return
+						}
+						// Временным право считается, только если ни одна из
+						// букв столбца не выдана бессрочно
+						for _, letter := range granted {
+							if _, ok := expiries[letter]; !ok {
+								return
+							}
+						}
+						label.SetText("✓⌛")
+						return
+					}
+
+					var set rules.LetterSet
 					for _, perm := range permissions {
-						if perm == letter {
-							hasAccess = true
-							break
+						set.Add(perm, policies[perm])
+					}
+					for _, letter := range column.letters {
+						if set.Contains(letter) {
+							label.SetText("✓~")
+							label.Importance = widget.SuccessImportance
+							return
 						}
 					}
 
-					if hasAccess {
-						label.SetText("✓")
-						label.Importance = widget.SuccessImportance
+					accessRules, err := database.GetRules(a.db, userID)
+					if err != nil {
+						label.SetText("❌")
+						label.Importance = widget.DangerImportance
+						return
+					}
+
+					hasRule := false
+					for _, accessRule := range accessRules {
+						for _, letter := range column.letters {
+							if accessRule.Rule.Match(letter) {
+								hasRule = true
+							}
+						}
+					}
+
+					if hasRule {
+						label.SetText("✓*")
+						label.Importance = widget.MediumImportance
 					} else {
 						label.SetText("✗")
 						label.Importance = widget.WarningImportance
@@ -154,16 +267,9 @@ func (a *AdminApp) createUserTable() *widget.Table {
 				return
 			}
 
-			letters, err := database.GetAllLetters(a.db)
-			if err != nil {
-				dialog.ShowError(err, a.window)
-				return
-			}
-
-			if id.Row-1 < len(users) && id.Col-1 < len(letters) {
+			if id.Row-1 < len(users) && id.Col-1 < len(columns) {
 				userName := users[id.Row-1]
-				letter := letters[id.Col-1]
-				letterRune := []rune(letter)[0]
+				column := columns[id.Col-1]
 
 				userID, err := database.FindUser(a.db, userName)
 				if err != nil {
@@ -171,38 +277,45 @@ func (a *AdminApp) createUserTable() *widget.Table {
 					return
 				}
 
-				letterID, err := database.EnsureLetterExists(a.db, letterRune)
-				if err != nil {
-					dialog.ShowError(err, a.window)
-					return
-				}
-
 				permissions, err := database.GetPermissions(a.db, userID)
 				if err != nil {
 					dialog.ShowError(err, a.window)
 					return
 				}
 
-				hasAccess := false
-				for _, perm := range permissions {
-					if perm == letter {
-						hasAccess = true
-						break
-					}
+				// В объединённом столбце право снимается со всех регистров
+				// буквы, а выдаётся на первую из них.
+				granted := column.granted(permissions)
+				if a.staging {
+					a.togglePending(userID, userName, column, len(granted) == 0)
+					a.updateMatrixTable()
+					return
 				}
-
-				if hasAccess {
-					err = database.Remove(a.db, userID, letterID)
-					if err != nil {
-						dialog.ShowError(err, a.window)
-						return
-					}
+				if len(granted) > 0 {
+					err = a.execute(fmt.Sprintf("Снято право %s: %s", userName, column.title), func(tx *sql.Tx) error {
+						for _, letter := range granted {
+							letterID, err := database.GetLetterID(tx, letter)
+							if err != nil {
+								return err
+							}
+							if err := database.Remove(tx, userID, letterID); err != nil {
+								return err
+							}
+						}
+						return nil
+					})
 				} else {
-					err = database.Grant(a.db, userID, letterID)
-					if err != nil {
-						dialog.ShowError(err, a.window)
-						return
-					}
+					err = a.execute(fmt.Sprintf("Выдано право %s: %s", userName, column.title), func(tx *sql.Tx) error {
+						letterID, err := database.EnsureLetterExists(tx, column.letters[0])
+						if err != nil {
+							return err
+						}
+						return database.Grant(tx, userID, letterID)
+					})
+				}
+				if err != nil {
+					dialog.ShowError(err, a.window)
+					return
 				}
 
 				a.updateMatrixTable()
@@ -213,6 +326,54 @@ func (a *AdminApp) createUserTable() *widget.Table {
 	return table
 }
 
+// matrixColumn - столбец матрицы доступа: одна буква или, в режиме без
+// учёта регистра, все регистры одной буквы.
+type matrixColumn struct {
+	title   string
+	letters []string
+}
+
+// granted возвращает буквы столбца, на которые право выдано явно.
+func (c matrixColumn) granted(permissions []string) []string {
+	var granted []string
+	for _, letter := range c.letters {
+		for _, perm := range permissions {
+			if perm == letter {
+				granted = append(granted, letter)
+				break
+			}
+		}
+	}
+	return granted
+}
+
+// matrixColumns строит столбцы матрицы. Если глобально включён режим без
+// учёта регистра, буквы с этой политикой, отличающиеся только регистром,
+// объединяются в один столбец ("а/А").
+func (a *AdminApp) matrixColumns(letters []string, policies map[string]rules.CasePolicy) []matrixColumn {
+	global, err := database.GetCasePolicy(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки политики регистра: %v", err)
+		global = rules.CaseExact
+	}
+
+	var columns []matrixColumn
+	folded := make(map[string]int)
+	for _, letter := range letters {
+		if global == rules.CaseInsensitive && policies[letter] == rules.CaseInsensitive {
+			key := rules.FoldCase(letter)
+			if i, ok := folded[key]; ok {
+				columns[i].letters = append(columns[i].letters, letter)
+				columns[i].title += "/" + displayLetter(letter)
+				continue
+			}
+			folded[key] = len(columns)
+		}
+		columns = append(columns, matrixColumn{title: displayLetter(letter), letters: []string{letter}})
+	}
+	return columns
+}
+
 func (a *AdminApp) updateMatrixTable() {
 	if a.matrixScroll != nil {
 		newTable := a.createUserTable()
@@ -225,25 +386,30 @@ func (a *AdminApp) refreshMatrix() {
 	a.updateMatrixTable()
 }
 
-func validateLetters(input string) error {
-	for _, char := range input {
-		// Разрешаем пробелы, запятые и точки с запятой в качестве разделителей
-		if char != ' ' && char != ',' && char != ';' && !unicode.IsLetter(char) {
-			return fmt.Errorf("можно вводить только буквы и разделители (пробел, ',', ';')")
+func (a *AdminApp) loadAlphabet() {
+	alphabet, err := database.GetAlphabet(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки алфавита объектов: %v", err)
+		alphabet, _ = rules.ParseAlphabet(rules.DefaultAlphabet)
+	}
+	a.alphabet = alphabet
+}
+
+func (a *AdminApp) validateLetters(input string) error {
+	for _, letter := range parseLetters(input) {
+		if !a.alphabet.Allows(letter) {
+			return fmt.Errorf("символ '%s' не входит в алфавит объектов (%s)", displayLetter(letter), a.alphabet)
 		}
 	}
 	return nil
 }
 
-func validateSingleLetter(input string) error {
-	if len([]rune(input)) != 1 {
-		return fmt.Errorf("введите ровно один символ")
-	}
-	char := []rune(input)[0]
-	if !unicode.IsLetter(char) {
-		return fmt.Errorf("можно вводить только буквы")
+func (a *AdminApp) validateSingleLetter(input string) error {
+	letters := parseLetters(input)
+	if len(letters) != 1 {
+		return fmt.Errorf("введите ровно один символ (пробельные символы: \\s, \\t, \\n, \\r)")
 	}
-	return nil
+	return a.validateLetters(input)
 }
 
 func validateUserList(input string) error {
@@ -260,18 +426,71 @@ func validateUserList(input string) error {
 	return nil
 }
 
-// Вспомогательная функция для парсинга букв из строки
-func parseLetters(input string) []rune {
-	var letterRunes []rune
-	if input != "" {
-		for _, char := range input {
-			// Игнорируем разделители
-			if char != ' ' && char != ',' && char != ';' && char != '\n' && char != '\t' {
-				letterRunes = append(letterRunes, char)
+// Пробельные символы нельзя ввести через пробел, поэтому они задаются
+// экранированными последовательностями.
+var escapedLetters = map[string]string{
+	`\s`: " ",
+	`\t`: "\t",
+	`\n`: "\n",
+	`\r`: "\r",
+}
+
+// Вспомогательная функция для парсинга букв из строки. Символы - кластеры
+// графем в NFC - разделяются пробелами; запятая и точка с запятой внутри
+// группы символов считаются разделителями, а отдельно стоящие - самими
+// символами.
+func parseLetters(input string) []string {
+	var letters []string
+	for _, token := range strings.Fields(input) {
+		if letter, ok := escapedLetters[token]; ok {
+			letters = append(letters, letter)
+			continue
+		}
+
+		clusters := grapheme.Split(token)
+		if len(clusters) == 1 {
+			letters = append(letters, clusters[0])
+			continue
+		}
+		for _, cluster := range clusters {
+			if cluster != "," && cluster != ";" {
+				letters = append(letters, cluster)
 			}
 		}
 	}
-	return letterRunes
+	return letters
+}
+
+const letterCaseDefault = "По умолчанию (из настроек)"
+
+var letterCaseNames = map[rules.CasePolicy]string{
+	rules.CaseExact:       "Точное совпадение",
+	rules.CaseInsensitive: "Без учёта регистра",
+	rules.CaseUpper:       "Только заглавная",
+}
+
+// Отображаемые имена невидимых символов в матрице и списках.
+var letterDisplayNames = map[string]string{
+	" ":  "␠",
+	"\t": "⇥",
+	"\n": "↵",
+	"\r": "␍",
+}
+
+func displayLetter(letter string) string {
+	if name, ok := letterDisplayNames[letter]; ok {
+		return name
+	}
+	return letter
+}
+
+func letterFromDisplay(display string) string {
+	for letter, name := range letterDisplayNames {
+		if name == display {
+			return letter
+		}
+	}
+	return display
 }
 
 // Вспомогательная функция для парсинга пользователей из строки
@@ -302,10 +521,10 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 	})
 
 	lettersEntry := widget.NewEntry()
-	lettersEntry.SetPlaceHolder("Введите буквы через пробел (например: A B C или А Б В)\nМожно использовать русские и латинские буквы")
+	lettersEntry.SetPlaceHolder("Введите символы через пробел (например: A B C 1 2 ! или А Б В)\nПробельные символы: \\s, \\t, \\n, \\r")
 	lettersEntry.MultiLine = true
 	lettersEntry.Wrapping = fyne.TextWrapWord
-	lettersEntry.Validator = validation.NewAllStrings(validateLetters)
+	lettersEntry.Validator = validation.NewAllStrings(a.validateLetters)
 
 	addUserBtn := widget.NewButton("Добавить пользователя", func() {
 		if nameEntry.Validate() != nil {
@@ -313,14 +532,16 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 			return
 		}
 
-		if lettersEntry.Validate() != nil {
-			dialog.ShowError(fmt.Errorf("в поле букв можно вводить только буквы и пробелы"), a.window)
+		if err := lettersEntry.Validate(); err != nil {
+			dialog.ShowError(fmt.Errorf("неверный список символов: %v", err), a.window)
 			return
 		}
 
-		letterRunes := parseLetters(lettersEntry.Text)
+		letters := parseLetters(lettersEntry.Text)
 
-		err := database.Create(a.db, nameEntry.Text, letterRunes...)
+		err := a.execute("Добавлен пользователь "+nameEntry.Text, func(tx *sql.Tx) error {
+			return database.Create(tx, nameEntry.Text, letters...)
+		})
 		if err != nil {
 			dialog.ShowError(err, a.window)
 			return
@@ -341,8 +562,8 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 
 	// НОВОЕ ПОЛЕ для букв
 	bulkLettersEntry := widget.NewEntry()
-	bulkLettersEntry.SetPlaceHolder("Введите буквы через пробел (например: A B C)")
-	bulkLettersEntry.Validator = validation.NewAllStrings(validateLetters)
+	bulkLettersEntry.SetPlaceHolder("Введите символы через пробел (например: A B C 1 2)")
+	bulkLettersEntry.Validator = validation.NewAllStrings(a.validateLetters)
 
 	// ИЗМЕНЕННАЯ КНОПКА (Добавить/Выдать права)
 	bulkGrantAddBtn := widget.NewButton("Массово выдать права / Добавить", func() {
@@ -356,13 +577,13 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		}
 
 		users := parseUsers(bulkUserEntry.Text)
-		letterRunes := parseLetters(bulkLettersEntry.Text)
+		letters := parseLetters(bulkLettersEntry.Text)
 
 		if len(users) == 0 {
 			dialog.ShowInformation("Внимание", "Список пользователей пуст", a.window)
 			return
 		}
-		if len(letterRunes) == 0 {
+		if len(letters) == 0 {
 			dialog.ShowInformation("Внимание", "Список букв пуст. Права не будут выданы.", a.window)
 			// (Можно и продолжить, просто создав пользователей, но лучше уведомить)
 		}
@@ -370,39 +591,46 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		var createdCount, grantedCount int
 		var errorMessages []string
 
-		for _, user := range users {
-			userID, err := database.FindUser(a.db, user)
+		err := a.execute(fmt.Sprintf("Массовая выдача прав (пользователей: %d)", len(users)), func(tx *sql.Tx) error {
+			for _, user := range users {
+				userID, err := database.FindUser(tx, user)
 
-			if err != nil {
-				// Пользователь не найден -> Создаем
-				errCreate := database.Create(a.db, user, letterRunes...)
-				if errCreate != nil {
-					errorMessages = append(errorMessages, fmt.Sprintf("Ошибка создания '%s': %v", user, errCreate))
+				if err != nil {
+					// Пользователь не найден -> Создаем
+					errCreate := database.Create(tx, user, letters...)
+					if errCreate != nil {
+						errorMessages = append(errorMessages, fmt.Sprintf("Ошибка создания '%s': %v", user, errCreate))
+					} else {
+						createdCount++
+					}
 				} else {
-					createdCount++
-				}
-			} else {
-				// Пользователь найден -> Выдаем права
-				var grantedForUser bool
-				for _, r := range letterRunes {
-					letterID, errEnsure := database.EnsureLetterExists(a.db, r)
-					if errEnsure != nil {
-						errorMessages = append(errorMessages, fmt.Sprintf("Ошибка (EnsureLetter) для '%s': %v", user, errEnsure))
-						continue
+					// Пользователь найден -> Выдаем права
+					var grantedForUser bool
+					for _, letter := range letters {
+						letterID, errEnsure := database.EnsureLetterExists(tx, letter)
+						if errEnsure != nil {
+							errorMessages = append(errorMessages, fmt.Sprintf("Ошибка (EnsureLetter) для '%s': %v", user, errEnsure))
+							continue
+						}
+						errGrant := database.Grant(tx, userID, letterID)
+						if errGrant != nil {
+							// (database.Grant может возвращать ошибку, если право уже есть,
+							// в зависимости от реализации. Предполагаем, что он идемпотентен или игнорирует дубликаты)
+							// log.Printf("Ошибка выдачи права %s для %s: %v", letter, user, errGrant)
+						} else {
+							grantedForUser = true
+						}
 					}
-					errGrant := database.Grant(a.db, userID, letterID)
-					if errGrant != nil {
-						// (database.Grant может возвращать ошибку, если право уже есть,
-						// в зависимости от реализации. Предполагаем, что он идемпотентен или игнорирует дубликаты)
-						// log.Printf("Ошибка выдачи права %c для %s: %v", r, user, errGrant)
-					} else {
-						grantedForUser = true
+					if grantedForUser {
+						grantedCount++ // Считаем, что права выданы хотя бы одному
 					}
 				}
-				if grantedForUser {
-					grantedCount++ // Считаем, что права выданы хотя бы одному
-				}
 			}
+			return nil
+		})
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
 		}
 
 		msg := fmt.Sprintf("Операция завершена.\nСоздано новых пользователей: %d\nВыданы права (существующим): %d", createdCount, grantedCount)
@@ -426,13 +654,13 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		}
 
 		users := parseUsers(bulkUserEntry.Text)
-		letterRunes := parseLetters(bulkLettersEntry.Text)
+		letters := parseLetters(bulkLettersEntry.Text)
 
 		if len(users) == 0 {
 			dialog.ShowInformation("Внимание", "Список пользователей пуст", a.window)
 			return
 		}
-		if len(letterRunes) == 0 {
+		if len(letters) == 0 {
 			dialog.ShowInformation("Внимание", "Список букв пуст. Права не будут забраны.", a.window)
 			return
 		}
@@ -440,31 +668,38 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		var removedCount int
 		var errorMessages []string
 
-		for _, user := range users {
-			userID, err := database.FindUser(a.db, user)
-			if err != nil {
-				errorMessages = append(errorMessages, fmt.Sprintf("Ошибка: пользователь '%s' не найден.", user))
-				continue
-			}
-
-			var removedForUser bool
-			for _, r := range letterRunes {
-				letterID, errGetID := database.GetLetterID(a.db, r)
-				if errGetID != nil {
-					// Если буквы нет в БД, право на нее и так ни у кого нет, это не ошибка
+		err := a.execute(fmt.Sprintf("Массовое снятие прав (пользователей: %d)", len(users)), func(tx *sql.Tx) error {
+			for _, user := range users {
+				userID, err := database.FindUser(tx, user)
+				if err != nil {
+					errorMessages = append(errorMessages, fmt.Sprintf("Ошибка: пользователь '%s' не найден.", user))
 					continue
 				}
 
-				errRemove := database.Remove(a.db, userID, letterID)
-				if errRemove != nil {
-					// log.Printf("Ошибка удаления права %c у %s: %v", r, user, errRemove)
-				} else {
-					removedForUser = true
+				var removedForUser bool
+				for _, letter := range letters {
+					letterID, errGetID := database.GetLetterID(tx, letter)
+					if errGetID != nil {
+						// Если буквы нет в БД, право на нее и так ни у кого нет, это не ошибка
+						continue
+					}
+
+					errRemove := database.Remove(tx, userID, letterID)
+					if errRemove != nil {
+						// log.Printf("Ошибка удаления права %s у %s: %v", letter, user, errRemove)
+					} else {
+						removedForUser = true
+					}
+				}
+				if removedForUser {
+					removedCount++
 				}
 			}
-			if removedForUser {
-				removedCount++
-			}
+			return nil
+		})
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
 		}
 
 		msg := fmt.Sprintf("Операция завершена.\nОбработано пользователей (у кого забраны права): %d", removedCount)
@@ -497,19 +732,52 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 					var successCount int
 					var errorMessages []string
 
-					for _, user := range usersToDelete {
-						userID, err := database.FindUser(a.db, user)
-						if err != nil {
-							errorMessages = append(errorMessages, fmt.Sprintf("Ошибка поиска ID для '%s': %v", user, err))
-							continue
+					required, err := database.GetApprovalRequired(a.db)
+					if err != nil {
+						dialog.ShowError(err, a.window)
+						return
+					}
+					if required {
+						for _, user := range usersToDelete {
+							userID, err := database.FindUser(a.db, user)
+							if err == nil {
+								_, err = database.RequestChange(a.db, database.OpDeleteUser, userID, user, a.admin)
+							}
+							if err != nil {
+								errorMessages = append(errorMessages, fmt.Sprintf("'%s': %v", user, err))
+							} else {
+								successCount++
+							}
 						}
-
-						err = database.DeleteUser(a.db, userID)
-						if err != nil {
-							errorMessages = append(errorMessages, fmt.Sprintf("Ошибка удаления '%s': %v", user, err))
+						a.refreshApprovals()
+						if len(errorMessages) > 0 {
+							dialog.ShowError(fmt.Errorf("создано запросов на удаление: %d. Ошибки: \n%s", successCount, strings.Join(errorMessages, "\n")), a.window)
 						} else {
-							successCount++
+							a.showRequested(successCount)
 						}
+						return
+					}
+
+					err = a.execute(fmt.Sprintf("Массовое удаление пользователей (%d)", len(usersToDelete)), func(tx *sql.Tx) error {
+						for _, user := range usersToDelete {
+							userID, err := database.FindUser(tx, user)
+							if err != nil {
+								errorMessages = append(errorMessages, fmt.Sprintf("Ошибка поиска ID для '%s': %v", user, err))
+								continue
+							}
+
+							err = database.DeleteUser(tx, userID)
+							if err != nil {
+								errorMessages = append(errorMessages, fmt.Sprintf("Ошибка удаления '%s': %v", user, err))
+							} else {
+								successCount++
+							}
+						}
+						return nil
+					})
+					if err != nil {
+						dialog.ShowError(err, a.window)
+						return
 					}
 
 					if successCount > 0 {
@@ -549,7 +817,11 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 			log.Printf("Ошибка обновления списка букв: %v", err)
 			letters = []string{}
 		}
-		letterSelect.Options = letters
+		options := make([]string, len(letters))
+		for i, letter := range letters {
+			options[i] = displayLetter(letter)
+		}
+		letterSelect.Options = options
 		letterSelect.Refresh()
 	}
 
@@ -566,11 +838,17 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 			dialog.ShowError(err, a.window)
 			return
 		}
-		err = database.GrantAll(a.db, userID)
+		requested, err := a.privileged(database.OpGrantAll, userID, userSelect.Selected, func(tx *sql.Tx) error {
+			return database.GrantAll(tx, userID)
+		})
 		if err != nil {
 			dialog.ShowError(err, a.window)
 			return
 		}
+		if requested {
+			a.showRequested(1)
+			return
+		}
 		dialog.ShowInformation("Успех", "Все права выданы", a.window)
 		a.refreshAllTabs()
 	})
@@ -585,7 +863,9 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 			dialog.ShowError(err, a.window)
 			return
 		}
-		err = database.RemoveAll(a.db, userID)
+		err = a.execute("Сняты все права "+userSelect.Selected, func(tx *sql.Tx) error {
+			return database.RemoveAll(tx, userID)
+		})
 		if err != nil {
 			dialog.ShowError(err, a.window)
 			return
@@ -608,11 +888,17 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 						dialog.ShowError(err, a.window)
 						return
 					}
-					err = database.DeleteUser(a.db, userID)
+					requested, err := a.privileged(database.OpDeleteUser, userID, userSelect.Selected, func(tx *sql.Tx) error {
+						return database.DeleteUser(tx, userID)
+					})
 					if err != nil {
 						dialog.ShowError(err, a.window)
 						return
 					}
+					if requested {
+						a.showRequested(1)
+						return
+					}
 					dialog.ShowInformation("Успех", "Пользователь удален", a.window)
 					updateUserList()
 					a.refreshAllTabs()
@@ -651,7 +937,9 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 						dialog.ShowError(err, a.window)
 						return
 					}
-					err = database.UpdateUserName(a.db, userID, newNameEntry.Text)
+					err = a.execute(fmt.Sprintf("Пользователь %s переименован в %s", userSelect.Selected, newNameEntry.Text), func(tx *sql.Tx) error {
+						return database.UpdateUserName(tx, userID, newNameEntry.Text)
+					})
 					if err != nil {
 						dialog.ShowError(err, a.window)
 						return
@@ -666,6 +954,54 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		form.Show()
 	})
 
+	passwordBtn := widget.NewButton("Задать пароль", func() {
+		if userSelect.Selected == "" {
+			dialog.ShowInformation("Внимание", "Выберите пользователя", a.window)
+			return
+		}
+		passwordEntry := widget.NewPasswordEntry()
+		passwordEntry.SetPlaceHolder("Пустой пароль - вход без пароля")
+		confirmEntry := widget.NewPasswordEntry()
+
+		form := dialog.NewForm(
+			fmt.Sprintf("Пароль пользователя %s", userSelect.Selected),
+			"Сохранить",
+			"Отмена",
+			[]*widget.FormItem{
+				{Text: "Пароль", Widget: passwordEntry},
+				{Text: "Повторите пароль", Widget: confirmEntry},
+			},
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				if passwordEntry.Text != confirmEntry.Text {
+					dialog.ShowError(fmt.Errorf("пароли не совпадают"), a.window)
+					return
+				}
+				userID, err := database.FindUser(a.db, userSelect.Selected)
+				if err != nil {
+					dialog.ShowError(err, a.window)
+					return
+				}
+				err = a.execute("Изменён пароль "+userSelect.Selected, func(tx *sql.Tx) error {
+					return database.SetPassword(tx, userID, passwordEntry.Text)
+				})
+				if err != nil {
+					dialog.ShowError(err, a.window)
+					return
+				}
+				if passwordEntry.Text == "" {
+					dialog.ShowInformation("Успех", "Пароль снят", a.window)
+				} else {
+					dialog.ShowInformation("Успех", "Пароль задан", a.window)
+				}
+			},
+			a.window,
+		)
+		form.Show()
+	})
+
 	// НОВАЯ КНОПКА - Переименовать букву
 	renameLetterBtn := widget.NewButton("Переименовать", func() {
 		if letterSelect.Selected == "" {
@@ -674,11 +1010,11 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		}
 
 		oldLetterStr := letterSelect.Selected
-		oldLetterRune := []rune(oldLetterStr)[0]
+		oldLetter := letterFromDisplay(oldLetterStr)
 
 		newLetterEntry := widget.NewEntry()
-		newLetterEntry.SetPlaceHolder("Введите новую букву")
-		newLetterEntry.Validator = validation.NewAllStrings(validateSingleLetter) // Используем существующий валидатор
+		newLetterEntry.SetPlaceHolder("Введите новый символ")
+		newLetterEntry.Validator = validation.NewAllStrings(a.validateSingleLetter) // Используем существующий валидатор
 
 		form := dialog.NewForm(
 			fmt.Sprintf("Переименовать '%s'", oldLetterStr),
@@ -690,14 +1026,14 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 			func(confirmed bool) {
 				if confirmed {
 					if newLetterEntry.Validate() != nil {
-						dialog.ShowError(fmt.Errorf("неверный ввод: введите одну букву"), a.window)
+						dialog.ShowError(fmt.Errorf("неверный ввод: %v", newLetterEntry.Validate()), a.window)
 						return
 					}
 
-					newLetterRune := []rune(newLetterEntry.Text)[0]
+					newLetter := parseLetters(newLetterEntry.Text)[0]
 
 					// 1. Получаем ID старой буквы
-					oldLetterID, err := database.GetLetterID(a.db, oldLetterRune)
+					oldLetterID, err := database.GetLetterID(a.db, oldLetter)
 					if err != nil {
 						dialog.ShowError(fmt.Errorf("не удалось найти ID для старой буквы: %v", err), a.window)
 						return
@@ -705,14 +1041,16 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 
 					// 2. Вызываем новую функцию в database (ее нужно будет создать)
 					// Эта функция должна сама проверить уникальность новой буквы
-					err = database.UpdateLetter(a.db, oldLetterID, newLetterRune)
+					err = a.execute(fmt.Sprintf("Буква %s переименована в %s", oldLetterStr, displayLetter(newLetter)), func(tx *sql.Tx) error {
+						return database.UpdateLetter(tx, oldLetterID, newLetter)
+					})
 					if err != nil {
 						// Ошибка сработает, если буква уже существует или другая проблема
 						dialog.ShowError(err, a.window)
 						return
 					}
 
-					dialog.ShowInformation("Успех", fmt.Sprintf("Буква '%s' успешно переименована в '%c'", oldLetterStr, newLetterRune), a.window)
+					dialog.ShowInformation("Успех", fmt.Sprintf("Буква '%s' успешно переименована в '%s'", oldLetterStr, displayLetter(newLetter)), a.window)
 					updateLetterList()
 					a.refreshAllTabs()
 				}
@@ -731,17 +1069,22 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 			fmt.Sprintf("Вы уверены, что хотите удалить букву %s?\nЭто удалит все права доступа к этой букве у всех пользователей.", letterSelect.Selected),
 			func(confirmed bool) {
 				if confirmed {
-					letterRune := []rune(letterSelect.Selected)[0]
-					letterID, err := database.GetLetterID(a.db, letterRune)
+					letterID, err := database.GetLetterID(a.db, letterFromDisplay(letterSelect.Selected))
 					if err != nil {
 						dialog.ShowError(err, a.window)
 						return
 					}
-					err = database.DeleteLetter(a.db, letterID)
+					requested, err := a.privileged(database.OpDeleteLetter, letterID, letterSelect.Selected, func(tx *sql.Tx) error {
+						return database.DeleteLetter(tx, letterID)
+					})
 					if err != nil {
 						dialog.ShowError(err, a.window)
 						return
 					}
+					if requested {
+						a.showRequested(1)
+						return
+					}
 					dialog.ShowInformation("Успех", "Буква удалена", a.window)
 					updateLetterList()
 					a.refreshAllTabs()
@@ -750,17 +1093,70 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		confirm.Show()
 	})
 
+	// Политика регистра отдельной буквы
+	letterCaseOptions := []string{letterCaseDefault}
+	for _, policy := range rules.CasePolicies {
+		letterCaseOptions = append(letterCaseOptions, letterCaseNames[policy])
+	}
+	letterCaseSelect := widget.NewSelect(letterCaseOptions, nil)
+	letterSelect.OnChanged = func(selected string) {
+		letterID, err := database.GetLetterID(a.db, letterFromDisplay(selected))
+		if err != nil {
+			return
+		}
+		policy, err := database.GetLetterCasePolicy(a.db, letterID)
+		if err != nil {
+			log.Printf("Ошибка загрузки политики регистра: %v", err)
+			return
+		}
+		if policy == "" {
+			letterCaseSelect.SetSelected(letterCaseDefault)
+		} else {
+			letterCaseSelect.SetSelected(letterCaseNames[policy])
+		}
+	}
+
+	setLetterCaseBtn := widget.NewButton("Задать регистр", func() {
+		if letterSelect.Selected == "" || letterCaseSelect.Selected == "" {
+			dialog.ShowInformation("Внимание", "Выберите букву и политику регистра", a.window)
+			return
+		}
+		letterID, err := database.GetLetterID(a.db, letterFromDisplay(letterSelect.Selected))
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+
+		var policy rules.CasePolicy
+		for p, name := range letterCaseNames {
+			if name == letterCaseSelect.Selected {
+				policy = p
+			}
+		}
+		err = a.execute(fmt.Sprintf("Регистр буквы %s: %s", letterSelect.Selected, letterCaseSelect.Selected), func(tx *sql.Tx) error {
+			return database.SetLetterCasePolicy(tx, letterID, policy)
+		})
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.updateMatrixTable()
+	})
+
 	addLetterEntry := widget.NewEntry()
-	addLetterEntry.SetPlaceHolder("Введите букву для добавления (русскую или латинскую)")
-	addLetterEntry.Validator = validation.NewAllStrings(validateSingleLetter)
+	addLetterEntry.SetPlaceHolder("Введите символ для добавления (пробельные: \\s, \\t, \\n, \\r)")
+	addLetterEntry.Validator = validation.NewAllStrings(a.validateSingleLetter)
 
 	addLetterBtn := widget.NewButton("Добавить букву", func() {
-		if addLetterEntry.Validate() != nil {
-			dialog.ShowError(fmt.Errorf("введите одну букву (русскую или латинскую)"), a.window)
+		if err := addLetterEntry.Validate(); err != nil {
+			dialog.ShowError(err, a.window)
 			return
 		}
-		letterRune := []rune(addLetterEntry.Text)[0]
-		_, err := database.EnsureLetterExists(a.db, letterRune)
+		letter := parseLetters(addLetterEntry.Text)[0]
+		err := a.execute("Добавлена буква "+displayLetter(letter), func(tx *sql.Tx) error {
+			_, err := database.EnsureLetterExists(tx, letter)
+			return err
+		})
 		if err != nil {
 			dialog.ShowError(err, a.window)
 			return
@@ -781,7 +1177,7 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		widget.NewLabelWithStyle("Добавить одного пользователя", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		widget.NewLabel("Имя пользователя:"),
 		nameEntry,
-		widget.NewLabel("Буквы доступа (через пробел):"),
+		widget.NewLabel("Символы доступа (через пробел):"),
 		container.NewBorder(nil, nil, nil, nil, lettersEntry),
 		addUserBtn,
 	)
@@ -790,9 +1186,10 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		widget.NewLabelWithStyle("Массовое управление пользователями", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		widget.NewLabel("Список имен (каждое с новой строки):"),
 		container.NewBorder(nil, nil, nil, nil, bulkUserEntry),
-		widget.NewLabel("Список букв (через пробел):"),
+		widget.NewLabel("Список символов (через пробел):"),
 		bulkLettersEntry,
 		container.NewHBox(bulkGrantAddBtn, bulkRemoveRightsBtn),
+		widget.NewButton("Импорт из CSV...", a.showCSVImportDialog),
 		widget.NewSeparator(),
 		bulkDeleteBtn,
 	)
@@ -809,6 +1206,7 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		userSelect,
 		container.NewHBox(grantAllBtn, removeAllBtn),
 		container.NewHBox(editUserBtn, deleteUserBtn),
+		passwordBtn,
 		widget.NewSeparator(),
 	)
 
@@ -822,6 +1220,8 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 		widget.NewLabel("Управление существующими буквами:"), // ИЗМЕНЕНО: Заголовок
 		letterSelect,
 		container.NewHBox(renameLetterBtn, deleteLetterBtn), // ИЗМЕНЕНО: Кнопки в HBox
+		widget.NewLabel("Регистр выбранной буквы:"),
+		container.NewBorder(nil, nil, nil, setLetterCaseBtn, letterCaseSelect),
 		widget.NewSeparator(),
 	)
 
@@ -839,8 +1239,14 @@ func (a *AdminApp) createUserManagementTab() fyne.CanvasObject {
 
 func (a *AdminApp) refreshAllTabs() {
 	a.updateMatrixTable()
-	userManagementTab := a.createUserManagementTab()
-	a.mainTabs.Items[1].Content = userManagementTab
+	a.tabs.users.Content = a.createUserManagementTab()
+	a.tabs.rules.Content = a.createRulesTab()
+	a.tabs.settings.Content = a.createSettingsTab()
+	a.tabs.sessions.Content = a.createSessionsTab()
+	a.tabs.snapshots.Content = a.createSnapshotsTab()
+	a.tabs.approvals.Content = a.createApprovalsTab()
+	a.tabs.accessRequests.Content = a.createAccessRequestsTab()
+	a.tabs.campaigns.Content = a.createCampaignsTab()
 	a.mainTabs.Refresh()
 }
 
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+	"laba3/rules"
+	"log"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const rulesHelp = `Правило - набор условий через пробел, все условия должны выполняться:
+  script:Cyrillic category:lower - строчные кириллические буквы
+  category:digit - цифры (также letter, upper, lower, punct, symbol, space, mark или Lu, Nd, P...)
+  range:a-z или range:U+0430-U+044F - диапазон символов
+  regex:[aeiou] - регулярное выражение (должно быть последним условием)
+  !category:upper - отрицание условия`
+
+func validateRule(input string) error {
+	_, err := rules.Parse(input)
+	return err
+}
+
+func (a *AdminApp) createRulesTab() fyne.CanvasObject {
+	var userRules []database.AccessRule
+	selectedRule := -1
+
+	rulesList := widget.NewList(
+		func() int {
+			return len(userRules)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			item.(*widget.Label).SetText(userRules[id].Rule.String())
+		},
+	)
+	rulesList.OnSelected = func(id widget.ListItemID) {
+		selectedRule = id
+	}
+
+	preview := widget.NewLabel("")
+	preview.Wrapping = fyne.TextWrapWord
+
+	userSelect := widget.NewSelect([]string{}, nil)
+
+	loadRules := func() {
+		userRules = nil
+		selectedRule = -1
+		rulesList.UnselectAll()
+
+		if userSelect.Selected != "" {
+			userID, err := database.FindUser(a.db, userSelect.Selected)
+			if err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			userRules, err = database.GetRules(a.db, userID)
+			if err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+		}
+		rulesList.Refresh()
+	}
+	userSelect.OnChanged = func(string) {
+		loadRules()
+	}
+
+	users, err := database.GetAllUsers(a.db)
+	if err != nil {
+		log.Printf("Ошибка обновления списка пользователей: %v", err)
+		users = []string{}
+	}
+	userSelect.Options = users
+
+	ruleEntry := widget.NewEntry()
+	ruleEntry.SetPlaceHolder("Например: script:Cyrillic category:lower")
+	ruleEntry.Validator = validation.NewAllStrings(validateRule)
+	ruleEntry.OnChanged = func(text string) {
+		rule, err := rules.Parse(text)
+		if err != nil {
+			preview.SetText("")
+			return
+		}
+
+		letters, err := database.GetAllLetters(a.db)
+		if err != nil {
+			preview.SetText(fmt.Sprintf("Ошибка получения букв: %v", err))
+			return
+		}
+
+		var matched []string
+		for _, letter := range letters {
+			if rule.Match(letter) {
+				matched = append(matched, letter)
+			}
+		}
+		preview.SetText(fmt.Sprintf("Из существующих букв под правило попадают (%d): %s", len(matched), strings.Join(matched, " ")))
+	}
+
+	addRuleBtn := widget.NewButton("Добавить правило", func() {
+		if userSelect.Selected == "" {
+			dialog.ShowInformation("Внимание", "Выберите пользователя", a.window)
+			return
+		}
+		if err := ruleEntry.Validate(); err != nil {
+			dialog.ShowError(fmt.Errorf("неверное правило: %v", err), a.window)
+			return
+		}
+
+		userID, err := database.FindUser(a.db, userSelect.Selected)
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		err = a.execute(fmt.Sprintf("Добавлено правило %s: %s", userSelect.Selected, ruleEntry.Text), func(tx *sql.Tx) error {
+			return database.AddRule(tx, userID, ruleEntry.Text)
+		})
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+
+		ruleEntry.SetText("")
+		loadRules()
+		a.updateMatrixTable()
+	})
+	addRuleBtn.Importance = widget.HighImportance
+
+	removeRuleBtn := widget.NewButton("Удалить выбранное правило", func() {
+		if selectedRule < 0 || selectedRule >= len(userRules) {
+			dialog.ShowInformation("Внимание", "Выберите правило в списке", a.window)
+			return
+		}
+		rule := userRules[selectedRule]
+		err := a.execute(fmt.Sprintf("Удалено правило %s: %s", userSelect.Selected, rule.Rule), func(tx *sql.Tx) error {
+			return database.RemoveRule(tx, rule.ID)
+		})
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		loadRules()
+		a.updateMatrixTable()
+	})
+
+	form := container.NewVBox(
+		widget.NewLabelWithStyle("Правила доступа к классам символов", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Пользователь:"),
+		userSelect,
+		widget.NewLabel("Новое правило:"),
+		ruleEntry,
+		preview,
+		container.NewHBox(addRuleBtn, removeRuleBtn),
+		widget.NewSeparator(),
+		widget.NewLabel(rulesHelp),
+		widget.NewSeparator(),
+		widget.NewLabel("Правила выбранного пользователя:"),
+	)
+
+	return container.NewBorder(form, nil, nil, nil, rulesList)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"laba3/database"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const sessionTimeLayout = "02.01.2006 15:04:05"
+
+func (a *AdminApp) createSessionsTab() fyne.CanvasObject {
+	var sessions []database.Session
+	selected := -1
+
+	sessionsList := widget.NewList(
+		func() int {
+			return len(sessions)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			session := sessions[id]
+			item.(*widget.Label).SetText(fmt.Sprintf("%s - вход %s, активность %s, хост %s, процесс %d",
+				session.UserName,
+				session.LoginAt.Format(sessionTimeLayout),
+				session.LastActive.Format(sessionTimeLayout),
+				session.Host,
+				session.PID,
+			))
+		},
+	)
+	sessionsList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+	}
+
+	loadSessions := func() {
+		var err error
+		sessions, err = database.GetActiveSessions(a.db)
+		if err != nil {
+			log.Printf("Ошибка получения сеансов: %v", err)
+			sessions = nil
+		}
+		selected = -1
+		sessionsList.UnselectAll()
+		sessionsList.Refresh()
+	}
+	loadSessions()
+
+	terminateBtn := widget.NewButton("Завершить сеанс", func() {
+		if selected < 0 || selected >= len(sessions) {
+			dialog.ShowInformation("Внимание", "Выберите сеанс в списке", a.window)
+			return
+		}
+		session := sessions[selected]
+		dialog.ShowConfirm("Подтверждение",
+			fmt.Sprintf("Завершить сеанс пользователя %s на %s?", session.UserName, session.Host),
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				if err := database.TerminateSession(a.db, session.ID); err != nil {
+					dialog.ShowError(err, a.window)
+					return
+				}
+				loadSessions()
+			}, a.window)
+	})
+	terminateBtn.Importance = widget.DangerImportance
+
+	refreshBtn := widget.NewButton("Обновить", loadSessions)
+
+	header := container.NewVBox(
+		widget.NewLabelWithStyle("Активные сеансы приложения пользователя", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Завершённый сеанс закрывается в приложении пользователя при следующем обновлении прав."),
+	)
+
+	return container.NewBorder(header, container.NewHBox(refreshBtn, terminateBtn), nil, nil, sessionsList)
+}
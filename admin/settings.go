@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+	"laba3/rules"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+var alphabetClassNames = map[string]string{
+	rules.ClassLetters: "Буквы",
+	rules.ClassDigits:  "Цифры",
+	rules.ClassPunct:   "Знаки препинания",
+	rules.ClassSymbols: "Символы (математические, валютные и т.д.)",
+	rules.ClassEmoji:   "Эмодзи",
+	rules.ClassSpace:   "Пробельные символы",
+}
+
+var whitespacePolicyNames = map[rules.WhitespacePolicy]string{
+	rules.WhitespaceNone:     "Проверять наравне с остальными символами",
+	rules.WhitespaceStandard: "Пропускать пробел, перевод строки, табуляцию и возврат каретки",
+	rules.WhitespaceAll:      "Пропускать все пробельные символы Unicode",
+}
+
+var casePolicyNames = map[rules.CasePolicy]string{
+	rules.CaseExact:       "Точное совпадение: право на \"а\" не разрешает \"А\"",
+	rules.CaseInsensitive: "Без учёта регистра: право на \"а\" разрешает и \"А\"",
+	rules.CaseUpper:       "Только заглавные: право на \"а\" разрешает только \"А\"",
+}
+
+func (a *AdminApp) createSettingsTab() fyne.CanvasObject {
+	classOptions := make([]string, len(rules.AlphabetClasses))
+	for i, class := range rules.AlphabetClasses {
+		classOptions[i] = alphabetClassNames[class]
+	}
+
+	alphabetGroup := widget.NewCheckGroup(classOptions, nil)
+	for _, class := range a.alphabet.Classes() {
+		alphabetGroup.Selected = append(alphabetGroup.Selected, alphabetClassNames[class])
+	}
+
+	policyOptions := make([]string, len(rules.WhitespacePolicies))
+	for i, policy := range rules.WhitespacePolicies {
+		policyOptions[i] = whitespacePolicyNames[policy]
+	}
+	whitespaceSelect := widget.NewRadioGroup(policyOptions, nil)
+
+	whitespace, err := database.GetWhitespacePolicy(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки политики пробельных символов: %v", err)
+		whitespace = rules.WhitespaceStandard
+	}
+	whitespaceSelect.SetSelected(whitespacePolicyNames[whitespace])
+
+	caseOptions := make([]string, len(rules.CasePolicies))
+	for i, policy := range rules.CasePolicies {
+		caseOptions[i] = casePolicyNames[policy]
+	}
+	caseSelect := widget.NewRadioGroup(caseOptions, nil)
+
+	casePolicy, err := database.GetCasePolicy(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки политики регистра: %v", err)
+		casePolicy = rules.CaseExact
+	}
+	caseSelect.SetSelected(casePolicyNames[casePolicy])
+
+	idleTimeout, err := database.GetIdleTimeout(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки времени бездействия: %v", err)
+		idleTimeout = database.DefaultIdleTimeout
+	}
+	idleEntry := widget.NewEntry()
+	idleEntry.SetText(strconv.Itoa(int(idleTimeout.Minutes())))
+	idleEntry.Validator = func(text string) error {
+		if minutes, err := strconv.Atoi(text); err != nil || minutes < 0 {
+			return fmt.Errorf("укажите целое число минут, 0 - без ограничения")
+		}
+		return nil
+	}
+
+	schedule, err := database.GetBackupSchedule(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки расписания резервных копий: %v", err)
+		schedule = database.BackupSchedule{Dir: "backups", Keep: database.DefaultBackupKeep}
+	}
+	backupDirEntry := widget.NewEntry()
+	backupDirEntry.SetText(schedule.Dir)
+	backupDirEntry.Validator = func(text string) error {
+		if strings.TrimSpace(text) == "" {
+			return fmt.Errorf("укажите каталог для резервных копий")
+		}
+		return nil
+	}
+	backupIntervalEntry := widget.NewEntry()
+	backupIntervalEntry.SetText(strconv.Itoa(int(schedule.Interval.Minutes())))
+	backupIntervalEntry.Validator = func(text string) error {
+		if minutes, err := strconv.Atoi(text); err != nil || minutes < 0 {
+			return fmt.Errorf("укажите целое число минут, 0 - плановые копии отключены")
+		}
+		return nil
+	}
+	backupKeepEntry := widget.NewEntry()
+	backupKeepEntry.SetText(strconv.Itoa(schedule.Keep))
+	backupKeepEntry.Validator = func(text string) error {
+		if keep, err := strconv.Atoi(text); err != nil || keep < 1 {
+			return fmt.Errorf("укажите число копий не меньше 1")
+		}
+		return nil
+	}
+
+	approvalRequired, err := database.GetApprovalRequired(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки настройки двойного одобрения: %v", err)
+	}
+	approvalCheck := widget.NewCheck("Выдача всех прав, удаление пользователей и букв и отключение этой настройки - только после одобрения другим администратором", nil)
+	approvalCheck.SetChecked(approvalRequired)
+
+	saveBtn := widget.NewButton("Сохранить настройки", func() {
+		for _, entry := range []*widget.Entry{idleEntry, backupDirEntry, backupIntervalEntry, backupKeepEntry} {
+			if err := entry.Validate(); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+		}
+
+		var classes []string
+		for _, class := range rules.AlphabetClasses {
+			for _, selected := range alphabetGroup.Selected {
+				if selected == alphabetClassNames[class] {
+					classes = append(classes, class)
+				}
+			}
+		}
+		if len(classes) == 0 {
+			dialog.ShowInformation("Внимание", "Выберите хотя бы один класс символов", a.window)
+			return
+		}
+
+		alphabet, err := rules.ParseAlphabet(strings.Join(classes, ","))
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		interval, _ := strconv.Atoi(backupIntervalEntry.Text)
+		keep, _ := strconv.Atoi(backupKeepEntry.Text)
+		minutes, _ := strconv.Atoi(idleEntry.Text)
+		// Отключение двойного одобрения само требует одобрения: вместо
+		// изменения настройки создаётся запрос
+		requested := false
+		err = a.execute("Изменены настройки", func(tx *sql.Tx) error {
+			if err := database.SetAlphabet(tx, alphabet); err != nil {
+				return err
+			}
+			for _, policy := range rules.WhitespacePolicies {
+				if whitespacePolicyNames[policy] == whitespaceSelect.Selected {
+					if err := database.SetWhitespacePolicy(tx, policy); err != nil {
+						return err
+					}
+				}
+			}
+			for _, policy := range rules.CasePolicies {
+				if casePolicyNames[policy] == caseSelect.Selected {
+					if err := database.SetCasePolicy(tx, policy); err != nil {
+						return err
+					}
+				}
+			}
+			if err := database.SetIdleTimeout(tx, time.Duration(minutes)*time.Minute); err != nil {
+				return err
+			}
+			required, err := database.GetApprovalRequired(tx)
+			if err != nil {
+				return err
+			}
+			if required && !approvalCheck.Checked {
+				if _, err := database.RequestChange(tx, database.OpDisableApproval, 0, database.SettingApproval, a.admin); err != nil {
+					return err
+				}
+				requested = true
+			} else if err := database.SetApprovalRequired(tx, approvalCheck.Checked); err != nil {
+				return err
+			}
+			return database.SetBackupSchedule(tx, database.BackupSchedule{
+				Dir:      strings.TrimSpace(backupDirEntry.Text),
+				Interval: time.Duration(interval) * time.Minute,
+				Keep:     keep,
+			})
+		})
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.scheduleBackups()
+
+		a.loadAlphabet()
+		a.refreshAllTabs()
+		if requested {
+			a.showRequested(1)
+			return
+		}
+		dialog.ShowInformation("Успех", fmt.Sprintf("Настройки сохранены. Алфавит объектов: %s", a.alphabet), a.window)
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("Алфавит объектов", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Классы символов, которые можно добавлять и выдавать пользователям:"),
+		alphabetGroup,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Пробельные символы в приложении пользователя", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		whitespaceSelect,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Регистр букв", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Политика по умолчанию; для отдельной буквы её можно изменить на вкладке управления:"),
+		caseSelect,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Сеансы", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Завершать сеанс пользователя после бездействия, минут (0 - не завершать):"),
+		idleEntry,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Двойное одобрение", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		approvalCheck,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Плановые резервные копии", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewForm(
+			widget.NewFormItem("Каталог", backupDirEntry),
+			widget.NewFormItem("Интервал, минут (0 - отключено)", backupIntervalEntry),
+			widget.NewFormItem("Хранить копий", backupKeepEntry),
+		),
+		widget.NewSeparator(),
+		saveBtn,
+		widget.NewSeparator(),
+		a.createConfigTransfer(),
+	)
+
+	return container.NewScroll(content)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+	"log"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// snapshotNow - пункт списков сравнения, означающий текущее состояние.
+const snapshotNow = "Сейчас"
+
+func snapshotTitle(snapshot database.Snapshot) string {
+	return fmt.Sprintf("%s (%s)", snapshot.Name, snapshot.CreatedAt.Format(sessionTimeLayout))
+}
+
+// changesText переводит список изменений в текст для показа в окне.
+func changesText(changes []database.Change) string {
+	if len(changes) == 0 {
+		return "Изменений нет"
+	}
+	lines := make([]string, len(changes))
+	for i, change := range changes {
+		lines[i] = change.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (a *AdminApp) createSnapshotsTab() fyne.CanvasObject {
+	snapshots, err := database.GetSnapshots(a.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки снимков: %v", err)
+	}
+	selected := -1
+
+	snapshotsList := widget.NewList(
+		func() int {
+			return len(snapshots)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			item.(*widget.Label).SetText(snapshotTitle(snapshots[id]))
+		},
+	)
+	snapshotsList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Название снимка, например: перед квартальной проверкой")
+	createBtn := widget.NewButton("Создать снимок", func() {
+		name := strings.TrimSpace(nameEntry.Text)
+		if name == "" {
+			dialog.ShowInformation("Внимание", "Введите название снимка", a.window)
+			return
+		}
+		if _, err := database.CreateSnapshot(a.db, name); err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.refreshAllTabs()
+	})
+
+	rollbackBtn := widget.NewButton("Откатить к снимку", func() {
+		if selected < 0 {
+			dialog.ShowInformation("Внимание", "Выберите снимок", a.window)
+			return
+		}
+		a.confirmRollback(snapshots[selected])
+	})
+
+	deleteBtn := widget.NewButton("Удалить снимок", func() {
+		if selected < 0 {
+			dialog.ShowInformation("Внимание", "Выберите снимок", a.window)
+			return
+		}
+		snapshot := snapshots[selected]
+		dialog.ShowConfirm("Удаление снимка", fmt.Sprintf("Удалить снимок '%s'?", snapshot.Name), func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := database.DeleteSnapshot(a.db, snapshot.ID); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
+			a.refreshAllTabs()
+		}, a.window)
+	})
+
+	options := []string{snapshotNow}
+	for _, snapshot := range snapshots {
+		options = append(options, snapshotTitle(snapshot))
+	}
+	fromSelect := widget.NewSelect(options, nil)
+	toSelect := widget.NewSelect(options, nil)
+	toSelect.SetSelected(snapshotNow)
+	if len(snapshots) > 0 {
+		fromSelect.SetSelected(options[1])
+	}
+
+	diffLabel := widget.NewLabel("")
+	diffLabel.TextStyle = fyne.TextStyle{Monospace: true}
+
+	loadOption := func(index int) (*database.Snapshot, error) {
+		if index <= 0 {
+			return database.CurrentSnapshot(a.db)
+		}
+		return database.GetSnapshot(a.db, snapshots[index-1].ID)
+	}
+	compareBtn := widget.NewButton("Сравнить", func() {
+		if fromSelect.SelectedIndex() < 0 || toSelect.SelectedIndex() < 0 {
+			dialog.ShowInformation("Внимание", "Выберите два состояния для сравнения", a.window)
+			return
+		}
+		from, err := loadOption(fromSelect.SelectedIndex())
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		to, err := loadOption(toSelect.SelectedIndex())
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		diffLabel.SetText(changesText(database.SnapshotDiff(from, to)))
+	})
+
+	left := container.NewBorder(
+		container.NewVBox(
+			widget.NewLabelWithStyle("Снимки матрицы доступа", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			container.NewBorder(nil, nil, nil, createBtn, nameEntry),
+		),
+		container.NewHBox(rollbackBtn, deleteBtn),
+		nil, nil,
+		snapshotsList,
+	)
+
+	right := container.NewBorder(
+		container.NewVBox(
+			widget.NewLabelWithStyle("Сравнение", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewForm(
+				widget.NewFormItem("Было", fromSelect),
+				widget.NewFormItem("Стало", toSelect),
+			),
+			compareBtn,
+			widget.NewLabel("+ добавлено, - удалено, ~ изменено или переименовано"),
+		),
+		nil, nil, nil,
+		container.NewScroll(diffLabel),
+	)
+
+	return container.NewHSplit(left, right)
+}
+
+// confirmRollback показывает изменения, которые внесёт откат, и выполняет
+// его после подтверждения.
+func (a *AdminApp) confirmRollback(snapshot database.Snapshot) {
+	changes, err := database.RollbackToSnapshot(a.db, snapshot.ID, true)
+	if err != nil {
+		dialog.ShowError(err, a.window)
+		return
+	}
+	if len(changes) == 0 {
+		dialog.ShowInformation("Откат", "Матрица доступа уже совпадает со снимком", a.window)
+		return
+	}
+
+	changesLabel := widget.NewLabel(changesText(changes))
+	changesLabel.TextStyle = fyne.TextStyle{Monospace: true}
+	changesScroll := container.NewScroll(changesLabel)
+	changesScroll.SetMinSize(fyne.NewSize(600, 300))
+
+	content := container.NewBorder(
+		widget.NewLabel("Настройки не меняются; удалённые после снимка пользователи\nсоздаются заново без пароля."),
+		nil, nil, nil,
+		changesScroll,
+	)
+	dialog.ShowCustomConfirm("Откат к снимку "+snapshot.Name, "Откатить", "Отмена", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		var applied []database.Change
+		err := a.execute("Откат к снимку "+snapshot.Name, func(tx *sql.Tx) error {
+			var err error
+			applied, err = database.RollbackToSnapshotTx(tx, snapshot.ID)
+			return err
+		})
+		if err != nil {
+			dialog.ShowError(err, a.window)
+			return
+		}
+		a.loadAlphabet()
+		a.refreshAllTabs()
+		dialog.ShowInformation("Готово", fmt.Sprintf("Матрица возвращена к снимку '%s': %d изменений", snapshot.Name, len(applied)), a.window)
+	}, a.window)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+	"log"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// pendingKey - ячейка матрицы: пользователь и заголовок столбца.
+type pendingKey struct {
+	userID int
+	column string
+}
+
+// pendingChange - отложенное изменение ячейки в режиме черновика.
+type pendingChange struct {
+	userID   int
+	userName string
+	column   matrixColumn
+	grant    bool
+}
+
+func (c pendingChange) String() string {
+	if c.grant {
+		return fmt.Sprintf("+ право %s: %s", c.userName, c.column.title)
+	}
+	return fmt.Sprintf("- право %s: %s", c.userName, c.column.title)
+}
+
+// createStagingControls - переключатель режима черновика и кнопки
+// просмотра и сброса отложенных изменений для нижней панели матрицы.
+func (a *AdminApp) createStagingControls() fyne.CanvasObject {
+	a.reviewBtn = widget.NewButton("Просмотреть изменения...", a.showReviewDialog)
+	a.reviewBtn.Importance = widget.HighImportance
+	a.discardBtn = widget.NewButton("Сбросить", a.discardPending)
+
+	var stagingCheck *widget.Check
+	stagingCheck = widget.NewCheck("Черновик", func(checked bool) {
+		if checked || len(a.pending) == 0 {
+			a.staging = checked
+			a.updateStagingControls()
+			return
+		}
+		dialog.ShowConfirm("Черновик", fmt.Sprintf("Сбросить отложенные изменения (%d)?", len(a.pending)), func(ok bool) {
+			if !ok {
+				stagingCheck.SetChecked(true)
+				return
+			}
+			a.staging = false
+			a.discardPending()
+		}, a.window)
+	})
+
+	a.updateStagingControls()
+	return container.NewHBox(stagingCheck, a.reviewBtn, a.discardBtn)
+}
+
+func (a *AdminApp) updateStagingControls() {
+	if a.reviewBtn == nil {
+		return
+	}
+	if len(a.pending) == 0 {
+		a.reviewBtn.SetText("Просмотреть изменения...")
+		a.reviewBtn.Disable()
+		a.discardBtn.Disable()
+		return
+	}
+	a.reviewBtn.SetText(fmt.Sprintf("Просмотреть изменения (%d)...", len(a.pending)))
+	a.reviewBtn.Enable()
+	a.discardBtn.Enable()
+}
+
+// togglePending отмечает ячейку для выдачи или снятия права; повторный
+// щелчок по отмеченной ячейке снимает отметку.
+func (a *AdminApp) togglePending(userID int, userName string, column matrixColumn, grant bool) {
+	key := pendingKey{userID: userID, column: column.title}
+	if _, ok := a.pending[key]; ok {
+		delete(a.pending, key)
+	} else {
+		if a.pending == nil {
+			a.pending = make(map[pendingKey]pendingChange)
+		}
+		a.pending[key] = pendingChange{userID: userID, userName: userName, column: column, grant: grant}
+	}
+	a.updateStagingControls()
+}
+
+func (a *AdminApp) discardPending() {
+	a.pending = nil
+	a.updateStagingControls()
+	a.updateMatrixTable()
+}
+
+// sortedPending возвращает отложенные изменения по пользователям и столбцам.
+func (a *AdminApp) sortedPending() []pendingChange {
+	changes := make([]pendingChange, 0, len(a.pending))
+	for _, change := range a.pending {
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].userName != changes[j].userName {
+			return changes[i].userName < changes[j].userName
+		}
+		return changes[i].column.title < changes[j].column.title
+	})
+	return changes
+}
+
+func (a *AdminApp) showReviewDialog() {
+	changes := a.sortedPending()
+	if len(changes) == 0 {
+		return
+	}
+
+	var granted, removed int
+	lines := make([]string, len(changes))
+	for i, change := range changes {
+		lines[i] = change.String()
+		if change.grant {
+			granted++
+		} else {
+			removed++
+		}
+	}
+	changesLabel := widget.NewLabel(strings.Join(lines, "\n"))
+	changesLabel.TextStyle = fyne.TextStyle{Monospace: true}
+	changesScroll := container.NewScroll(changesLabel)
+	changesScroll.SetMinSize(fyne.NewSize(500, 250))
+
+	commentEntry := widget.NewMultiLineEntry()
+	commentEntry.SetPlaceHolder("Комментарий к изменению (необязательно), например номер заявки")
+	commentEntry.SetMinRowsVisible(2)
+
+	content := container.NewBorder(
+		widget.NewLabel(fmt.Sprintf("Будет выдано прав: %d, снято: %d. Изменения применяются вместе или не применяются совсем.", granted, removed)),
+		container.NewVBox(widget.NewLabel("Комментарий:"), commentEntry),
+		nil, nil,
+		changesScroll,
+	)
+	dialog.ShowCustomConfirm("Просмотр изменений", "Применить", "Закрыть", content, func(apply bool) {
+		if !apply {
+			return
+		}
+		if err := a.commitPending(changes, strings.TrimSpace(commentEntry.Text)); err != nil {
+			dialog.ShowError(fmt.Errorf("изменения не применены: %v", err), a.window)
+			return
+		}
+		a.pending = nil
+		a.updateStagingControls()
+		a.refreshAllTabs()
+	}, a.window)
+}
+
+// commitPending применяет отложенные изменения одной транзакцией. Если
+// пользователь был удалён, пока изменения ждали применения, не
+// применяется ничего.
+func (a *AdminApp) commitPending(changes []pendingChange, comment string) error {
+	title := fmt.Sprintf("Применён черновик (изменений: %d)", len(changes))
+	if comment != "" {
+		title += ": " + comment
+	}
+
+	err := a.execute(title, func(tx *sql.Tx) error {
+		for _, change := range changes {
+			userID := change.userID
+			_, _, err := database.GetUserRevision(tx, userID)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("пользователь %s удалён", change.userName)
+			}
+			if err != nil {
+				return err
+			}
+
+			if change.grant {
+				letterID, err := database.EnsureLetterExists(tx, change.column.letters[0])
+				if err != nil {
+					return err
+				}
+				if err := database.Grant(tx, userID, letterID); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, letter := range change.column.letters {
+				letterID, err := database.GetLetterID(tx, letter)
+				if err == sql.ErrNoRows {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if err := database.Remove(tx, userID, letterID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%s", title)
+	for _, change := range changes {
+		log.Printf("  %s", change)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Package charset определяет кодировку текстовых файлов (UTF-8,
+// Windows-1251 или KOI8-R) и перекодирует их в UTF-8.
+package charset
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+type Encoding string
+
+const (
+	UTF8   Encoding = "UTF-8"
+	CP1251 Encoding = "Windows-1251"
+	KOI8R  Encoding = "KOI8-R"
+)
+
+// sampleSize - размер начала файла, по которому определяется кодировка.
+const sampleSize = 64 * 1024
+
+var bom = []byte{0xef, 0xbb, 0xbf}
+
+// Detect определяет кодировку по образцу текста. Корректный UTF-8 считается
+// UTF-8; иначе из однобайтовых кодировок выбирается та, в которой образец
+// даёт больше строчных кириллических букв: обычный текст в основном
+// состоит из строчных, а строчные буквы Windows-1251 в KOI8-R читаются
+// как заглавные и наоборот.
+func Detect(sample []byte) Encoding {
+	if bytes.HasPrefix(sample, bom) || validUTF8(sample) {
+		return UTF8
+	}
+
+	koi8, _ := charmap.KOI8R.NewDecoder().Bytes(sample)
+	cp1251, _ := charmap.Windows1251.NewDecoder().Bytes(sample)
+	if lowerCyrillic(koi8) > lowerCyrillic(cp1251) {
+		return KOI8R
+	}
+	return CP1251
+}
+
+// validUTF8 проверяет образец, допуская обрезанный последний символ.
+func validUTF8(sample []byte) bool {
+	if utf8.Valid(sample) {
+		return true
+	}
+	i := len(sample) - 1
+	for i > 0 && len(sample)-i < utf8.UTFMax && !utf8.RuneStart(sample[i]) {
+		i--
+	}
+	return i >= 0 && !utf8.FullRune(sample[i:]) && utf8.Valid(sample[:i])
+}
+
+func lowerCyrillic(text []byte) int {
+	n := 0
+	for _, r := range string(text) {
+		if unicode.Is(unicode.Cyrillic, r) && unicode.IsLower(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// NewReader определяет кодировку по началу r и возвращает читатель,
+// выдающий текст в UTF-8 (без BOM).
+func NewReader(r io.Reader) (io.Reader, Encoding, error) {
+	reader := bufio.NewReaderSize(r, sampleSize)
+	sample, err := reader.Peek(sampleSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+
+	encoding := Detect(sample)
+	switch encoding {
+	case KOI8R:
+		return charmap.KOI8R.NewDecoder().Reader(reader), encoding, nil
+	case CP1251:
+		return charmap.Windows1251.NewDecoder().Reader(reader), encoding, nil
+	}
+
+	if bytes.HasPrefix(sample, bom) {
+		if _, err := reader.Discard(len(bom)); err != nil {
+			return nil, "", err
+		}
+	}
+	return reader, encoding, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"laba3/database"
+)
+
+func runBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	output := flags.String("o", "", "файл резервной копии")
+	dir := flags.String("dir", "", "каталог для копий с ротацией (вместо -o)")
+	keep := flags.Int("keep", database.DefaultBackupKeep, "сколько последних копий хранить в -dir")
+	flags.Parse(args)
+
+	if (*output == "") == (*dir == "") {
+		flags.Usage()
+		return fmt.Errorf("необходимо указать либо -o, либо -dir")
+	}
+	if *keep < 1 {
+		return fmt.Errorf("-keep должно быть не меньше 1")
+	}
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	if *dir != "" {
+		path, err := database.RotateBackup(db, *dir, *keep)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Резервная копия сохранена в %s\n", path)
+		return nil
+	}
+
+	if err := database.Backup(db, *output); err != nil {
+		return err
+	}
+	fmt.Printf("Резервная копия сохранена в %s\n", *output)
+	return nil
+}
+
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	input := flags.String("i", "", "файл резервной копии")
+	check := flags.Bool("check", false, "только проверить копию")
+	flags.Parse(args)
+
+	if *input == "" {
+		flags.Usage()
+		return fmt.Errorf("необходимо указать -i")
+	}
+
+	version, err := database.CheckBackup(*input)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Копия %s цела, версия схемы %d\n", *input, version)
+	if *check {
+		return nil
+	}
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	if err := database.Restore(db, *input); err != nil {
+		return err
+	}
+	fmt.Println("База данных восстановлена из копии")
+	return nil
+}
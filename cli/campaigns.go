@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"laba3/database"
+	"os"
+)
+
+func runRecert(args []string) error {
+	flags := flag.NewFlagSet("recert", flag.ExitOnError)
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	closeDue := flags.Bool("close-due", false, "закрыть кампании с истёкшим сроком")
+	report := flags.Int("report", 0, "номер кампании для отчёта")
+	output := flags.String("o", "", "файл отчёта CSV (по умолчанию - стандартный вывод)")
+	flags.Parse(args)
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	if *report != 0 {
+		campaign, err := database.GetCampaign(db, *report)
+		if err != nil {
+			return err
+		}
+		items, err := database.GetCampaignItems(db, campaign.ID)
+		if err != nil {
+			return err
+		}
+		if *output == "" {
+			return database.WriteCampaignReport(os.Stdout, campaign, items)
+		}
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		if err := database.WriteCampaignReport(file, campaign, items); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Отчёт по кампании '%s' сохранён в %s\n", campaign.Name, *output)
+		return nil
+	}
+
+	if *closeDue {
+		campaigns, err := database.DueCampaigns(db)
+		if err != nil {
+			return err
+		}
+		for _, campaign := range campaigns {
+			tx, err := db.Begin()
+			if err != nil {
+				return err
+			}
+			revoked, err := database.CloseCampaign(tx, campaign.ID)
+			if err == nil {
+				err = tx.Commit()
+			}
+			if err != nil {
+				tx.Rollback()
+				return fmt.Errorf("ошибка закрытия кампании '%s': %v", campaign.Name, err)
+			}
+			fmt.Printf("Кампания '%s' закрыта, отозвано прав: %d\n", campaign.Name, revoked)
+		}
+		return nil
+	}
+
+	campaigns, err := database.GetCampaigns(db)
+	if err != nil {
+		return err
+	}
+	if len(campaigns) == 0 {
+		fmt.Println("Кампаний пересмотра нет")
+	}
+	for _, campaign := range campaigns {
+		status := "до " + campaign.Deadline.Format("02.01.2006 15:04:05")
+		if campaign.Closed() {
+			status = "закрыта " + campaign.ClosedAt.Format("02.01.2006 15:04:05")
+		}
+		fmt.Printf("%d. %s (%s): подтверждено %d, отозвано %d, не рассмотрено %d из %d\n", campaign.ID, campaign.Name, status,
+			campaign.Confirmed, campaign.Revoked+campaign.AutoRevoked, campaign.Pending(), campaign.Total)
+	}
+	return nil
+}
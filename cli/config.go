@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"laba3/database"
+	"os"
+)
+
+// configFormat возвращает формат из флага -format или, если он не задан,
+// по расширению файла.
+func configFormat(format, path string) (database.Format, error) {
+	switch database.Format(format) {
+	case "":
+		return database.FormatFromPath(path), nil
+	case database.FormatJSON, database.FormatYAML:
+		return database.Format(format), nil
+	}
+	return "", fmt.Errorf("неизвестный формат '%s' (допустимы json и yaml)", format)
+}
+
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	output := flags.String("o", "", "файл для записи (по умолчанию stdout)")
+	formatName := flags.String("format", "", "формат: json или yaml (по умолчанию по расширению файла)")
+	flags.Parse(args)
+
+	format, err := configFormat(*formatName, *output)
+	if err != nil {
+		return err
+	}
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	cfg, err := database.Export(db)
+	if err != nil {
+		return fmt.Errorf("ошибка выгрузки конфигурации: %v", err)
+	}
+
+	if *output == "" {
+		return database.WriteConfig(os.Stdout, cfg, format)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return database.WriteConfig(file, cfg, format)
+}
+
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	input := flags.String("i", "", "файл конфигурации (- для stdin)")
+	formatName := flags.String("format", "", "формат: json или yaml (по умолчанию по расширению файла)")
+	modeName := flags.String("mode", string(database.ImportMerge), "режим: merge - только добавлять, replace - привести базу к файлу")
+	dryRun := flags.Bool("dry-run", false, "показать изменения, не меняя базу")
+	flags.Parse(args)
+
+	if *input == "" {
+		flags.Usage()
+		return fmt.Errorf("необходимо указать -i")
+	}
+
+	format, err := configFormat(*formatName, *input)
+	if err != nil {
+		return err
+	}
+	mode, err := database.ParseImportMode(*modeName)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader = os.Stdin
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	cfg, err := database.ReadConfig(reader, format)
+	if err != nil {
+		return err
+	}
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	changes, err := database.Import(db, cfg, mode, *dryRun)
+	if err != nil {
+		return fmt.Errorf("ошибка импорта: %v", err)
+	}
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	switch {
+	case len(changes) == 0:
+		fmt.Println("Изменений нет")
+	case *dryRun:
+		fmt.Printf("Пробный запуск: %d изменений, база не изменена\n", len(changes))
+	default:
+		fmt.Printf("Импорт выполнен: %d изменений\n", len(changes))
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"laba3/database"
+	"laba3/grapheme"
+	"laba3/hru"
+	"laba3/takegrant"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+type subcommand struct {
+	description string
+	run         func(args []string) error
+}
+
+var subcommands = map[string]subcommand{
+	"backup":   {"создать резервную копию базы данных", runBackup},
+	"restore":  {"восстановить базу данных из резервной копии", runRestore},
+	"export":   {"выгрузить конфигурацию доступа в JSON или YAML", runExport},
+	"hru":      {"анализ безопасности модели HRU", runHRU},
+	"import":   {"загрузить конфигурацию доступа из JSON или YAML", runImport},
+	"plan":     {"показать изменения для приведения базы к файлу политики", runPlan},
+	"recert":   {"кампании пересмотра прав: список, закрытие по сроку, отчёт", runRecert},
+	"apply":    {"привести базу к файлу политики", runApply},
+	"drift":    {"показать изменения базы после последнего apply", runDrift},
+	"tg-edge":  {"добавить или удалить ребро take/grant", runTakeGrantEdge},
+	"tg-share": {"проверить предикат can_share модели Take-Grant", runCanShare},
+	"tg-steal": {"проверить предикат can_steal модели Take-Grant", runCanSteal},
+	"tg-dot":   {"выгрузить граф Take-Grant в формате Graphviz DOT", runTakeGrantDOT},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Использование: cli <команда> [флаги]")
+	fmt.Fprintln(os.Stderr, "Команды:")
+
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, subcommands[name].description)
+	}
+}
+
+func openDB(path string) *sql.DB {
+	db, err := database.Init(path)
+	if err != nil {
+		log.Fatal("Ошибка инициализации базы данных:", err)
+	}
+	return db
+}
+
+func runHRU(args []string) error {
+	flags := flag.NewFlagSet("hru", flag.ExitOnError)
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	commandsPath := flags.String("commands", "", "файл с командами HRU")
+	right := flags.String("right", hru.ReadRight, "проверяемое право")
+	subject := flags.String("subject", "", "субъект, к которому может утечь право")
+	object := flags.String("object", "", "объект (по умолчанию любой)")
+	depth := flags.Int("depth", 4, "максимальная длина последовательности команд")
+	maxStates := flags.Int("max-states", 100000, "максимальное число рассматриваемых состояний")
+	flags.Parse(args)
+
+	if *commandsPath == "" || *subject == "" {
+		flags.Usage()
+		return fmt.Errorf("необходимо указать -commands и -subject")
+	}
+
+	file, err := os.Open(*commandsPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	spec, err := hru.Parse(file)
+	if err != nil {
+		return fmt.Errorf("ошибка разбора файла команд: %v", err)
+	}
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	state, err := hru.FromDatabase(db)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения матрицы доступа: %v", err)
+	}
+	state.AddGrants(spec.Initial)
+
+	result := hru.Analyze(state, spec.Commands, hru.Query{
+		Right:   *right,
+		Subject: *subject,
+		Object:  *object,
+	}, hru.Limits{MaxDepth: *depth, MaxStates: *maxStates})
+
+	if result.Leaked {
+		fmt.Printf("Утечка возможна: право '%s' попадает в ячейку [%s, %s]\n", *right, result.Subject, result.Object)
+		fmt.Println("Контрпример:")
+		for i, step := range result.Trace {
+			fmt.Printf("  %d. %s\n", i+1, step)
+		}
+		return nil
+	}
+
+	fmt.Printf("Утечка права '%s' к субъекту %s не обнаружена (состояний: %d, глубина: %d)\n",
+		*right, *subject, result.Explored, *depth)
+	if !result.Complete {
+		fmt.Println("Внимание: достигнут лимит состояний, поиск выполнен не полностью")
+	}
+	return nil
+}
+
+// resolveVertex переводит запись "u:имя" или "l:символ" в вид вершины и
+// её идентификатор в базе данных.
+func resolveVertex(db *sql.DB, ref string) (kind string, id int, err error) {
+	ref = takegrant.ParseVertex(ref)
+	if letter, ok := strings.CutPrefix(ref, "l:"); ok {
+		if !grapheme.IsSingle(letter) {
+			return "", 0, fmt.Errorf("ожидается один символ: '%s'", letter)
+		}
+		id, err = database.GetLetterID(db, letter)
+		if err != nil {
+			return "", 0, fmt.Errorf("буква '%s' не найдена: %v", letter, err)
+		}
+		return database.TargetLetter, id, nil
+	}
+
+	name := strings.TrimPrefix(ref, "u:")
+	id, err = database.FindUser(db, name)
+	if err != nil {
+		return "", 0, fmt.Errorf("пользователь '%s' не найден: %v", name, err)
+	}
+	return database.TargetUser, id, nil
+}
+
+func runTakeGrantEdge(args []string) error {
+	flags := flag.NewFlagSet("tg-edge", flag.ExitOnError)
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	from := flags.String("from", "", "пользователь, из которого выходит ребро")
+	to := flags.String("to", "", "вершина назначения: u:имя или l:символ")
+	label := flags.String("label", database.EdgeTake, "метка ребра: t или g")
+	remove := flags.Bool("remove", false, "удалить ребро вместо добавления")
+	flags.Parse(args)
+
+	if *from == "" || *to == "" {
+		flags.Usage()
+		return fmt.Errorf("необходимо указать -from и -to")
+	}
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	fromKind, fromID, err := resolveVertex(db, *from)
+	if err != nil {
+		return err
+	}
+	if fromKind != database.TargetUser {
+		return fmt.Errorf("ребро take/grant может выходить только из пользователя")
+	}
+	toKind, toID, err := resolveVertex(db, *to)
+	if err != nil {
+		return err
+	}
+
+	if *remove {
+		return database.RemoveTakeGrantEdge(db, fromID, toKind, toID, *label)
+	}
+	return database.AddTakeGrantEdge(db, fromID, toKind, toID, *label)
+}
+
+func loadTakeGrant(flags *flag.FlagSet, args []string) (*takegrant.Graph, error) {
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	flags.Parse(args)
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	return takegrant.FromDatabase(db)
+}
+
+func runTakeGrantPredicate(name string, args []string, predicate func(g *takegrant.Graph, right rune, x, y string) bool) error {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	right := flags.String("right", string(takegrant.Read), "право (r, w, t или g)")
+	x := flags.String("x", "", "вершина, получающая право: u:имя или l:символ")
+	y := flags.String("y", "", "вершина, на которую выдаётся право")
+
+	g, err := loadTakeGrant(flags, args)
+	if err != nil {
+		return err
+	}
+	if *x == "" || *y == "" || len([]rune(*right)) != 1 {
+		flags.Usage()
+		return fmt.Errorf("необходимо указать -x, -y и одно право -right")
+	}
+
+	xID, yID := takegrant.ParseVertex(*x), takegrant.ParseVertex(*y)
+	for _, id := range []string{xID, yID} {
+		if _, ok := g.Vertex(id); !ok {
+			return fmt.Errorf("вершина '%s' отсутствует в графе", id)
+		}
+	}
+
+	result := predicate(g, []rune(*right)[0], xID, yID)
+	fmt.Printf("%s(%s, %s, %s) = %v\n", strings.ReplaceAll(name, "tg-", "can_"), *right, xID, yID, result)
+	return nil
+}
+
+func runCanShare(args []string) error {
+	return runTakeGrantPredicate("tg-share", args, (*takegrant.Graph).CanShare)
+}
+
+func runCanSteal(args []string) error {
+	return runTakeGrantPredicate("tg-steal", args, (*takegrant.Graph).CanSteal)
+}
+
+func runTakeGrantDOT(args []string) error {
+	flags := flag.NewFlagSet("tg-dot", flag.ExitOnError)
+	output := flags.String("o", "", "файл для записи (по умолчанию stdout)")
+
+	g, err := loadTakeGrant(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return g.WriteDOT(os.Stdout)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return g.WriteDOT(file)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := subcommands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		log.Fatal("Ошибка: ", err)
+	}
+}
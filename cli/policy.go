@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"flag"
+	"fmt"
+	"laba3/database"
+	"laba3/policy"
+	"os"
+	"strings"
+)
+
+// exitDrift - код завершения drift, если база разошлась с политикой.
+const exitDrift = 2
+
+func readPolicy(path string) (*policy.Policy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return policy.Read(file, database.FormatFromPath(path))
+}
+
+func printChanges(changes []database.Change) {
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+}
+
+func runPlan(args []string) error {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	path := flags.String("f", "", "файл политики (JSON или YAML)")
+	flags.Parse(args)
+
+	if *path == "" {
+		flags.Usage()
+		return fmt.Errorf("необходимо указать -f")
+	}
+
+	p, err := readPolicy(*path)
+	if err != nil {
+		return err
+	}
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	changes, err := policy.Plan(db, p)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("База соответствует политике, изменений нет")
+		return nil
+	}
+	printChanges(changes)
+	fmt.Printf("План: %s\n", policy.Summary(changes))
+	return nil
+}
+
+func runApply(args []string) error {
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	path := flags.String("f", "", "файл политики (JSON или YAML)")
+	autoApprove := flags.Bool("auto-approve", false, "применить без подтверждения")
+	flags.Parse(args)
+
+	if *path == "" {
+		flags.Usage()
+		return fmt.Errorf("необходимо указать -f")
+	}
+
+	p, err := readPolicy(*path)
+	if err != nil {
+		return err
+	}
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	changes, err := policy.Plan(db, p)
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		printChanges(changes)
+		fmt.Printf("План: %s\n", policy.Summary(changes))
+
+		if !*autoApprove {
+			fmt.Print("Применить изменения? Введите yes для подтверждения: ")
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if strings.TrimSpace(answer) != "yes" {
+				fmt.Println("Отменено")
+				return nil
+			}
+		}
+	}
+
+	// План пересчитывается внутри транзакции применения; если база успела
+	// измениться после показа плана, ничего не применяется
+	applied, err := policy.Apply(db, p, *path, changes)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("База соответствует политике, состояние синхронизации обновлено")
+		return nil
+	}
+	fmt.Printf("Применено: %s\n", policy.Summary(applied))
+	return nil
+}
+
+func runDrift(args []string) error {
+	flags := flag.NewFlagSet("drift", flag.ExitOnError)
+	dbPath := flags.String("db", "data.db", "путь к базе данных")
+	flags.Parse(args)
+
+	db := openDB(*dbPath)
+	defer db.Close()
+
+	report, err := policy.Drift(db)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("политика ещё не применялась к этой базе")
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Последняя синхронизация: %s из %s\n", report.AppliedAt.Format("02.01.2006 15:04:05"), report.Source)
+	if len(report.Changes) == 0 {
+		fmt.Println("Расхождений нет")
+		return nil
+	}
+	fmt.Println("Изменения в базе после синхронизации:")
+	printChanges(report.Changes)
+	fmt.Printf("Итого: %s\n", policy.Summary(report.Changes))
+	db.Close()
+	os.Exit(exitDrift)
+	return nil
+}
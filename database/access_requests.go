@@ -0,0 +1,204 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AccessRequest - запрос пользователя на доступ к символу. Нулевой
+// ExpiresAt у одобренного запроса означает бессрочное право.
+type AccessRequest struct {
+	ID            int
+	UserID        int
+	UserName      string
+	Letter        string
+	Justification string
+	RequestedAt   time.Time
+	Status        string
+	DecidedBy     string
+	DecidedAt     time.Time
+	ExpiresAt     time.Time
+	Comment       string
+}
+
+// RequestAccess создаёт запрос пользователя на доступ к символу letter.
+func RequestAccess(db Querier, userID int, letter, justification string) (int, error) {
+	letter, err := normalizeLetter(letter)
+	if err != nil {
+		return 0, err
+	}
+	justification = strings.TrimSpace(justification)
+	if justification == "" {
+		return 0, fmt.Errorf("укажите, зачем нужен доступ")
+	}
+
+	permissions, err := GetPermissions(db, userID)
+	if err != nil {
+		return 0, err
+	}
+	for _, permission := range permissions {
+		if permission == letter {
+			return 0, fmt.Errorf("доступ к '%s' уже есть", letter)
+		}
+	}
+
+	var pending int
+	err = db.QueryRow("SELECT COUNT(*) FROM access_requests WHERE user_id = ? AND letter = ? AND status = ?",
+		userID, letter, RequestPending).Scan(&pending)
+	if err != nil {
+		return 0, err
+	}
+	if pending > 0 {
+		return 0, fmt.Errorf("запрос на '%s' уже ожидает рассмотрения", letter)
+	}
+
+	result, err := db.Exec(`INSERT INTO access_requests (user_id, letter, justification, requested_at)
+		VALUES (?, ?, ?, ?)`, userID, letter, justification, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+const accessRequestQuery = `SELECT r.id, r.user_id, u.name, r.letter, r.justification, r.requested_at, r.status,
+	COALESCE(r.decided_by, ''), COALESCE(r.decided_at, 0), COALESCE(r.expires_at, 0), r.comment
+	FROM access_requests r JOIN users u ON u.id = r.user_id`
+
+func queryAccessRequests(db Querier, where string, args ...any) ([]AccessRequest, error) {
+	rows, err := db.Query(accessRequestQuery+" "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []AccessRequest
+	for rows.Next() {
+		var request AccessRequest
+		var requestedAt, decidedAt, expiresAt int64
+		err := rows.Scan(&request.ID, &request.UserID, &request.UserName, &request.Letter, &request.Justification,
+			&requestedAt, &request.Status, &request.DecidedBy, &decidedAt, &expiresAt, &request.Comment)
+		if err != nil {
+			return nil, err
+		}
+		request.RequestedAt = time.Unix(requestedAt, 0)
+		if decidedAt != 0 {
+			request.DecidedAt = time.Unix(decidedAt, 0)
+		}
+		if expiresAt != 0 {
+			request.ExpiresAt = time.Unix(expiresAt, 0)
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// GetAccessRequests возвращает запросы всех пользователей: сначала
+// ожидающие, начиная с самых старых, затем рассмотренные.
+func GetAccessRequests(db Querier) ([]AccessRequest, error) {
+	return queryAccessRequests(db, `ORDER BY r.status <> 'pending',
+		CASE WHEN r.status = 'pending' THEN r.requested_at ELSE -r.decided_at END, r.id`)
+}
+
+// GetUserAccessRequests возвращает запросы пользователя, начиная с последних.
+func GetUserAccessRequests(db Querier, userID int) ([]AccessRequest, error) {
+	return queryAccessRequests(db, "WHERE r.user_id = ? ORDER BY r.requested_at DESC, r.id DESC", userID)
+}
+
+// ApproveAccessRequest одобряет запрос и выдаёт право: бессрочно, если
+// expires нулевое, иначе до expires. Символ должен входить в алфавит
+// объектов.
+func ApproveAccessRequest(db Querier, requestID int, admin string, expires time.Time) error {
+	request, err := pendingAccessRequest(db, requestID)
+	if err != nil {
+		return err
+	}
+
+	alphabet, err := GetAlphabet(db)
+	if err != nil {
+		return err
+	}
+	if !alphabet.Allows(request.Letter) {
+		return fmt.Errorf("символ '%s' не входит в алфавит объектов (%s)", request.Letter, alphabet)
+	}
+
+	letterID, err := EnsureLetterExists(db, request.Letter)
+	if err != nil {
+		return err
+	}
+	var expiresAt any
+	if expires.IsZero() {
+		err = Grant(db, request.UserID, letterID)
+	} else {
+		expiresAt = expires.Unix()
+		err = GrantUntil(db, request.UserID, letterID, expires)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE access_requests SET status = ?, decided_by = ?, decided_at = ?, expires_at = ? WHERE id = ?",
+		RequestApproved, admin, time.Now().Unix(), expiresAt, requestID)
+	return err
+}
+
+// RejectAccessRequest отклоняет запрос; comment увидит пользователь.
+func RejectAccessRequest(db Querier, requestID int, admin, comment string) error {
+	if _, err := pendingAccessRequest(db, requestID); err != nil {
+		return err
+	}
+	_, err := db.Exec("UPDATE access_requests SET status = ?, decided_by = ?, decided_at = ?, comment = ? WHERE id = ?",
+		RequestRejected, admin, time.Now().Unix(), strings.TrimSpace(comment), requestID)
+	return err
+}
+
+func pendingAccessRequest(db Querier, requestID int) (AccessRequest, error) {
+	requests, err := queryAccessRequests(db, "WHERE r.id = ?", requestID)
+	if err != nil {
+		return AccessRequest{}, err
+	}
+	if len(requests) == 0 {
+		return AccessRequest{}, fmt.Errorf("запрос №%d не найден: %v", requestID, sql.ErrNoRows)
+	}
+	if requests[0].Status != RequestPending {
+		return requests[0], fmt.Errorf("запрос №%d уже рассмотрен", requestID)
+	}
+	return requests[0], nil
+}
+
+// GrantUntil выдаёт право, действующее до until. Бессрочное право не
+// сокращается, а у временного остаётся более поздний срок.
+func GrantUntil(db Querier, userID, letterID int, until time.Time) error {
+	_, err := db.Exec(
+		`INSERT INTO user_letters (user_id, letter_id, expires_at) VALUES (?, ?, ?)
+		 ON CONFLICT (user_id, letter_id) DO UPDATE SET expires_at = excluded.expires_at
+		 WHERE user_letters.expires_at IS NOT NULL AND user_letters.expires_at < excluded.expires_at`,
+		userID, letterID, until.Unix(),
+	)
+	return err
+}
+
+// GetGrantExpiries возвращает сроки действия временных прав пользователя,
+// которые ещё не истекли.
+func GetGrantExpiries(db Querier, userID int) (map[string]time.Time, error) {
+	rows, err := db.Query(`SELECT l.char, ul.expires_at FROM user_letters ul
+		JOIN letters l ON l.id = ul.letter_id
+		WHERE ul.user_id = ? AND ul.expires_at > ?`, userID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expiries := make(map[string]time.Time)
+	for rows.Next() {
+		var char string
+		var expiresAt int64
+		if err := rows.Scan(&char, &expiresAt); err != nil {
+			return nil, err
+		}
+		expiries[char] = time.Unix(expiresAt, 0)
+	}
+	return expiries, rows.Err()
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MinApprovalAdmins - сколько администраторов можно завести при
+// включённом двойном одобрении без одобренного запроса. Дальше список
+// администраторов меняется только после отключения одобрения, иначе
+// один администратор мог бы завести второго и одобрять свои запросы.
+const MinApprovalAdmins = 2
+
+// ErrWrongAdmin - неизвестное имя администратора или неверный пароль.
+// Причина не уточняется, чтобы по ответу нельзя было подобрать имя.
+var ErrWrongAdmin = errors.New("неверное имя администратора или пароль")
+
+type Admin struct {
+	ID   int
+	Name string
+}
+
+// GetAdmins возвращает администраторов в порядке имён.
+func GetAdmins(db Querier) ([]Admin, error) {
+	rows, err := db.Query("SELECT id, name FROM admins ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var admins []Admin
+	for rows.Next() {
+		var admin Admin
+		if err := rows.Scan(&admin.ID, &admin.Name); err != nil {
+			return nil, err
+		}
+		admins = append(admins, admin)
+	}
+	return admins, rows.Err()
+}
+
+func CountAdmins(db Querier) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM admins").Scan(&count)
+	return count, err
+}
+
+// AddAdmin заводит администратора с паролем. При включённом двойном
+// одобрении без одобренного запроса можно завести только первых
+// MinApprovalAdmins администраторов; то же проверяет триггер базы.
+func AddAdmin(db Querier, name, password string) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("имя администратора не может быть пустым")
+	}
+	if password == "" {
+		return 0, fmt.Errorf("пароль администратора не может быть пустым")
+	}
+
+	count, err := CountAdmins(db)
+	if err != nil {
+		return 0, err
+	}
+	if count >= MinApprovalAdmins {
+		if err := checkAdminsUnlocked(db); err != nil {
+			return 0, err
+		}
+	}
+
+	var existing int
+	err = db.QueryRow("SELECT COUNT(*) FROM admins WHERE name = ?", name).Scan(&existing)
+	if err != nil {
+		return 0, err
+	}
+	if existing > 0 {
+		return 0, fmt.Errorf("администратор '%s' уже существует", name)
+	}
+
+	encoded, err := encodePassword(password)
+	if err != nil {
+		return 0, err
+	}
+	result, err := db.Exec("INSERT INTO admins (name, password_hash) VALUES (?, ?)", name, encoded)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// DeleteAdmin удаляет администратора. Последнего администратора удалить
+// нельзя; при включённом двойном одобрении удаление запрещено.
+func DeleteAdmin(db Querier, adminID int) error {
+	if err := checkAdminsUnlocked(db); err != nil {
+		return err
+	}
+	count, err := CountAdmins(db)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return fmt.Errorf("нельзя удалить последнего администратора")
+	}
+	_, err = db.Exec("DELETE FROM admins WHERE id = ?", adminID)
+	return err
+}
+
+// checkAdminsUnlocked запрещает менять список администраторов при
+// включённом двойном одобрении. Отдельного запроса на это нет: сначала
+// одобренным запросом отключается само одобрение.
+func checkAdminsUnlocked(db Querier) error {
+	err := checkApproved(db)
+	if err == ErrApprovalRequired {
+		return fmt.Errorf("при включённом двойном одобрении список администраторов не меняется: сначала отключите одобрение одобренным запросом")
+	}
+	return err
+}
+
+// AuthenticateAdmin проверяет имя и пароль администратора.
+func AuthenticateAdmin(db Querier, name, password string) error {
+	var encoded string
+	err := db.QueryRow("SELECT password_hash FROM admins WHERE name = ?", strings.TrimSpace(name)).Scan(&encoded)
+	if err == sql.ErrNoRows {
+		return ErrWrongAdmin
+	}
+	if err != nil {
+		return err
+	}
+	err = verifyPassword(encoded, password)
+	if err == ErrWrongPassword {
+		return ErrWrongAdmin
+	}
+	return err
+}
+
+// ChangeAdminPassword меняет пароль администратора; нужен прежний
+// пароль, поэтому один администратор не может войти под именем другого,
+// сменив ему пароль.
+func ChangeAdminPassword(db Querier, name, oldPassword, newPassword string) error {
+	if err := AuthenticateAdmin(db, name, oldPassword); err != nil {
+		return err
+	}
+	if newPassword == "" {
+		return fmt.Errorf("пароль администратора не может быть пустым")
+	}
+	encoded, err := encodePassword(newPassword)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE admins SET password_hash = ? WHERE name = ?", encoded, strings.TrimSpace(name))
+	return err
+}
+
+// checkAdmin проверяет, что запрос создаёт или рассматривает заведённый
+// администратор.
+func checkAdmin(db Querier, name string) error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM admins WHERE name = ?", name).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("'%s' не является администратором", name)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SettingApproval включает обязательное одобрение привилегированных
+// операций вторым администратором.
+const SettingApproval = "two_person_approval"
+
+// Operation - операция, которая выполняется только после одобрения.
+type Operation string
+
+const (
+	OpGrantAll     Operation = "grant_all"
+	OpDeleteUser   Operation = "delete_user"
+	OpDeleteLetter Operation = "delete_letter"
+	// OpDisableApproval отключает само двойное одобрение; TargetID равен 0.
+	OpDisableApproval Operation = "disable_approval"
+)
+
+// Состояния запроса.
+const (
+	RequestPending  = "pending"
+	RequestApproved = "approved"
+	RequestRejected = "rejected"
+)
+
+// ErrSelfApproval - администратор пытается одобрить собственный запрос.
+var ErrSelfApproval = errors.New("нельзя одобрить собственный запрос: нужен другой администратор")
+
+// ErrApprovalRequired - привилегированная операция выполняется без
+// одобренного запроса при включённом двойном одобрении.
+var ErrApprovalRequired = errors.New("операция требует одобрения второго администратора")
+
+type ChangeRequest struct {
+	ID          int
+	Operation   Operation
+	TargetID    int
+	Target      string
+	RequestedBy string
+	RequestedAt time.Time
+	Status      string
+	DecidedBy   string
+	DecidedAt   time.Time
+}
+
+func GetApprovalRequired(db Querier) (bool, error) {
+	value, err := GetSetting(db, SettingApproval, "false")
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(value)
+}
+
+// SetApprovalRequired включает или отключает двойное одобрение. Отключить
+// включённое одобрение можно только одобренным запросом OpDisableApproval.
+func SetApprovalRequired(db Querier, required bool) error {
+	return SetSetting(db, SettingApproval, strconv.FormatBool(required))
+}
+
+// checkApproved возвращает ErrApprovalRequired, если двойное одобрение
+// включено, а операция выполняется не из ApproveChange. Ту же проверку
+// выполняют триггеры базы, поэтому она срабатывает и при изменении строк
+// напрямую (импортом, откатом к снимку, отменой операции). Триггеры не
+// видят постраничную замену базы, поэтому Restore при включённом
+// одобрении запрещён отдельно (CheckRestoreAllowed); от записи в файл
+// базы в обход программы проверка не защищает.
+func checkApproved(db Querier) error {
+	required, err := GetApprovalRequired(db)
+	if err != nil || !required {
+		return err
+	}
+	var approved int
+	if err := db.QueryRow("SELECT COUNT(*) FROM approved_changes").Scan(&approved); err != nil {
+		return err
+	}
+	if approved == 0 {
+		return ErrApprovalRequired
+	}
+	return nil
+}
+
+// RequestChange создаёт запрос на операцию над пользователем или буквой
+// targetID; target - её имя на момент запроса. requester - имя
+// администратора, вошедшего в админ-панель (AuthenticateAdmin).
+func RequestChange(db Querier, op Operation, targetID int, target, requester string) (int, error) {
+	if err := checkAdmin(db, requester); err != nil {
+		return 0, err
+	}
+
+	var pending int
+	err := db.QueryRow("SELECT COUNT(*) FROM change_requests WHERE operation = ? AND target_id = ? AND status = ?",
+		op, targetID, RequestPending).Scan(&pending)
+	if err != nil {
+		return 0, err
+	}
+	if pending > 0 {
+		return 0, fmt.Errorf("запрос на эту операцию над '%s' уже ожидает одобрения", target)
+	}
+
+	result, err := db.Exec(`INSERT INTO change_requests (operation, target_id, target, requested_by, requested_at)
+		VALUES (?, ?, ?, ?, ?)`, op, targetID, target, requester, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+const changeRequestColumns = `id, operation, target_id, target, requested_by, requested_at,
+	status, COALESCE(decided_by, ''), COALESCE(decided_at, 0)`
+
+func scanChangeRequest(row interface{ Scan(...any) error }) (ChangeRequest, error) {
+	var request ChangeRequest
+	var requestedAt, decidedAt int64
+	err := row.Scan(&request.ID, &request.Operation, &request.TargetID, &request.Target, &request.RequestedBy,
+		&requestedAt, &request.Status, &request.DecidedBy, &decidedAt)
+	request.RequestedAt = time.Unix(requestedAt, 0)
+	if decidedAt != 0 {
+		request.DecidedAt = time.Unix(decidedAt, 0)
+	}
+	return request, err
+}
+
+func GetChangeRequest(db Querier, requestID int) (ChangeRequest, error) {
+	return scanChangeRequest(db.QueryRow("SELECT "+changeRequestColumns+" FROM change_requests WHERE id = ?", requestID))
+}
+
+// GetChangeRequests возвращает запросы: сначала ожидающие, затем
+// рассмотренные, в каждой группе - начиная с последних.
+func GetChangeRequests(db Querier) ([]ChangeRequest, error) {
+	rows, err := db.Query("SELECT " + changeRequestColumns + ` FROM change_requests
+		ORDER BY status <> 'pending', requested_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []ChangeRequest
+	for rows.Next() {
+		request, err := scanChangeRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// ApproveChange одобряет запрос и выполняет операцию. Одобрить можно
+// только чужой запрос; это проверяется здесь и ограничением таблицы.
+// Функцию нужно вызывать в транзакции, чтобы отметка об одобрении
+// и сама операция сохранялись вместе.
+func ApproveChange(db Querier, requestID int, approver string) error {
+	request, err := decide(db, requestID, approver, RequestApproved)
+	if err != nil {
+		return err
+	}
+
+	// Пока запрос выполняется, проверки одобрения его пропускают
+	if _, err := db.Exec("INSERT INTO approved_changes (request_id) VALUES (?)", requestID); err != nil {
+		return err
+	}
+	err = runApproved(db, request)
+	if _, cleanupErr := db.Exec("DELETE FROM approved_changes WHERE request_id = ?", requestID); err == nil {
+		err = cleanupErr
+	}
+	return err
+}
+
+func runApproved(db Querier, request ChangeRequest) error {
+	switch request.Operation {
+	case OpGrantAll, OpDeleteUser:
+		if _, _, err := GetUserRevision(db, request.TargetID); err != nil {
+			return fmt.Errorf("пользователь '%s' уже удалён", request.Target)
+		}
+		if request.Operation == OpGrantAll {
+			return GrantAll(db, request.TargetID)
+		}
+		return DeleteUser(db, request.TargetID)
+	case OpDeleteLetter:
+		var char string
+		if err := db.QueryRow("SELECT char FROM letters WHERE id = ?", request.TargetID).Scan(&char); err != nil {
+			return fmt.Errorf("буква '%s' уже удалена", request.Target)
+		}
+		return DeleteLetter(db, request.TargetID)
+	case OpDisableApproval:
+		return SetApprovalRequired(db, false)
+	}
+	return fmt.Errorf("неизвестная операция '%s'", request.Operation)
+}
+
+// RejectChange отклоняет запрос. Автор может отклонить свой запрос сам,
+// то есть отозвать его.
+func RejectChange(db Querier, requestID int, decider string) error {
+	_, err := decide(db, requestID, decider, RequestRejected)
+	return err
+}
+
+func decide(db Querier, requestID int, decider, status string) (ChangeRequest, error) {
+	if err := checkAdmin(db, decider); err != nil {
+		return ChangeRequest{}, err
+	}
+
+	request, err := GetChangeRequest(db, requestID)
+	if err == sql.ErrNoRows {
+		return request, fmt.Errorf("запрос №%d не найден", requestID)
+	}
+	if err != nil {
+		return request, err
+	}
+	if request.Status != RequestPending {
+		return request, fmt.Errorf("запрос №%d уже рассмотрен", requestID)
+	}
+	if status == RequestApproved && decider == request.RequestedBy {
+		return request, ErrSelfApproval
+	}
+
+	_, err = db.Exec("UPDATE change_requests SET status = ?, decided_by = ?, decided_at = ? WHERE id = ? AND status = ?",
+		status, decider, time.Now().Unix(), requestID, RequestPending)
+	return request, err
+}
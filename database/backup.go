@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// Ключи настроек плановых резервных копий.
+const (
+	SettingBackupDir      = "backup_dir"
+	SettingBackupInterval = "backup_interval"
+	SettingBackupKeep     = "backup_keep"
+)
+
+// DefaultBackupKeep - сколько плановых копий хранится по умолчанию.
+const DefaultBackupKeep = 7
+
+// backupBusyTimeout - сколько восстановление ждёт, пока приложения
+// пользователей освободят базу.
+const backupBusyTimeout = 5 * time.Second
+
+// Backup сохраняет согласованную копию базы в path командой VACUUM INTO.
+// Копия сначала пишется во временный файл, поэтому существующий файл
+// заменяется только готовой копией. Приложения пользователей могут
+// читать базу во время копирования.
+func Backup(db *sql.DB, path string) error {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if _, err := db.Exec("VACUUM INTO ?", tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ошибка создания резервной копии: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// readOnlyURI возвращает URI SQLite для открытия файла только на чтение;
+// символы вроде ? и # в пути экранируются.
+func readOnlyURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	uri := url.URL{Scheme: "file", Path: filepath.ToSlash(path), RawQuery: "mode=ro"}
+	return uri.String()
+}
+
+// CheckBackup открывает копию только для чтения и проверяет её
+// целостность и версию схемы. Копии более новой версии программы
+// отклоняются, более старые обновляются миграциями после восстановления.
+func CheckBackup(path string) (version int, err error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, fmt.Errorf("копия %s недоступна: %v", path, err)
+	}
+
+	backup, err := sql.Open("sqlite", readOnlyURI(path))
+	if err != nil {
+		return 0, err
+	}
+	defer backup.Close()
+
+	var result string
+	if err := backup.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return 0, fmt.Errorf("файл %s не является базой данных: %v", filepath.Base(path), err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("копия повреждена: %s", result)
+	}
+
+	if !tablesExist(backup) {
+		return 0, fmt.Errorf("файл %s не содержит таблиц системы доступа", filepath.Base(path))
+	}
+
+	version, err = SchemaVersion(backup)
+	if err != nil {
+		return 0, err
+	}
+	if version > len(migrations) {
+		return 0, fmt.Errorf("копия создана более новой версией программы (схема %d, поддерживается %d)", version, len(migrations))
+	}
+	return version, nil
+}
+
+// CheckRestoreAllowed запрещает восстановление при включённом двойном
+// одобрении: копия переносится постранично, минуя триггеры и
+// checkApproved, и могла бы вернуть удалённых пользователей и права или
+// отключить само одобрение.
+func CheckRestoreAllowed(db Querier) error {
+	required, err := GetApprovalRequired(db)
+	if err != nil {
+		return err
+	}
+	if required {
+		return fmt.Errorf("восстановление из копии недоступно, пока включено двойное одобрение: сначала отключите его одобренным запросом")
+	}
+	return nil
+}
+
+// Restore заменяет содержимое базы копией из path через API онлайн-копирования
+// SQLite: страницы переносятся под блокировкой базы, поэтому открытые
+// соединения приложений пользователей видят либо старые, либо новые данные.
+// Файл базы не подменяется. Все открытые сеансы после восстановления
+// завершаются. При включённом двойном одобрении восстановление запрещено.
+func Restore(db *sql.DB, path string) error {
+	if err := CheckRestoreAllowed(db); err != nil {
+		return err
+	}
+	if _, err := CheckBackup(path); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA busy_timeout = %d", backupBusyTimeout.Milliseconds())); err != nil {
+		return err
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		restorer, ok := driverConn.(interface {
+			NewRestore(srcUri string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("драйвер базы данных не поддерживает восстановление")
+		}
+
+		restore, err := restorer.NewRestore(readOnlyURI(path))
+		if err != nil {
+			return err
+		}
+		if _, err := restore.Step(-1); err != nil {
+			restore.Finish()
+			return err
+		}
+		return restore.Finish()
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка восстановления: %v", err)
+	}
+
+	// Копия могла быть сделана до последних миграций
+	if err := migrate(db); err != nil {
+		return err
+	}
+
+	// Сеансы из копии не соответствуют запущенным приложениям: они
+	// завершаются, и пользователи входят заново с восстановленными правами
+	_, err = db.Exec("UPDATE sessions SET terminated = 1, ended_at = ? WHERE ended_at IS NULL", time.Now().Unix())
+	return err
+}
+
+// RotateBackup создаёт в dir копию с отметкой времени в имени и удаляет
+// самые старые копии, оставляя keep последних.
+func RotateBackup(db *sql.DB, dir string, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, "backup-"+time.Now().Format("20060102-150405")+".db")
+	if err := Backup(db, path); err != nil {
+		return "", err
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "backup-*.db"))
+	if err != nil {
+		return path, err
+	}
+	sort.Strings(backups)
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return path, err
+		}
+		backups = backups[1:]
+	}
+	return path, nil
+}
+
+// BackupSchedule - настройки плановых резервных копий; нулевой Interval
+// отключает их.
+type BackupSchedule struct {
+	Dir      string
+	Interval time.Duration
+	Keep     int
+}
+
+func GetBackupSchedule(db Querier) (BackupSchedule, error) {
+	var schedule BackupSchedule
+	var err error
+
+	schedule.Dir, err = GetSetting(db, SettingBackupDir, "backups")
+	if err != nil {
+		return schedule, err
+	}
+
+	value, err := GetSetting(db, SettingBackupInterval, "0")
+	if err != nil {
+		return schedule, err
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes < 0 {
+		return schedule, fmt.Errorf("некорректный интервал резервного копирования '%s'", value)
+	}
+	schedule.Interval = time.Duration(minutes) * time.Minute
+
+	value, err = GetSetting(db, SettingBackupKeep, strconv.Itoa(DefaultBackupKeep))
+	if err != nil {
+		return schedule, err
+	}
+	schedule.Keep, err = strconv.Atoi(value)
+	if err != nil || schedule.Keep < 1 {
+		return schedule, fmt.Errorf("некорректное число хранимых копий '%s'", value)
+	}
+	return schedule, nil
+}
+
+func SetBackupSchedule(db Querier, schedule BackupSchedule) error {
+	if err := SetSetting(db, SettingBackupDir, schedule.Dir); err != nil {
+		return err
+	}
+	if err := SetSetting(db, SettingBackupInterval, strconv.Itoa(int(schedule.Interval.Minutes()))); err != nil {
+		return err
+	}
+	return SetSetting(db, SettingBackupKeep, strconv.Itoa(schedule.Keep))
+}
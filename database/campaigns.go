@@ -0,0 +1,303 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Решения по праву в кампании пересмотра. Право без решения ещё не
+// рассмотрено.
+const (
+	DecisionConfirmed   = "confirmed"
+	DecisionRevoked     = "revoked"
+	DecisionAutoRevoked = "auto_revoked"
+)
+
+// DecisionNames - названия решений для интерфейса и отчёта.
+var DecisionNames = map[string]string{
+	"":                  "не рассмотрено",
+	DecisionConfirmed:   "подтверждено",
+	DecisionRevoked:     "отозвано",
+	DecisionAutoRevoked: "отозвано при закрытии",
+}
+
+// Campaign - кампания пересмотра прав: все права, выданные на момент
+// запуска, нужно подтвердить или отозвать до Deadline. Если AutoRevoke,
+// при закрытии кампании нерассмотренные права отзываются.
+type Campaign struct {
+	ID          int
+	Name        string
+	StartedBy   string
+	StartedAt   time.Time
+	Deadline    time.Time
+	AutoRevoke  bool
+	ClosedAt    time.Time
+	Total       int
+	Confirmed   int
+	Revoked     int
+	AutoRevoked int
+}
+
+func (c Campaign) Closed() bool {
+	return !c.ClosedAt.IsZero()
+}
+
+// Pending возвращает число прав, по которым ещё нет решения.
+func (c Campaign) Pending() int {
+	return c.Total - c.Confirmed - c.Revoked - c.AutoRevoked
+}
+
+// CampaignItem - право пользователя на символ в кампании. Имена
+// сохраняются на момент запуска, чтобы отчёт не зависел от последующих
+// переименований и удалений.
+type CampaignItem struct {
+	ID         int
+	CampaignID int
+	UserID     int
+	UserName   string
+	LetterID   int
+	Letter     string
+	Decision   string
+	Reviewer   string
+	DecidedAt  time.Time
+}
+
+// StartCampaign запускает кампанию по всем действующим правам. Функцию
+// нужно вызывать в транзакции, чтобы кампания не осталась без прав.
+func StartCampaign(db Querier, name, admin string, deadline time.Time, autoRevoke bool) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("укажите название кампании")
+	}
+	if !deadline.After(time.Now()) {
+		return 0, fmt.Errorf("срок кампании должен быть в будущем")
+	}
+	var exists int
+	if err := db.QueryRow("SELECT COUNT(*) FROM campaigns WHERE name = ?", name).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists > 0 {
+		return 0, fmt.Errorf("кампания '%s' уже существует", name)
+	}
+	now := time.Now().Unix()
+	var grants int
+	err := db.QueryRow(`SELECT COUNT(*) FROM user_letters WHERE expires_at IS NULL OR expires_at > ?`, now).Scan(&grants)
+	if err != nil {
+		return 0, err
+	}
+	if grants == 0 {
+		return 0, fmt.Errorf("нет выданных прав для пересмотра")
+	}
+
+	result, err := db.Exec("INSERT INTO campaigns (name, started_by, started_at, deadline, auto_revoke) VALUES (?, ?, ?, ?, ?)",
+		name, admin, now, deadline.Unix(), autoRevoke)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = db.Exec(`INSERT INTO campaign_items (campaign_id, user_id, user_name, letter_id, letter)
+		SELECT ?, u.id, u.name, l.id, l.char FROM user_letters ul
+		JOIN users u ON u.id = ul.user_id
+		JOIN letters l ON l.id = ul.letter_id
+		WHERE ul.expires_at IS NULL OR ul.expires_at > ?`, id, now)
+	return int(id), err
+}
+
+const campaignQuery = `SELECT c.id, c.name, c.started_by, c.started_at, c.deadline, c.auto_revoke, COALESCE(c.closed_at, 0),
+	COUNT(i.id), COUNT(CASE WHEN i.decision = 'confirmed' THEN 1 END),
+	COUNT(CASE WHEN i.decision = 'revoked' THEN 1 END), COUNT(CASE WHEN i.decision = 'auto_revoked' THEN 1 END)
+	FROM campaigns c LEFT JOIN campaign_items i ON i.campaign_id = c.id`
+
+func queryCampaigns(db Querier, where string, args ...any) ([]Campaign, error) {
+	rows, err := db.Query(campaignQuery+" "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var campaigns []Campaign
+	for rows.Next() {
+		var campaign Campaign
+		var startedAt, deadline, closedAt int64
+		err := rows.Scan(&campaign.ID, &campaign.Name, &campaign.StartedBy, &startedAt, &deadline, &campaign.AutoRevoke, &closedAt,
+			&campaign.Total, &campaign.Confirmed, &campaign.Revoked, &campaign.AutoRevoked)
+		if err != nil {
+			return nil, err
+		}
+		campaign.StartedAt = time.Unix(startedAt, 0)
+		campaign.Deadline = time.Unix(deadline, 0)
+		if closedAt != 0 {
+			campaign.ClosedAt = time.Unix(closedAt, 0)
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, rows.Err()
+}
+
+// GetCampaigns возвращает кампании: сначала открытые, затем закрытые,
+// в каждой группе - начиная с последних.
+func GetCampaigns(db Querier) ([]Campaign, error) {
+	return queryCampaigns(db, "GROUP BY c.id ORDER BY c.closed_at IS NOT NULL, c.started_at DESC, c.id DESC")
+}
+
+func GetCampaign(db Querier, campaignID int) (Campaign, error) {
+	campaigns, err := queryCampaigns(db, "WHERE c.id = ? GROUP BY c.id", campaignID)
+	if err != nil {
+		return Campaign{}, err
+	}
+	if len(campaigns) == 0 {
+		return Campaign{}, fmt.Errorf("кампания №%d не найдена: %v", campaignID, sql.ErrNoRows)
+	}
+	return campaigns[0], nil
+}
+
+// GetCampaignItems возвращает права кампании по пользователям и символам.
+func GetCampaignItems(db Querier, campaignID int) ([]CampaignItem, error) {
+	rows, err := db.Query(`SELECT id, campaign_id, user_id, user_name, letter_id, letter,
+		COALESCE(decision, ''), COALESCE(reviewer, ''), COALESCE(decided_at, 0)
+		FROM campaign_items WHERE campaign_id = ? ORDER BY user_name, letter`, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []CampaignItem
+	for rows.Next() {
+		var item CampaignItem
+		var decidedAt int64
+		err := rows.Scan(&item.ID, &item.CampaignID, &item.UserID, &item.UserName, &item.LetterID, &item.Letter,
+			&item.Decision, &item.Reviewer, &decidedAt)
+		if err != nil {
+			return nil, err
+		}
+		if decidedAt != 0 {
+			item.DecidedAt = time.Unix(decidedAt, 0)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// DecideCampaignItems подтверждает или отзывает права кампании. Права, по
+// которым решение уже принято, пропускаются; возвращается число
+// рассмотренных прав. Отзыв сразу снимает право, поэтому функцию нужно
+// вызывать в транзакции.
+func DecideCampaignItems(db Querier, campaignID int, itemIDs []int, reviewer, decision string) (int, error) {
+	if decision != DecisionConfirmed && decision != DecisionRevoked {
+		return 0, fmt.Errorf("неизвестное решение '%s'", decision)
+	}
+	campaign, err := GetCampaign(db, campaignID)
+	if err != nil {
+		return 0, err
+	}
+	if campaign.Closed() {
+		return 0, fmt.Errorf("кампания '%s' уже закрыта", campaign.Name)
+	}
+
+	decided := 0
+	now := time.Now().Unix()
+	for _, itemID := range itemIDs {
+		var userID, letterID int
+		err := db.QueryRow("SELECT user_id, letter_id FROM campaign_items WHERE id = ? AND campaign_id = ? AND decision IS NULL",
+			itemID, campaignID).Scan(&userID, &letterID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return decided, err
+		}
+
+		if decision == DecisionRevoked {
+			if err := Remove(db, userID, letterID); err != nil {
+				return decided, err
+			}
+		}
+		_, err = db.Exec("UPDATE campaign_items SET decision = ?, reviewer = ?, decided_at = ? WHERE id = ?",
+			decision, reviewer, now, itemID)
+		if err != nil {
+			return decided, err
+		}
+		decided++
+	}
+	return decided, nil
+}
+
+// CloseCampaign закрывает кампанию и, если она запущена с автоотзывом,
+// снимает нерассмотренные права. Возвращает число отозванных прав.
+func CloseCampaign(db Querier, campaignID int) (int, error) {
+	campaign, err := GetCampaign(db, campaignID)
+	if err != nil {
+		return 0, err
+	}
+	if campaign.Closed() {
+		return 0, fmt.Errorf("кампания '%s' уже закрыта", campaign.Name)
+	}
+
+	now := time.Now().Unix()
+	revoked := 0
+	if campaign.AutoRevoke {
+		_, err := db.Exec(`DELETE FROM user_letters WHERE EXISTS (SELECT 1 FROM campaign_items i
+			WHERE i.campaign_id = ? AND i.decision IS NULL
+			AND i.user_id = user_letters.user_id AND i.letter_id = user_letters.letter_id)`, campaignID)
+		if err != nil {
+			return 0, err
+		}
+		result, err := db.Exec("UPDATE campaign_items SET decision = ?, decided_at = ? WHERE campaign_id = ? AND decision IS NULL",
+			DecisionAutoRevoked, now, campaignID)
+		if err != nil {
+			return 0, err
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		revoked = int(count)
+	}
+
+	_, err = db.Exec("UPDATE campaigns SET closed_at = ? WHERE id = ?", now, campaignID)
+	return revoked, err
+}
+
+// DueCampaigns возвращает открытые кампании, срок которых истёк.
+func DueCampaigns(db Querier) ([]Campaign, error) {
+	return queryCampaigns(db, "WHERE c.closed_at IS NULL AND c.deadline <= ? GROUP BY c.id ORDER BY c.deadline",
+		time.Now().Unix())
+}
+
+const reportTimeLayout = "02.01.2006 15:04:05"
+
+// WriteCampaignReport выгружает итоги кампании в CSV: по строке на право
+// с решением, проверяющим и временем решения.
+func WriteCampaignReport(w io.Writer, campaign Campaign, items []CampaignItem) error {
+	writer := csv.NewWriter(w)
+	records := [][]string{
+		{"кампания", campaign.Name},
+		{"запущена", campaign.StartedAt.Format(reportTimeLayout), campaign.StartedBy},
+		{"срок", campaign.Deadline.Format(reportTimeLayout)},
+	}
+	if campaign.Closed() {
+		records = append(records, []string{"закрыта", campaign.ClosedAt.Format(reportTimeLayout)})
+	}
+	records = append(records,
+		[]string{"итого", fmt.Sprintf("прав: %d, подтверждено: %d, отозвано: %d, отозвано при закрытии: %d, не рассмотрено: %d",
+			campaign.Total, campaign.Confirmed, campaign.Revoked, campaign.AutoRevoked, campaign.Pending())},
+		nil,
+		[]string{"пользователь", "символ", "решение", "проверяющий", "время решения"},
+	)
+	for _, item := range items {
+		decidedAt := ""
+		if !item.DecidedAt.IsZero() {
+			decidedAt = item.DecidedAt.Format(reportTimeLayout)
+		}
+		records = append(records, []string{item.UserName, item.Letter, DecisionNames[item.Decision], item.Reviewer, decidedAt})
+	}
+	return writer.WriteAll(records)
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"database/sql"
+	"laba3/rules"
+)
+
+// GetLetterCasePolicy возвращает политику регистра, заданную для буквы,
+// или пустую строку, если для неё действует глобальная настройка.
+func GetLetterCasePolicy(db Querier, letterID int) (rules.CasePolicy, error) {
+	var policy sql.NullString
+	err := db.QueryRow("SELECT case_policy FROM letters WHERE id = ?", letterID).Scan(&policy)
+	if err != nil {
+		return "", err
+	}
+	return rules.CasePolicy(policy.String), nil
+}
+
+// SetLetterCasePolicy задаёт политику регистра для буквы. Пустая политика
+// возвращает букву к глобальной настройке.
+func SetLetterCasePolicy(db Querier, letterID int, policy rules.CasePolicy) error {
+	var value any
+	if policy != "" {
+		value = string(policy)
+	}
+	_, err := db.Exec("UPDATE letters SET case_policy = ? WHERE id = ?", value, letterID)
+	return err
+}
+
+// GetCasePolicies возвращает действующую политику регистра каждой буквы
+// с учётом глобальной настройки.
+func GetCasePolicies(db Querier) (map[string]rules.CasePolicy, error) {
+	global, err := GetCasePolicy(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT char, case_policy FROM letters")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := make(map[string]rules.CasePolicy)
+	for rows.Next() {
+		var char string
+		var policy sql.NullString
+		if err := rows.Scan(&char, &policy); err != nil {
+			return nil, err
+		}
+		policies[char] = global
+		if policy.Valid {
+			policies[char] = rules.CasePolicy(policy.String)
+		}
+	}
+	return policies, rows.Err()
+}
+
+// GetPermissionSet возвращает явно выданные буквы пользователя вместе
+// с действующими для них политиками регистра.
+func GetPermissionSet(db Querier, UserID int) (rules.LetterSet, error) {
+	var set rules.LetterSet
+
+	letters, err := GetPermissions(db, UserID)
+	if err != nil {
+		return set, err
+	}
+
+	policies, err := GetCasePolicies(db)
+	if err != nil {
+		return set, err
+	}
+
+	for _, letter := range letters {
+		set.Add(letter, policies[letter])
+	}
+	return set, nil
+}
@@ -0,0 +1,313 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// stateTable описывает таблицу, изменения которой запоминаются для отмены
+// операций администратора: key - столбцы первичного ключа, columns -
+// остальные столбцы.
+type stateTable struct {
+	name    string
+	key     []string
+	columns []string
+	// update дописывается к SET при изменении строки, запросы cleanup
+	// выполняются с ключом строки перед её удалением
+	update  string
+	cleanup []string
+}
+
+// Сеансы и запросы доступа не отслеживаются: при удалении пользователя они
+// удаляются, как в DeleteUser, а ревизия увеличивается при каждом изменении, чтобы
+// приложение пользователя заметило переименование.
+var stateTables = []stateTable{
+	{name: "users", key: []string{"id"}, columns: []string{"name", "password_hash"},
+		update: "revision = revision + 1", cleanup: []string{"DELETE FROM sessions WHERE user_id = ?", "DELETE FROM access_requests WHERE user_id = ?"}},
+	{name: "letters", key: []string{"id"}, columns: []string{"char", "case_policy"}},
+	{name: "user_letters", key: []string{"user_id", "letter_id"}, columns: []string{"expires_at"}},
+	{name: "letter_rules", key: []string{"id"}, columns: []string{"user_id", "expr"}},
+	{name: "tg_edges", key: []string{"src_user", "dst_kind", "dst_id", "label"}},
+	{name: "settings", key: []string{"key"}, columns: []string{"value"}},
+}
+
+func (t stateTable) allColumns() []string {
+	return append(append([]string{}, t.key...), t.columns...)
+}
+
+func (t stateTable) where() string {
+	conditions := make([]string, len(t.key))
+	for i, column := range t.key {
+		conditions[i] = column + " = ?"
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func (t stateTable) rowKey(row []any) string {
+	return fmt.Sprintf("%#v", row[:len(t.key)])
+}
+
+// Изменения операции записываются временными триггерами: каждая
+// вставка, изменение и удаление строки отслеживаемой таблицы добавляет
+// в captured_rows прежнее (old = 1) или новое (old = 0) содержимое строки.
+// Триггеры и журнал создаются в транзакции операции и удаляются в ней же,
+// поэтому читаются только затронутые строки, а не вся база.
+const captureWidth = 4
+
+func (t stateTable) triggerName(event string) string {
+	return "capture_" + t.name + "_" + event
+}
+
+// logRow возвращает INSERT в журнал строки ref (NEW или OLD).
+func (t stateTable) logRow(ref string, old int) string {
+	columns := make([]string, 0, captureWidth)
+	values := make([]string, 0, captureWidth)
+	for i, column := range t.allColumns() {
+		columns = append(columns, fmt.Sprintf("c%d", i))
+		values = append(values, ref+"."+column)
+	}
+	return fmt.Sprintf("INSERT INTO captured_rows (tbl, old, %s) VALUES ('%s', %d, %s);",
+		strings.Join(columns, ", "), t.name, old, strings.Join(values, ", "))
+}
+
+// StartCapture начинает запись изменений отслеживаемых таблиц. Вызывается
+// в транзакции перед операцией; изменения возвращает FinishCapture.
+func StartCapture(db Querier) error {
+	statements := []string{`CREATE TEMP TABLE captured_rows (
+		seq INTEGER PRIMARY KEY,
+		tbl TEXT NOT NULL,
+		old INTEGER NOT NULL,
+		c0, c1, c2, c3
+	)`}
+	for _, table := range stateTables {
+		statements = append(statements,
+			fmt.Sprintf("CREATE TEMP TRIGGER %s AFTER INSERT ON %s BEGIN %s END",
+				table.triggerName("insert"), table.name, table.logRow("NEW", 0)),
+			fmt.Sprintf("CREATE TEMP TRIGGER %s AFTER UPDATE ON %s BEGIN %s %s END",
+				table.triggerName("update"), table.name, table.logRow("OLD", 1), table.logRow("NEW", 0)),
+			fmt.Sprintf("CREATE TEMP TRIGGER %s AFTER DELETE ON %s BEGIN %s END",
+				table.triggerName("delete"), table.name, table.logRow("OLD", 1)),
+		)
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("ошибка записи изменений: %v", err)
+		}
+	}
+	return nil
+}
+
+// FinishCapture прекращает запись и возвращает изменения, внесённые после
+// StartCapture. Прежнее содержимое строки берётся из первой записи журнала
+// о ней, новое читается из таблицы; строки, вернувшиеся в исходное
+// состояние, в результат не попадают.
+func FinishCapture(db Querier) (Changeset, error) {
+	tables := make(map[string]stateTable, len(stateTables))
+	for _, table := range stateTables {
+		tables[table.name] = table
+	}
+
+	// Строки в порядке первого изменения; before равно nil, если строка
+	// была добавлена операцией
+	type touchedRow struct {
+		table  stateTable
+		key    []any
+		before []any
+	}
+	var touched []touchedRow
+	seen := make(map[string]bool)
+
+	rows, err := db.Query("SELECT tbl, old, c0, c1, c2, c3 FROM temp.captured_rows ORDER BY seq")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var old bool
+		row := make([]any, captureWidth)
+		if err := rows.Scan(&name, &old, &row[0], &row[1], &row[2], &row[3]); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		table := tables[name]
+		row = row[:len(table.allColumns())]
+		if id := table.name + table.rowKey(row); !seen[id] {
+			seen[id] = true
+			entry := touchedRow{table: table, key: row[:len(table.key)]}
+			if old {
+				entry.before = row
+			}
+			touched = append(touched, entry)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, table := range stateTables {
+		for _, event := range []string{"insert", "update", "delete"} {
+			if _, err := db.Exec("DROP TRIGGER temp." + table.triggerName(event)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := db.Exec("DROP TABLE temp.captured_rows"); err != nil {
+		return nil, err
+	}
+
+	var changes Changeset
+	for _, row := range touched {
+		current, err := readRows(db, row.table, "SELECT "+strings.Join(row.table.allColumns(), ", ")+
+			" FROM "+row.table.name+" WHERE "+row.table.where(), row.key...)
+		if err != nil {
+			return nil, err
+		}
+		var after []any
+		if len(current) > 0 {
+			after = current[0]
+		}
+		if !reflect.DeepEqual(row.before, after) {
+			changes = append(changes, RowChange{Table: row.table.name, Before: row.before, After: after})
+		}
+	}
+	return changes, nil
+}
+
+func readRows(db Querier, table stateTable, query string, args ...any) ([][]any, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result [][]any
+	width := len(table.key) + len(table.columns)
+	for rows.Next() {
+		row := make([]any, width)
+		pointers := make([]any, width)
+		for i := range row {
+			pointers[i] = &row[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// RowChange - изменение одной строки: Before равно nil для добавленной
+// строки, After - для удалённой.
+type RowChange struct {
+	Table  string
+	Before []any
+	After  []any
+}
+
+// Changeset - строки, изменённые одной операцией. Apply повторяет
+// операцию, Revert отменяет её; обе проверяют, что строки с тех пор
+// не менялись.
+type Changeset []RowChange
+
+// Tables возвращает имена таблиц, затронутых изменениями.
+func (cs Changeset) Tables() map[string]bool {
+	tables := make(map[string]bool)
+	for _, change := range cs {
+		tables[change.Table] = true
+	}
+	return tables
+}
+
+func (cs Changeset) Apply(db Querier) error {
+	return cs.apply(db, false)
+}
+
+func (cs Changeset) Revert(db Querier) error {
+	return cs.apply(db, true)
+}
+
+// apply сначала проверяет все строки, затем удаляет, изменяет и добавляет
+// их - в таком порядке уникальные имена освобождаются раньше, чем заняты.
+func (cs Changeset) apply(db Querier, reverse bool) error {
+	tables := make(map[string]stateTable)
+	for _, table := range stateTables {
+		tables[table.name] = table
+	}
+	direction := func(change RowChange) (from, to []any) {
+		if reverse {
+			return change.After, change.Before
+		}
+		return change.Before, change.After
+	}
+
+	for _, change := range cs {
+		table := tables[change.Table]
+		from, to := direction(change)
+		row := from
+		if row == nil {
+			row = to
+		}
+		current, err := readRows(db, table, "SELECT "+strings.Join(table.allColumns(), ", ")+
+			" FROM "+table.name+" WHERE "+table.where(), row[:len(table.key)]...)
+		if err != nil {
+			return err
+		}
+		if (from == nil && len(current) > 0) || (from != nil && (len(current) == 0 || !reflect.DeepEqual(current[0], from))) {
+			return fmt.Errorf("запись %v в таблице %s изменилась после операции", row[:len(table.key)], table.name)
+		}
+	}
+
+	for phase := 0; phase < 3; phase++ {
+		for _, change := range cs {
+			table := tables[change.Table]
+			from, to := direction(change)
+
+			var err error
+			switch {
+			case phase == 0 && to == nil:
+				err = table.deleteRow(db, from)
+			case phase == 1 && from != nil && to != nil:
+				err = table.updateRow(db, to)
+			case phase == 2 && from == nil:
+				err = table.insertRow(db, to)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t stateTable) deleteRow(db Querier, row []any) error {
+	key := row[:len(t.key)]
+	for _, query := range t.cleanup {
+		if _, err := db.Exec(query, key...); err != nil {
+			return err
+		}
+	}
+	_, err := db.Exec("DELETE FROM "+t.name+" WHERE "+t.where(), key...)
+	return err
+}
+
+func (t stateTable) updateRow(db Querier, row []any) error {
+	assignments := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		assignments[i] = column + " = ?"
+	}
+	if t.update != "" {
+		assignments = append(assignments, t.update)
+	}
+	args := append(append([]any{}, row[len(t.key):]...), row[:len(t.key)]...)
+	_, err := db.Exec("UPDATE "+t.name+" SET "+strings.Join(assignments, ", ")+" WHERE "+t.where(), args...)
+	return err
+}
+
+func (t stateTable) insertRow(db Querier, row []any) error {
+	columns := t.allColumns()
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	_, err := db.Exec("INSERT INTO "+t.name+" ("+strings.Join(columns, ", ")+") VALUES ("+placeholders+")", row...)
+	return err
+}
@@ -0,0 +1,748 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"laba3/rules"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigVersion - версия формата файла конфигурации доступа. Файлы другой
+// версии не импортируются.
+const ConfigVersion = 1
+
+// Config - переносимая конфигурация доступа: настройки, буквы, пользователи
+// с выданными буквами и правилами, рёбра Take-Grant. Пароли и сеансы
+// в конфигурацию не входят.
+type Config struct {
+	Version  int               `json:"version" yaml:"version"`
+	Settings map[string]string `json:"settings,omitempty" yaml:"settings,omitempty"`
+	Letters  []LetterConfig    `json:"letters" yaml:"letters"`
+	Users    []UserConfig      `json:"users" yaml:"users"`
+	Edges    []EdgeConfig      `json:"edges,omitempty" yaml:"edges,omitempty"`
+}
+
+// LetterConfig - буква и её политика регистра; пустая политика означает
+// глобальную настройку.
+type LetterConfig struct {
+	Char string           `json:"char" yaml:"char"`
+	Case rules.CasePolicy `json:"case,omitempty" yaml:"case,omitempty"`
+}
+
+// UserConfig - пользователь с правами и правилами. Expires задаёт сроки
+// временных прав: буква из Letters и момент, когда право истекает; права
+// без срока бессрочные.
+type UserConfig struct {
+	Name    string               `json:"name" yaml:"name"`
+	Letters []string             `json:"letters" yaml:"letters"`
+	Expires map[string]time.Time `json:"expires,omitempty" yaml:"expires,omitempty"`
+	Rules   []string             `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+// EdgeConfig - ребро take/grant от пользователя From к пользователю User
+// или к букве Letter.
+type EdgeConfig struct {
+	From   string `json:"from" yaml:"from"`
+	User   string `json:"user,omitempty" yaml:"user,omitempty"`
+	Letter string `json:"letter,omitempty" yaml:"letter,omitempty"`
+	Label  string `json:"label" yaml:"label"`
+}
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// FormatFromPath выбирает формат по расширению файла: .yaml и .yml - YAML,
+// остальные - JSON.
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+func WriteConfig(w io.Writer, cfg *Config, format Format) error {
+	if format == FormatYAML {
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(cfg); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(cfg)
+}
+
+// ReadConfig читает конфигурацию и проверяет версию формата. Неизвестные
+// поля считаются ошибкой, чтобы опечатка не превращалась в пропущенные
+// права.
+func ReadConfig(r io.Reader, format Format) (*Config, error) {
+	var cfg Config
+	if err := Decode(r, format, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Version != ConfigVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия конфигурации %d (ожидается %d)", cfg.Version, ConfigVersion)
+	}
+	return &cfg, nil
+}
+
+// Decode читает JSON или YAML в v, отклоняя неизвестные поля.
+func Decode(r io.Reader, format Format, v any) error {
+	if format == FormatYAML {
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(v); err != nil {
+			return fmt.Errorf("ошибка разбора YAML: %v", err)
+		}
+		return nil
+	}
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("ошибка разбора JSON: %v", err)
+	}
+	return nil
+}
+
+// Export выгружает конфигурацию доступа. Списки отсортированы, чтобы
+// выгрузки одной и той же базы совпадали.
+func Export(db Querier) (*Config, error) {
+	settings, err := settingsMap(db)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		Version:  ConfigVersion,
+		Settings: settings,
+		Letters:  []LetterConfig{},
+		Users:    []UserConfig{},
+	}
+
+	rows, err := db.Query("SELECT char, case_policy FROM letters ORDER BY char")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var letter LetterConfig
+		var policy sql.NullString
+		if err := rows.Scan(&letter.Char, &policy); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		letter.Case = rules.CasePolicy(policy.String)
+		cfg.Letters = append(cfg.Letters, letter)
+	}
+	rows.Close()
+
+	users, err := GetAllUsers(db)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(users)
+	for _, name := range users {
+		user, err := exportUser(db, name)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Users = append(cfg.Users, user)
+	}
+
+	edges, err := GetTakeGrantEdges(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, edge := range edges {
+		cfg.Edges = append(cfg.Edges, edgeConfig(edge))
+	}
+	sortEdges(cfg.Edges)
+
+	return cfg, nil
+}
+
+func exportUser(db Querier, name string) (UserConfig, error) {
+	user := UserConfig{Name: name, Letters: []string{}}
+
+	userID, err := GetUserID(db, name)
+	if err != nil {
+		return user, err
+	}
+
+	letters, err := GetPermissions(db, userID)
+	if err != nil {
+		return user, err
+	}
+	sort.Strings(letters)
+	user.Letters = append(user.Letters, letters...)
+
+	expiries, err := GetGrantExpiries(db, userID)
+	if err != nil {
+		return user, err
+	}
+	if len(expiries) > 0 {
+		user.Expires = expiries
+	}
+
+	accessRules, err := GetRules(db, userID)
+	if err != nil {
+		return user, err
+	}
+	for _, accessRule := range accessRules {
+		user.Rules = append(user.Rules, accessRule.Rule.String())
+	}
+	return user, nil
+}
+
+func edgeConfig(edge TakeGrantEdge) EdgeConfig {
+	if edge.ToLetter {
+		return EdgeConfig{From: edge.From, Letter: edge.To, Label: edge.Label}
+	}
+	return EdgeConfig{From: edge.From, User: edge.To, Label: edge.Label}
+}
+
+func sortEdges(edges []EdgeConfig) {
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		if a.Letter != b.Letter {
+			return a.Letter < b.Letter
+		}
+		return a.Label < b.Label
+	})
+}
+
+func (e EdgeConfig) String() string {
+	if e.Letter != "" {
+		return fmt.Sprintf("%s -%s-> буква %s", e.From, e.Label, e.Letter)
+	}
+	return fmt.Sprintf("%s -%s-> %s", e.From, e.Label, e.User)
+}
+
+// ImportMode определяет, что делать с объектами базы, которых нет
+// в импортируемой конфигурации.
+type ImportMode string
+
+const (
+	// ImportMerge добавляет недостающее и ничего не удаляет.
+	ImportMerge ImportMode = "merge"
+	// ImportReplace приводит базу в точное соответствие конфигурации.
+	ImportReplace ImportMode = "replace"
+)
+
+var ImportModes = []ImportMode{ImportMerge, ImportReplace}
+
+func ParseImportMode(s string) (ImportMode, error) {
+	for _, mode := range ImportModes {
+		if string(mode) == s {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("неизвестный режим импорта '%s' (допустимы merge и replace)", s)
+}
+
+type ChangeKind string
+
+const (
+	ChangeAdd    ChangeKind = "+"
+	ChangeRemove ChangeKind = "-"
+	ChangeUpdate ChangeKind = "~"
+)
+
+// Change - одно изменение базы при импорте.
+type Change struct {
+	Kind   ChangeKind
+	Object string
+}
+
+func (c Change) String() string {
+	return string(c.Kind) + " " + c.Object
+}
+
+// Import загружает конфигурацию в базу в одной транзакции и возвращает
+// список изменений. При dryRun транзакция откатывается, и база
+// не меняется: список показывает, что изменил бы импорт.
+func Import(db *sql.DB, cfg *Config, mode ImportMode, dryRun bool) ([]Change, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	changes, err := ImportTx(tx, cfg, mode)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return changes, nil
+	}
+	return changes, tx.Commit()
+}
+
+// ImportTx загружает конфигурацию внутри транзакции, начатой вызывающим.
+func ImportTx(tx *sql.Tx, cfg *Config, mode ImportMode) ([]Change, error) {
+	if err := cfg.normalize(); err != nil {
+		return nil, err
+	}
+	return importConfig(tx, cfg, mode)
+}
+
+// normalize проверяет конфигурацию и приводит буквы и правила к той форме,
+// в которой они хранятся в базе.
+func (cfg *Config) normalize() error {
+	for key, value := range cfg.Settings {
+		if err := checkSetting(key, value); err != nil {
+			return err
+		}
+	}
+
+	for i, letter := range cfg.Letters {
+		char, err := normalizeLetter(letter.Char)
+		if err != nil {
+			return err
+		}
+		if letter.Case != "" {
+			if _, err := rules.ParseCasePolicy(string(letter.Case)); err != nil {
+				return fmt.Errorf("буква '%s': %v", char, err)
+			}
+		}
+		cfg.Letters[i].Char = char
+	}
+
+	names := make(map[string]bool, len(cfg.Users))
+	for i, user := range cfg.Users {
+		if strings.TrimSpace(user.Name) == "" {
+			return fmt.Errorf("пользователь #%d без имени", i+1)
+		}
+		if names[user.Name] {
+			return fmt.Errorf("пользователь '%s' указан дважды", user.Name)
+		}
+		names[user.Name] = true
+
+		for j, letter := range user.Letters {
+			char, err := normalizeLetter(letter)
+			if err != nil {
+				return fmt.Errorf("пользователь '%s': %v", user.Name, err)
+			}
+			cfg.Users[i].Letters[j] = char
+		}
+		for j, expr := range user.Rules {
+			rule, err := rules.Parse(expr)
+			if err != nil {
+				return fmt.Errorf("пользователь '%s': %v", user.Name, err)
+			}
+			cfg.Users[i].Rules[j] = rule.String()
+		}
+	}
+
+	for i, edge := range cfg.Edges {
+		if (edge.User == "") == (edge.Letter == "") {
+			return fmt.Errorf("ребро от '%s' должно вести либо к пользователю, либо к букве", edge.From)
+		}
+		kind := TargetUser
+		if edge.Letter != "" {
+			kind = TargetLetter
+			char, err := normalizeLetter(edge.Letter)
+			if err != nil {
+				return err
+			}
+			cfg.Edges[i].Letter = char
+		}
+		if err := checkEdge(kind, edge.Label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkSetting(key, value string) error {
+	var err error
+	switch key {
+	case SettingAlphabet:
+		_, err = rules.ParseAlphabet(value)
+	case SettingWhitespace:
+		_, err = rules.ParseWhitespacePolicy(value)
+	case SettingCase:
+		_, err = rules.ParseCasePolicy(value)
+	case SettingIdle, SettingBackupInterval:
+		if minutes, convErr := strconv.Atoi(value); convErr != nil || minutes < 0 {
+			err = fmt.Errorf("ожидается неотрицательное число минут, получено '%s'", value)
+		}
+	case SettingBackupKeep:
+		if keep, convErr := strconv.Atoi(value); convErr != nil || keep < 1 {
+			err = fmt.Errorf("ожидается положительное число, получено '%s'", value)
+		}
+	case SettingApproval:
+		_, err = strconv.ParseBool(value)
+	case SettingBackupDir:
+	default:
+		return fmt.Errorf("неизвестная настройка '%s'", key)
+	}
+	if err != nil {
+		return fmt.Errorf("настройка %s: %v", key, err)
+	}
+	return nil
+}
+
+// grantLetter выдаёт право бессрочно или до until.
+func grantLetter(db Querier, userID, letterID int, until time.Time, temporary bool) error {
+	if temporary {
+		return GrantUntil(db, userID, letterID, until)
+	}
+	return Grant(db, userID, letterID)
+}
+
+func expiryName(until time.Time, temporary bool) string {
+	if !temporary {
+		return "бессрочно"
+	}
+	return "до " + until.Format(reportTimeLayout)
+}
+
+func expirySuffix(until time.Time, temporary bool) string {
+	if !temporary {
+		return ""
+	}
+	return " (" + expiryName(until, temporary) + ")"
+}
+
+func casePolicyName(policy rules.CasePolicy) string {
+	if policy == "" {
+		return "по умолчанию"
+	}
+	return string(policy)
+}
+
+func importConfig(db Querier, cfg *Config, mode ImportMode) ([]Change, error) {
+	var changes []Change
+	change := func(kind ChangeKind, format string, args ...any) {
+		changes = append(changes, Change{Kind: kind, Object: fmt.Sprintf(format, args...)})
+	}
+
+	current, err := Export(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Настройки
+	keys := make([]string, 0, len(cfg.Settings))
+	for key := range cfg.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := cfg.Settings[key]
+		old, ok := current.Settings[key]
+		if ok && old == value {
+			continue
+		}
+		if err := SetSetting(db, key, value); err != nil {
+			return nil, fmt.Errorf("настройка %s: %v", key, err)
+		}
+		if ok {
+			change(ChangeUpdate, "настройка %s: %s → %s", key, old, value)
+		} else {
+			change(ChangeAdd, "настройка %s = %s", key, value)
+		}
+	}
+	if mode == ImportReplace {
+		keys = keys[:0]
+		for key := range current.Settings {
+			if _, ok := cfg.Settings[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			// Удаление настройки двойного одобрения отключает его
+			if key == SettingApproval {
+				if err := checkApproved(db); err != nil {
+					return nil, fmt.Errorf("настройка %s: %v", key, err)
+				}
+			}
+			if _, err := db.Exec("DELETE FROM settings WHERE key = ?", key); err != nil {
+				return nil, err
+			}
+			change(ChangeRemove, "настройка %s (значение по умолчанию)", key)
+		}
+	}
+
+	// Пользователи, которых нет в конфигурации, удаляются вместе с их
+	// правами, правилами и рёбрами
+	wanted := make(map[string]bool, len(cfg.Users))
+	for _, user := range cfg.Users {
+		wanted[user.Name] = true
+	}
+	existing := make(map[string]UserConfig, len(current.Users))
+	for _, user := range current.Users {
+		existing[user.Name] = user
+		if mode != ImportReplace || wanted[user.Name] {
+			continue
+		}
+		userID, err := GetUserID(db, user.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := DeleteUser(db, userID); err != nil {
+			return nil, fmt.Errorf("удаление пользователя %s: %v", user.Name, err)
+		}
+		change(ChangeRemove, "пользователь %s", user.Name)
+	}
+
+	// Буквы
+	letters := make(map[string]rules.CasePolicy, len(current.Letters))
+	for _, letter := range current.Letters {
+		letters[letter.Char] = letter.Case
+	}
+	needed := make(map[string]bool)
+	for _, letter := range cfg.Letters {
+		needed[letter.Char] = true
+		old, ok := letters[letter.Char]
+		if ok && old == letter.Case {
+			continue
+		}
+		letterID, err := EnsureLetterExists(db, letter.Char)
+		if err != nil {
+			return nil, err
+		}
+		if err := SetLetterCasePolicy(db, letterID, letter.Case); err != nil {
+			return nil, err
+		}
+		if ok {
+			change(ChangeUpdate, "регистр буквы %s: %s → %s", letter.Char, casePolicyName(old), casePolicyName(letter.Case))
+		} else {
+			change(ChangeAdd, "буква %s (регистр: %s)", letter.Char, casePolicyName(letter.Case))
+		}
+		letters[letter.Char] = letter.Case
+	}
+	ensureLetter := func(char string) (int, error) {
+		needed[char] = true
+		if _, ok := letters[char]; !ok {
+			letters[char] = ""
+			change(ChangeAdd, "буква %s (регистр: %s)", char, casePolicyName(""))
+		}
+		return EnsureLetterExists(db, char)
+	}
+
+	// Права и правила пользователей
+	for _, user := range cfg.Users {
+		old, ok := existing[user.Name]
+		if !ok {
+			change(ChangeAdd, "пользователь %s", user.Name)
+		}
+		userID, err := CreateUser(db, user.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		granted := make(map[string]bool, len(old.Letters))
+		for _, letter := range old.Letters {
+			granted[letter] = true
+		}
+		listed := make(map[string]bool, len(user.Letters))
+		for _, letter := range user.Letters {
+			listed[letter] = true
+		}
+		for letter := range user.Expires {
+			if !listed[letter] {
+				return nil, fmt.Errorf("пользователь %s: срок задан для невыданной буквы %s", user.Name, letter)
+			}
+		}
+		now := time.Now()
+		keep := make(map[string]bool, len(user.Letters))
+		for _, letter := range user.Letters {
+			until, temporary := user.Expires[letter]
+			// Истёкшее временное право не восстанавливается
+			if keep[letter] || (temporary && !until.After(now)) {
+				continue
+			}
+			keep[letter] = true
+			letterID, err := ensureLetter(letter)
+			if err != nil {
+				return nil, err
+			}
+
+			oldUntil, wasTemporary := old.Expires[letter]
+			switch {
+			case !granted[letter]:
+				if err := grantLetter(db, userID, letterID, until, temporary); err != nil {
+					return nil, err
+				}
+				change(ChangeAdd, "право %s: %s%s", user.Name, letter, expirySuffix(until, temporary))
+			case temporary == wasTemporary && until.Equal(oldUntil):
+				// Срок не изменился
+			case mode == ImportMerge && temporary && !(wasTemporary && until.After(oldUntil)):
+				// При слиянии срок права только продлевается
+			default:
+				if mode == ImportReplace && temporary {
+					// GrantUntil не сокращает срок, поэтому право выдаётся заново
+					if err := Remove(db, userID, letterID); err != nil {
+						return nil, err
+					}
+				}
+				if err := grantLetter(db, userID, letterID, until, temporary); err != nil {
+					return nil, err
+				}
+				change(ChangeUpdate, "срок права %s: %s: %s → %s", user.Name, letter,
+					expiryName(oldUntil, wasTemporary), expiryName(until, temporary))
+			}
+			granted[letter] = true
+		}
+		if mode == ImportReplace {
+			for _, letter := range old.Letters {
+				if keep[letter] {
+					continue
+				}
+				letterID, err := GetLetterID(db, letter)
+				if err != nil {
+					return nil, err
+				}
+				if err := Remove(db, userID, letterID); err != nil {
+					return nil, err
+				}
+				change(ChangeRemove, "право %s: %s", user.Name, letter)
+			}
+		}
+
+		accessRules, err := GetRules(db, userID)
+		if err != nil {
+			return nil, err
+		}
+		present := make(map[string]bool, len(accessRules))
+		for _, accessRule := range accessRules {
+			present[accessRule.Rule.String()] = true
+		}
+		keep = make(map[string]bool, len(user.Rules))
+		for _, expr := range user.Rules {
+			keep[expr] = true
+			if present[expr] {
+				continue
+			}
+			present[expr] = true
+			if err := AddRule(db, userID, expr); err != nil {
+				return nil, err
+			}
+			change(ChangeAdd, "правило %s: %s", user.Name, expr)
+		}
+		if mode == ImportReplace {
+			for _, accessRule := range accessRules {
+				expr := accessRule.Rule.String()
+				if keep[expr] {
+					continue
+				}
+				if err := RemoveRule(db, accessRule.ID); err != nil {
+					return nil, err
+				}
+				change(ChangeRemove, "правило %s: %s", user.Name, expr)
+			}
+		}
+	}
+
+	// Рёбра Take-Grant. Текущие рёбра перечитываются: удаление
+	// пользователей уже убрало связанные с ними рёбра.
+	edges, err := GetTakeGrantEdges(db)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[EdgeConfig]bool, len(edges))
+	for _, edge := range edges {
+		present[edgeConfig(edge)] = true
+	}
+	keep := make(map[EdgeConfig]bool, len(cfg.Edges))
+	for _, edge := range cfg.Edges {
+		keep[edge] = true
+		if present[edge] {
+			continue
+		}
+		present[edge] = true
+		if err := setEdge(db, edge, ensureLetter, AddTakeGrantEdge); err != nil {
+			return nil, err
+		}
+		change(ChangeAdd, "ребро %s", edge)
+	}
+	if mode == ImportReplace {
+		var removed []EdgeConfig
+		for _, edge := range edges {
+			if config := edgeConfig(edge); !keep[config] {
+				removed = append(removed, config)
+			}
+		}
+		sortEdges(removed)
+		for _, edge := range removed {
+			findLetter := func(char string) (int, error) { return GetLetterID(db, char) }
+			if err := setEdge(db, edge, findLetter, RemoveTakeGrantEdge); err != nil {
+				return nil, err
+			}
+			change(ChangeRemove, "ребро %s", edge)
+		}
+	}
+
+	// Буквы, не упомянутые в конфигурации, удаляются последними, когда
+	// права на них уже пересчитаны
+	if mode == ImportReplace {
+		for _, letter := range current.Letters {
+			if needed[letter.Char] {
+				continue
+			}
+			letterID, err := GetLetterID(db, letter.Char)
+			if err != nil {
+				return nil, err
+			}
+			if err := DeleteLetter(db, letterID); err != nil {
+				return nil, fmt.Errorf("удаление буквы %s: %v", letter.Char, err)
+			}
+			change(ChangeRemove, "буква %s", letter.Char)
+		}
+	}
+
+	return changes, nil
+}
+
+// setEdge находит вершины ребра в базе и вызывает apply (добавление или
+// удаление ребра).
+func setEdge(db Querier, edge EdgeConfig, letterID func(string) (int, error),
+	apply func(db Querier, fromUserID int, targetKind string, targetID int, label string) error) error {
+	fromID, err := GetUserID(db, edge.From)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("ребро %s: пользователь '%s' не найден", edge, edge.From)
+	}
+	if err != nil {
+		return err
+	}
+
+	kind, targetID := TargetLetter, 0
+	if edge.Letter != "" {
+		targetID, err = letterID(edge.Letter)
+	} else {
+		kind = TargetUser
+		targetID, err = GetUserID(db, edge.User)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("ребро %s: пользователь '%s' не найден", edge, edge.User)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return apply(db, fromID, kind, targetID, edge.Label)
+}
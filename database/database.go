@@ -3,11 +3,22 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"laba3/grapheme"
 	"log"
+	"os"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+// Querier - общее подмножество *sql.DB и *sql.Tx. Функции пакета
+// принимают Querier, чтобы их можно было выполнять внутри транзакции.
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 func Init(dbPath string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite", dbPath)
 
@@ -25,6 +36,10 @@ func Init(dbPath string) (*sql.DB, error) {
 		log.Println("База данных подключена")
 	}
 
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
@@ -32,7 +47,40 @@ func Init(dbPath string) (*sql.DB, error) {
 	return db, nil
 }
 
-func tablesExist(db *sql.DB) bool {
+// OpenReadOnly открывает существующую базу только для чтения, не применяя
+// миграции. Схема базы должна соответствовать версии программы: устаревшую
+// базу нужно сначала открыть приложением администратора, которое её
+// обновит.
+func OpenReadOnly(dbPath string) (*sql.DB, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("база данных %s недоступна: %v", dbPath, err)
+	}
+	db, err := sql.Open("sqlite", readOnlyURI(dbPath))
+	if err != nil {
+		return nil, err
+	}
+
+	if !tablesExist(db) {
+		db.Close()
+		return nil, fmt.Errorf("файл %s не содержит таблиц системы доступа", dbPath)
+	}
+	version, err := SchemaVersion(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version < len(migrations) {
+		db.Close()
+		return nil, fmt.Errorf("схема базы данных устарела (версия %d, требуется %d): откройте базу приложением администратора, чтобы обновить её", version, len(migrations))
+	}
+	if version > len(migrations) {
+		db.Close()
+		return nil, fmt.Errorf("база данных создана более новой версией программы (схема %d, поддерживается %d)", version, len(migrations))
+	}
+	return db, nil
+}
+
+func tablesExist(db Querier) bool {
 	tables := []string{"users", "letters", "user_letters"}
 
 	for _, table := range tables {
@@ -46,7 +94,7 @@ func tablesExist(db *sql.DB) bool {
 	return true
 }
 
-func createTables(db *sql.DB) error {
+func createTables(db Querier) error {
 	queries := []string{
 		`
 		CREATE TABLE IF NOT EXISTS users (
@@ -77,21 +125,24 @@ func createTables(db *sql.DB) error {
 	return nil
 }
 
-func GetUserID(db *sql.DB, userName string) (int, error) {
+func GetUserID(db Querier, userName string) (int, error) {
 	var id int
 	err := db.QueryRow("SELECT id FROM users WHERE name = ?", userName).Scan(&id)
 	return id, err
 }
 
-func Grant(db *sql.DB, UserID int, LetterID int) error {
+// Grant выдаёт бессрочное право; временное право на ту же букву
+// становится бессрочным.
+func Grant(db Querier, UserID int, LetterID int) error {
 	_, err := db.Exec(
-		"INSERT OR IGNORE INTO user_letters (user_id, letter_id) VALUES (?, ?)",
+		`INSERT INTO user_letters (user_id, letter_id) VALUES (?, ?)
+		 ON CONFLICT (user_id, letter_id) DO UPDATE SET expires_at = NULL`,
 		UserID, LetterID,
 	)
 	return err
 }
 
-func CreateUser(db *sql.DB, name string) (UserID int, err error) {
+func CreateUser(db Querier, name string) (UserID int, err error) {
 	_, err = db.Exec("INSERT OR IGNORE INTO users (name) VALUES (?)", name)
 	if err != nil {
 		return 0, err
@@ -101,17 +152,30 @@ func CreateUser(db *sql.DB, name string) (UserID int, err error) {
 	return UserID, err
 }
 
-func CreateLetter(db *sql.DB, letter rune) (LetterID int, err error) {
-	letterStr := string(letter)
+// normalizeLetter приводит букву к NFC и проверяет, что она является
+// ровно одним кластером графем.
+func normalizeLetter(letter string) (string, error) {
+	letter = grapheme.Normalize(letter)
+	if !grapheme.IsSingle(letter) {
+		return "", fmt.Errorf("'%s' не является одним символом", letter)
+	}
+	return letter, nil
+}
+
+func CreateLetter(db Querier, letter string) (LetterID int, err error) {
+	letterStr, err := normalizeLetter(letter)
+	if err != nil {
+		return 0, err
+	}
 	_, err = db.Exec("INSERT OR IGNORE INTO letters (char) VALUES (?)", letterStr)
 	if err != nil {
 		return 0, err
 	}
-	LetterID, err = GetLetterID(db, letter)
+	LetterID, err = GetLetterID(db, letterStr)
 	return LetterID, err
 }
 
-func Create(db *sql.DB, name string, letters ...rune) error {
+func Create(db Querier, name string, letters ...string) error {
 	UserID, err := CreateUser(db, name)
 	if err != nil {
 		return err
@@ -131,7 +195,7 @@ func Create(db *sql.DB, name string, letters ...rune) error {
 	return nil
 }
 
-func Remove(db *sql.DB, UserID int, LetterID int) error {
+func Remove(db Querier, UserID int, LetterID int) error {
 	_, err := db.Exec(
 		"DELETE FROM user_letters WHERE user_id = ? AND letter_id = ?",
 		UserID, LetterID,
@@ -139,21 +203,27 @@ func Remove(db *sql.DB, UserID int, LetterID int) error {
 	return err
 }
 
-func GrantAll(db *sql.DB, UserID int) error {
+// GrantAll выдаёт пользователю все буквы. При включённом двойном
+// одобрении выполняется только из ApproveChange.
+func GrantAll(db Querier, UserID int) error {
+	if err := checkApproved(db); err != nil {
+		return err
+	}
 	_, err := db.Exec(
-		`INSERT OR IGNORE INTO user_letters (user_id, letter_id)
-         SELECT ?, id FROM letters`,
+		`INSERT INTO user_letters (user_id, letter_id)
+         SELECT ?, id FROM letters WHERE true
+         ON CONFLICT (user_id, letter_id) DO UPDATE SET expires_at = NULL`,
 		UserID,
 	)
 	return err
 }
 
-func RemoveAll(db *sql.DB, UserID int) error {
+func RemoveAll(db Querier, UserID int) error {
 	_, err := db.Exec("DELETE FROM user_letters WHERE user_id = ?", UserID)
 	return err
 }
 
-func FindUser(db *sql.DB, userName string) (UserID int, err error) {
+func FindUser(db Querier, userName string) (UserID int, err error) {
 	var id int
 	err = db.QueryRow("SELECT id FROM users WHERE name = ?", userName).Scan(&id)
 	if err != nil {
@@ -162,13 +232,13 @@ func FindUser(db *sql.DB, userName string) (UserID int, err error) {
 	return id, nil
 }
 
-func GetPermissions(db *sql.DB, UserID int) (AccessableLetters []string, err error) {
+func GetPermissions(db Querier, UserID int) (AccessableLetters []string, err error) {
 	rows, err := db.Query(`
         SELECT l.char 
         FROM letters l
         JOIN user_letters ul ON l.id = ul.letter_id
-        WHERE ul.user_id = ?
-    `, UserID)
+        WHERE ul.user_id = ? AND (ul.expires_at IS NULL OR ul.expires_at > ?)
+    `, UserID, time.Now().Unix())
 
 	if err != nil {
 		return nil, err
@@ -187,14 +257,14 @@ func GetPermissions(db *sql.DB, UserID int) (AccessableLetters []string, err err
 	return letters, nil
 }
 
-func GetLetterID(db *sql.DB, letterChar rune) (int, error) {
+func GetLetterID(db Querier, letterChar string) (int, error) {
 	var id int
-	letterStr := string(letterChar)
+	letterStr := grapheme.Normalize(letterChar)
 	err := db.QueryRow("SELECT id FROM letters WHERE char = ?", letterStr).Scan(&id)
 	return id, err
 }
 
-func GetAllUsers(db *sql.DB) ([]string, error) {
+func GetAllUsers(db Querier) ([]string, error) {
 	rows, err := db.Query("SELECT name FROM users")
 	if err != nil {
 		return nil, err
@@ -212,7 +282,7 @@ func GetAllUsers(db *sql.DB) ([]string, error) {
 	return users, nil
 }
 
-func GetAllLetters(db *sql.DB) ([]string, error) {
+func GetAllLetters(db Querier) ([]string, error) {
 	rows, err := db.Query("SELECT char FROM letters")
 	if err != nil {
 		return nil, err
@@ -230,33 +300,79 @@ func GetAllLetters(db *sql.DB) ([]string, error) {
 	return letters, nil
 }
 
-func DeleteUser(db *sql.DB, userID int) error {
+// DeleteUser удаляет пользователя вместе с правами, правилами, рёбрами,
+// сеансами и запросами доступа. При включённом двойном одобрении
+// выполняется только из ApproveChange.
+func DeleteUser(db Querier, userID int) error {
+	if err := checkApproved(db); err != nil {
+		return err
+	}
 	_, err := db.Exec("DELETE FROM user_letters WHERE user_id = ?", userID)
 	if err != nil {
 		return err
 	}
 
+	_, err = db.Exec(
+		"DELETE FROM tg_edges WHERE src_user = ? OR (dst_kind = ? AND dst_id = ?)",
+		userID, TargetUser, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM letter_rules WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM access_requests WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
 	_, err = db.Exec("DELETE FROM users WHERE id = ?", userID)
 	return err
 }
 
-func UpdateUserName(db *sql.DB, userID int, newName string) error {
-	_, err := db.Exec("UPDATE users SET name = ? WHERE id = ?", newName, userID)
+func UpdateUserName(db Querier, userID int, newName string) error {
+	_, err := db.Exec("UPDATE users SET name = ?, revision = revision + 1 WHERE id = ?", newName, userID)
 	return err
 }
 
-func DeleteLetter(db *sql.DB, letterID int) error {
+// GetUserRevision возвращает текущее имя и ревизию пользователя. Если
+// пользователь удалён, возвращается sql.ErrNoRows.
+func GetUserRevision(db Querier, userID int) (name string, revision int, err error) {
+	err = db.QueryRow("SELECT name, revision FROM users WHERE id = ?", userID).Scan(&name, &revision)
+	return name, revision, err
+}
+
+// DeleteLetter удаляет букву вместе с правами и рёбрами на неё. При
+// включённом двойном одобрении выполняется только из ApproveChange.
+func DeleteLetter(db Querier, letterID int) error {
+	if err := checkApproved(db); err != nil {
+		return err
+	}
 	_, err := db.Exec("DELETE FROM user_letters WHERE letter_id = ?", letterID)
 	if err != nil {
 		return err
 	}
 
+	_, err = db.Exec("DELETE FROM tg_edges WHERE dst_kind = ? AND dst_id = ?", TargetLetter, letterID)
+	if err != nil {
+		return err
+	}
+
 	_, err = db.Exec("DELETE FROM letters WHERE id = ?", letterID)
 	return err
 }
 
-func EnsureLetterExists(db *sql.DB, letter rune) (int, error) {
-	letterStr := string(letter)
+func EnsureLetterExists(db Querier, letter string) (int, error) {
+	letterStr := grapheme.Normalize(letter)
 	var id int
 	err := db.QueryRow("SELECT id FROM letters WHERE char = ?", letterStr).Scan(&id)
 	if err != nil {
@@ -265,14 +381,17 @@ func EnsureLetterExists(db *sql.DB, letter rune) (int, error) {
 	return id, nil
 }
 
-func UpdateLetter(db *sql.DB, letterID int, newLetter rune) error {
-	newLetterStr := string(newLetter)
+func UpdateLetter(db Querier, letterID int, newLetter string) error {
+	newLetterStr, err := normalizeLetter(newLetter)
+	if err != nil {
+		return err
+	}
 
 	// 1. Проверяем, не существует ли УЖЕ буква, в которую мы переименовываем
 	var existingID int
 
 	// ИЗМЕНЕНО: было "WHERE letter = ?"
-	err := db.QueryRow("SELECT id FROM letters WHERE char = ?", newLetterStr).Scan(&existingID)
+	err = db.QueryRow("SELECT id FROM letters WHERE char = ?", newLetterStr).Scan(&existingID)
 
 	if err == nil {
 		// Буква найдена.
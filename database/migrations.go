@@ -0,0 +1,301 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/grapheme"
+	"log"
+)
+
+type migration func(tx *sql.Tx) error
+
+func execMigration(query string) migration {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// Миграции схемы применяются по порядку поверх базовых таблиц. Номер
+// последней применённой миграции хранится в PRAGMA user_version, поэтому
+// новые миграции добавляются только в конец списка.
+var migrations = []migration{
+	// 1: рёбра take/grant модели Take-Grant
+	execMigration(`CREATE TABLE IF NOT EXISTS tg_edges (
+		src_user INTEGER NOT NULL,
+		dst_kind TEXT NOT NULL CHECK (dst_kind IN ('user', 'letter')),
+		dst_id INTEGER NOT NULL,
+		label TEXT NOT NULL CHECK (label IN ('t', 'g')),
+		PRIMARY KEY (src_user, dst_kind, dst_id, label),
+		FOREIGN KEY (src_user) REFERENCES users(id)
+	);`),
+	// 2: атрибутные правила доступа к классам символов
+	execMigration(`CREATE TABLE IF NOT EXISTS letter_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		expr TEXT NOT NULL,
+		UNIQUE (user_id, expr),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`),
+	// 3: настройки политики доступа
+	execMigration(`CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`),
+	// 4: приведение существующих букв к NFC
+	normalizeStoredLetters,
+	// 5: политика регистра отдельной буквы (NULL - глобальная настройка)
+	execMigration(`ALTER TABLE letters ADD COLUMN case_policy TEXT
+		CHECK (case_policy IN ('exact', 'insensitive', 'upper'));`),
+	// 6: пароль пользователя (NULL - вход без пароля)
+	execMigration(`ALTER TABLE users ADD COLUMN password_hash TEXT;`),
+	// 7: сеансы приложения пользователя (время - в секундах Unix)
+	execMigration(`CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		login_at INTEGER NOT NULL,
+		last_active INTEGER NOT NULL,
+		host TEXT NOT NULL,
+		pid INTEGER NOT NULL,
+		ended_at INTEGER,
+		terminated INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`),
+	// 8: ревизия пользователя, увеличивается при переименовании
+	execMigration(`ALTER TABLE users ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;`),
+	// 9: состояние базы после последней декларативной синхронизации
+	execMigration(`CREATE TABLE IF NOT EXISTS sync_state (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		applied_at INTEGER NOT NULL,
+		source TEXT NOT NULL,
+		config TEXT NOT NULL
+	);`),
+	// 10: именованные снимки матрицы доступа
+	execMigration(`CREATE TABLE IF NOT EXISTS snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		created_at INTEGER NOT NULL,
+		data TEXT NOT NULL
+	);`),
+	// 11: запросы на операции, требующие одобрения второго администратора
+	execMigration(`CREATE TABLE IF NOT EXISTS change_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		operation TEXT NOT NULL CHECK (operation IN ('grant_all', 'delete_user', 'delete_letter')),
+		target_id INTEGER NOT NULL,
+		target TEXT NOT NULL,
+		requested_by TEXT NOT NULL,
+		requested_at INTEGER NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+		decided_by TEXT,
+		decided_at INTEGER,
+		CHECK (status <> 'approved' OR decided_by <> requested_by)
+	);`),
+	// 12: запросы доступа от пользователей
+	execMigration(`CREATE TABLE IF NOT EXISTS access_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		letter TEXT NOT NULL,
+		justification TEXT NOT NULL,
+		requested_at INTEGER NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+		decided_by TEXT,
+		decided_at INTEGER,
+		expires_at INTEGER,
+		comment TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`),
+	// 13: срок действия права (NULL - бессрочно)
+	execMigration(`ALTER TABLE user_letters ADD COLUMN expires_at INTEGER;`),
+	// 14: кампании пересмотра прав
+	execMigration(`CREATE TABLE IF NOT EXISTS campaigns (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		started_by TEXT NOT NULL,
+		started_at INTEGER NOT NULL,
+		deadline INTEGER NOT NULL,
+		auto_revoke INTEGER NOT NULL DEFAULT 0,
+		closed_at INTEGER
+	);`),
+	// 15: права, попавшие в кампанию, и решения по ним (NULL - не рассмотрено)
+	execMigration(`CREATE TABLE IF NOT EXISTS campaign_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		campaign_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		user_name TEXT NOT NULL,
+		letter_id INTEGER NOT NULL,
+		letter TEXT NOT NULL,
+		decision TEXT CHECK (decision IN ('confirmed', 'revoked', 'auto_revoked')),
+		reviewer TEXT,
+		decided_at INTEGER,
+		UNIQUE (campaign_id, user_id, letter_id),
+		FOREIGN KEY (campaign_id) REFERENCES campaigns(id)
+	);`),
+	// 16: проверка двойного одобрения в самой базе. Отключение настройки
+	// тоже требует одобрения; пока выполняется одобренный запрос, его номер
+	// лежит в approved_changes, иначе триггеры отклоняют удаление
+	// пользователей и букв и отключение настройки
+	execMigration(`CREATE TABLE change_requests_new (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		operation TEXT NOT NULL CHECK (operation IN ('grant_all', 'delete_user', 'delete_letter', 'disable_approval')),
+		target_id INTEGER NOT NULL,
+		target TEXT NOT NULL,
+		requested_by TEXT NOT NULL,
+		requested_at INTEGER NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+		decided_by TEXT,
+		decided_at INTEGER,
+		CHECK (status <> 'approved' OR decided_by <> requested_by)
+	);
+	INSERT INTO change_requests_new SELECT * FROM change_requests;
+	DROP TABLE change_requests;
+	ALTER TABLE change_requests_new RENAME TO change_requests;
+
+	CREATE TABLE approved_changes (
+		request_id INTEGER PRIMARY KEY,
+		FOREIGN KEY (request_id) REFERENCES change_requests(id)
+	);
+
+	CREATE TRIGGER approval_delete_user BEFORE DELETE ON users
+	WHEN ` + approvalPending + `
+	BEGIN SELECT RAISE(ABORT, 'удаление пользователя требует одобрения второго администратора'); END;
+
+	CREATE TRIGGER approval_delete_letter BEFORE DELETE ON letters
+	WHEN ` + approvalPending + `
+	BEGIN SELECT RAISE(ABORT, 'удаление буквы требует одобрения второго администратора'); END;
+
+	CREATE TRIGGER approval_update_setting BEFORE UPDATE ON settings
+	WHEN OLD.key = 'two_person_approval' AND lower(OLD.value) IN ('1', 't', 'true')
+		AND NOT (NEW.key = OLD.key AND lower(NEW.value) IN ('1', 't', 'true'))
+		AND NOT EXISTS (SELECT 1 FROM approved_changes)
+	BEGIN SELECT RAISE(ABORT, 'отключение двойного одобрения требует одобрения второго администратора'); END;
+
+	CREATE TRIGGER approval_delete_setting BEFORE DELETE ON settings
+	WHEN OLD.key = 'two_person_approval' AND lower(OLD.value) IN ('1', 't', 'true')
+		AND NOT EXISTS (SELECT 1 FROM approved_changes)
+	BEGIN SELECT RAISE(ABORT, 'отключение двойного одобрения требует одобрения второго администратора'); END;`),
+	// 17: запросы доступа удалённых пользователей
+	execMigration(`DELETE FROM access_requests WHERE user_id NOT IN (SELECT id FROM users);`),
+	// 18: учётные записи администраторов. При включённом двойном одобрении
+	// список администраторов, в котором уже есть двое, меняется только
+	// одобренным запросом
+	execMigration(`CREATE TABLE IF NOT EXISTS admins (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL
+	);
+
+	CREATE TRIGGER approval_insert_admin BEFORE INSERT ON admins
+	WHEN (SELECT COUNT(*) FROM admins) >= 2 AND ` + approvalPending + `
+	BEGIN SELECT RAISE(ABORT, 'добавление администратора требует одобрения второго администратора'); END;
+
+	CREATE TRIGGER approval_delete_admin BEFORE DELETE ON admins
+	WHEN ` + approvalPending + `
+	BEGIN SELECT RAISE(ABORT, 'удаление администратора требует одобрения второго администратора'); END;`),
+}
+
+// approvalPending - условие триггеров: двойное одобрение включено, а
+// одобренный запрос не выполняется. Значения настройки - те, которые
+// strconv.ParseBool считает истиной.
+const approvalPending = `(SELECT lower(value) FROM settings WHERE key = 'two_person_approval') IN ('1', 't', 'true')
+		AND NOT EXISTS (SELECT 1 FROM approved_changes)`
+
+func SchemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+func migrate(db *sql.DB) error {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := migrations[i](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("ошибка миграции %d: %v", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Применена миграция схемы %d", i+1)
+	}
+
+	return nil
+}
+
+// normalizeStoredLetters приводит буквы, добавленные до перехода на
+// кластеры графем, к NFC. Если нормализованная форма уже есть в таблице,
+// права и рёбра переносятся на неё, а дубликат удаляется.
+func normalizeStoredLetters(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, char FROM letters")
+	if err != nil {
+		return err
+	}
+
+	type storedLetter struct {
+		id   int
+		char string
+	}
+	var pending []storedLetter
+	for rows.Next() {
+		var letter storedLetter
+		if err := rows.Scan(&letter.id, &letter.char); err != nil {
+			rows.Close()
+			return err
+		}
+		if grapheme.Normalize(letter.char) != letter.char {
+			pending = append(pending, letter)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, letter := range pending {
+		normalized := grapheme.Normalize(letter.char)
+
+		var existingID int
+		err := tx.QueryRow("SELECT id FROM letters WHERE char = ?", normalized).Scan(&existingID)
+		if err == sql.ErrNoRows {
+			if _, err := tx.Exec("UPDATE letters SET char = ? WHERE id = ?", normalized, letter.id); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		queries := []string{
+			"INSERT OR IGNORE INTO user_letters (user_id, letter_id) SELECT user_id, ? FROM user_letters WHERE letter_id = ?",
+			"INSERT OR IGNORE INTO tg_edges (src_user, dst_kind, dst_id, label) SELECT src_user, dst_kind, ?, label FROM tg_edges WHERE dst_kind = 'letter' AND dst_id = ?",
+		}
+		for _, query := range queries {
+			if _, err := tx.Exec(query, existingID, letter.id); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM user_letters WHERE letter_id = ?", letter.id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM tg_edges WHERE dst_kind = 'letter' AND dst_id = ?", letter.id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM letters WHERE id = ?", letter.id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Пароли хранятся в виде "pbkdf2-sha256$итерации$соль$хеш".
+const (
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 600000
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+)
+
+var ErrWrongPassword = errors.New("неверный пароль")
+
+func hashPassword(password string, salt []byte, iterations int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, password, salt, iterations, passwordKeySize)
+}
+
+// SetPassword задаёт пароль пользователя. Пустой пароль снимает защиту:
+// пользователь снова входит только по имени.
+func SetPassword(db Querier, userID int, password string) error {
+	if password == "" {
+		_, err := db.Exec("UPDATE users SET password_hash = NULL WHERE id = ?", userID)
+		return err
+	}
+
+	encoded, err := encodePassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", encoded, userID)
+	return err
+}
+
+// encodePassword возвращает хеш пароля со случайной солью в формате,
+// в котором он хранится в базе.
+func encodePassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := hashPassword(password, salt, passwordIterations)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		passwordScheme,
+		strconv.Itoa(passwordIterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// HasPassword сообщает, задан ли пароль пользователя.
+func HasPassword(db Querier, userID int) (bool, error) {
+	var hash sql.NullString
+	err := db.QueryRow("SELECT password_hash FROM users WHERE id = ?", userID).Scan(&hash)
+	if err != nil {
+		return false, err
+	}
+	return hash.Valid, nil
+}
+
+// CheckPassword проверяет пароль пользователя. Если пароль не задан,
+// подходит любой.
+func CheckPassword(db Querier, userID int, password string) error {
+	var hash sql.NullString
+	err := db.QueryRow("SELECT password_hash FROM users WHERE id = ?", userID).Scan(&hash)
+	if err != nil {
+		return err
+	}
+	if !hash.Valid {
+		return nil
+	}
+	return verifyPassword(hash.String, password)
+}
+
+// verifyPassword сравнивает пароль с хешем, сохранённым encodePassword.
+func verifyPassword(encoded, password string) error {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return fmt.Errorf("неизвестный формат хеша пароля")
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return fmt.Errorf("неизвестный формат хеша пароля")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("неизвестный формат хеша пароля")
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return fmt.Errorf("неизвестный формат хеша пароля")
+	}
+
+	key, err := hashPassword(password, salt, iterations)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(key, expected) != 1 {
+		return ErrWrongPassword
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"fmt"
+	"laba3/rules"
+)
+
+type AccessRule struct {
+	ID   int
+	Rule rules.Rule
+}
+
+func AddRule(db Querier, userID int, expr string) error {
+	rule, err := rules.Parse(expr)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT OR IGNORE INTO letter_rules (user_id, expr) VALUES (?, ?)", userID, rule.String())
+	return err
+}
+
+func RemoveRule(db Querier, ruleID int) error {
+	_, err := db.Exec("DELETE FROM letter_rules WHERE id = ?", ruleID)
+	return err
+}
+
+func GetRules(db Querier, userID int) ([]AccessRule, error) {
+	rows, err := db.Query("SELECT id, expr FROM letter_rules WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []AccessRule
+	for rows.Next() {
+		var id int
+		var expr string
+		if err := rows.Scan(&id, &expr); err != nil {
+			return nil, err
+		}
+		rule, err := rules.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("правило #%d '%s' повреждено: %v", id, expr, err)
+		}
+		result = append(result, AccessRule{ID: id, Rule: rule})
+	}
+	return result, nil
+}
+
+// GetExpandedPermissions возвращает явно выданные буквы пользователя и
+// буквы из таблицы letters, разрешённые его правилами или политикой
+// регистра выданных букв. Используется для отображения: правила могут
+// разрешать и символы, которых нет в letters.
+func GetExpandedPermissions(db Querier, UserID int) ([]string, error) {
+	explicit, err := GetPermissions(db, UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessRules, err := GetRules(db, UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := GetPermissionSet(db, UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	letters, err := GetAllLetters(db)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(explicit))
+	for _, letter := range explicit {
+		seen[letter] = true
+	}
+
+	expanded := explicit
+	for _, letter := range letters {
+		if seen[letter] {
+			continue
+		}
+		if set.Contains(letter) || rules.MatchAny(ruleList(accessRules), letter) {
+			expanded = append(expanded, letter)
+		}
+	}
+	return expanded, nil
+}
+
+func ruleList(accessRules []AccessRule) []rules.Rule {
+	list := make([]rules.Rule, 0, len(accessRules))
+	for _, accessRule := range accessRules {
+		list = append(list, accessRule.Rule)
+	}
+	return list
+}
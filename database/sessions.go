@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"time"
+)
+
+// Session - сеанс пользователя в приложении пользователя.
+type Session struct {
+	ID         int
+	UserID     int
+	UserName   string
+	LoginAt    time.Time
+	LastActive time.Time
+	Host       string
+	PID        int
+}
+
+// StartSession регистрирует вход пользователя с текущего хоста и процесса.
+func StartSession(db Querier, userID int) (int, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "неизвестно"
+	}
+
+	now := time.Now().Unix()
+	result, err := db.Exec(
+		"INSERT INTO sessions (user_id, login_at, last_active, host, pid) VALUES (?, ?, ?, ?, ?)",
+		userID, now, now, host, os.Getpid(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// TouchSession записывает время последней активности пользователя.
+func TouchSession(db Querier, sessionID int, lastActive time.Time) error {
+	_, err := db.Exec("UPDATE sessions SET last_active = ? WHERE id = ?", lastActive.Unix(), sessionID)
+	return err
+}
+
+// EndSession отмечает штатное завершение сеанса.
+func EndSession(db Querier, sessionID int) error {
+	_, err := db.Exec("UPDATE sessions SET ended_at = ? WHERE id = ? AND ended_at IS NULL", time.Now().Unix(), sessionID)
+	return err
+}
+
+// TerminateSession завершает сеанс по требованию администратора. Приложение
+// пользователя обнаруживает это при следующем обновлении прав.
+func TerminateSession(db Querier, sessionID int) error {
+	_, err := db.Exec("UPDATE sessions SET terminated = 1, ended_at = ? WHERE id = ? AND ended_at IS NULL", time.Now().Unix(), sessionID)
+	return err
+}
+
+// SessionTerminated сообщает, завершён ли сеанс администратором. Сеанс,
+// которого нет в базе (например, после удаления пользователя), также
+// считается завершённым.
+func SessionTerminated(db Querier, sessionID int) (bool, error) {
+	var terminated bool
+	err := db.QueryRow("SELECT terminated FROM sessions WHERE id = ?", sessionID).Scan(&terminated)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	return terminated, err
+}
+
+// GetActiveSessions возвращает незавершённые сеансы, начиная с последних.
+func GetActiveSessions(db Querier) ([]Session, error) {
+	rows, err := db.Query(`
+		SELECT s.id, s.user_id, u.name, s.login_at, s.last_active, s.host, s.pid
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.ended_at IS NULL
+		ORDER BY s.login_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		var loginAt, lastActive int64
+		if err := rows.Scan(&session.ID, &session.UserID, &session.UserName, &loginAt, &lastActive, &session.Host, &session.PID); err != nil {
+			return nil, err
+		}
+		session.LoginAt = time.Unix(loginAt, 0)
+		session.LastActive = time.Unix(lastActive, 0)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
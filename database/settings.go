@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/rules"
+	"strconv"
+	"time"
+)
+
+// Ключи таблицы settings.
+const (
+	SettingAlphabet   = "alphabet"
+	SettingWhitespace = "whitespace_policy"
+	SettingCase       = "case_policy"
+	SettingIdle       = "session_idle_timeout"
+)
+
+// GetSetting возвращает значение настройки или def, если она не задана.
+func GetSetting(db Querier, key string, def string) (string, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return def, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+func SetSetting(db Querier, key string, value string) error {
+	if key == SettingApproval {
+		if enabled, _ := strconv.ParseBool(value); !enabled {
+			if err := checkApproved(db); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := db.Exec(
+		"INSERT INTO settings (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value",
+		key, value,
+	)
+	return err
+}
+
+func GetAlphabet(db Querier) (rules.Alphabet, error) {
+	value, err := GetSetting(db, SettingAlphabet, rules.DefaultAlphabet)
+	if err != nil {
+		return rules.Alphabet{}, err
+	}
+	return rules.ParseAlphabet(value)
+}
+
+func SetAlphabet(db Querier, alphabet rules.Alphabet) error {
+	return SetSetting(db, SettingAlphabet, alphabet.String())
+}
+
+func GetWhitespacePolicy(db Querier) (rules.WhitespacePolicy, error) {
+	value, err := GetSetting(db, SettingWhitespace, string(rules.WhitespaceStandard))
+	if err != nil {
+		return "", err
+	}
+	return rules.ParseWhitespacePolicy(value)
+}
+
+func SetWhitespacePolicy(db Querier, policy rules.WhitespacePolicy) error {
+	return SetSetting(db, SettingWhitespace, string(policy))
+}
+
+func GetCasePolicy(db Querier) (rules.CasePolicy, error) {
+	value, err := GetSetting(db, SettingCase, string(rules.CaseExact))
+	if err != nil {
+		return "", err
+	}
+	return rules.ParseCasePolicy(value)
+}
+
+func SetCasePolicy(db Querier, policy rules.CasePolicy) error {
+	return SetSetting(db, SettingCase, string(policy))
+}
+
+// DefaultIdleTimeout - время бездействия, после которого приложение
+// пользователя завершает сеанс.
+const DefaultIdleTimeout = 15 * time.Minute
+
+// GetIdleTimeout возвращает время бездействия до завершения сеанса;
+// 0 - сеанс не завершается по бездействию.
+func GetIdleTimeout(db Querier) (time.Duration, error) {
+	value, err := GetSetting(db, SettingIdle, strconv.Itoa(int(DefaultIdleTimeout.Minutes())))
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes < 0 {
+		return 0, fmt.Errorf("некорректное время бездействия '%s'", value)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+func SetIdleTimeout(db Querier, timeout time.Duration) error {
+	return SetSetting(db, SettingIdle, strconv.Itoa(int(timeout.Minutes())))
+}
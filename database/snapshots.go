@@ -0,0 +1,315 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Snapshot - снимок матрицы доступа: пользователи, буквы, права, правила
+// и рёбра Take-Grant без настроек. Вместе с конфигурацией хранятся
+// идентификаторы строк, чтобы отличать переименование от удаления
+// и создания.
+type Snapshot struct {
+	ID        int
+	Name      string
+	CreatedAt time.Time
+	Config    *Config
+	Users     map[int]string
+	Letters   map[int]string
+}
+
+type snapshotData struct {
+	Config  *Config        `json:"config"`
+	Users   map[int]string `json:"users"`
+	Letters map[int]string `json:"letters"`
+}
+
+// CurrentSnapshot возвращает снимок текущего состояния без сохранения.
+func CurrentSnapshot(db Querier) (*Snapshot, error) {
+	cfg, err := Export(db)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Settings = nil
+
+	snapshot := &Snapshot{CreatedAt: time.Now(), Config: cfg}
+	if snapshot.Users, err = idNames(db, "SELECT id, name FROM users"); err != nil {
+		return nil, err
+	}
+	if snapshot.Letters, err = idNames(db, "SELECT id, char FROM letters"); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func idNames(db Querier, query string) (map[int]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
+
+func CreateSnapshot(db Querier, name string) (int, error) {
+	var exists int
+	if err := db.QueryRow("SELECT COUNT(*) FROM snapshots WHERE name = ?", name).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists > 0 {
+		return 0, fmt.Errorf("снимок '%s' уже существует", name)
+	}
+
+	snapshot, err := CurrentSnapshot(db)
+	if err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(snapshotData{Config: snapshot.Config, Users: snapshot.Users, Letters: snapshot.Letters})
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec("INSERT INTO snapshots (name, created_at, data) VALUES (?, ?, ?)",
+		name, snapshot.CreatedAt.Unix(), string(data))
+	if err != nil {
+		return 0, fmt.Errorf("снимок '%s' не создан: %v", name, err)
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func DeleteSnapshot(db Querier, snapshotID int) error {
+	_, err := db.Exec("DELETE FROM snapshots WHERE id = ?", snapshotID)
+	return err
+}
+
+// GetSnapshots возвращает снимки без содержимого, начиная с последних.
+func GetSnapshots(db Querier) ([]Snapshot, error) {
+	rows, err := db.Query("SELECT id, name, created_at FROM snapshots ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var snapshot Snapshot
+		var createdAt int64
+		if err := rows.Scan(&snapshot.ID, &snapshot.Name, &createdAt); err != nil {
+			return nil, err
+		}
+		snapshot.CreatedAt = time.Unix(createdAt, 0)
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+func GetSnapshot(db Querier, snapshotID int) (*Snapshot, error) {
+	snapshot := &Snapshot{ID: snapshotID}
+	var createdAt int64
+	var data string
+	err := db.QueryRow("SELECT name, created_at, data FROM snapshots WHERE id = ?", snapshotID).
+		Scan(&snapshot.Name, &createdAt, &data)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.CreatedAt = time.Unix(createdAt, 0)
+
+	var stored snapshotData
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, fmt.Errorf("снимок '%s' повреждён: %v", snapshot.Name, err)
+	}
+	snapshot.Config, snapshot.Users, snapshot.Letters = stored.Config, stored.Users, stored.Letters
+	return snapshot, nil
+}
+
+// SnapshotDiff возвращает изменения от снимка from к снимку to.
+// Пользователи и буквы с тем же идентификатором, но другим именем,
+// показываются как переименование.
+func SnapshotDiff(from, to *Snapshot) []Change {
+	var changes []Change
+	users := renames(from.Users, to.Users)
+	letters := renames(from.Letters, to.Letters)
+
+	for _, old := range sortedKeys(users) {
+		changes = append(changes, Change{Kind: ChangeUpdate, Object: fmt.Sprintf("пользователь %s → %s", old, users[old])})
+	}
+	for _, old := range sortedKeys(letters) {
+		changes = append(changes, Change{Kind: ChangeUpdate, Object: fmt.Sprintf("буква %s → %s", old, letters[old])})
+	}
+	return append(changes, Diff(from.Config.renamed(users, letters), to.Config)...)
+}
+
+// renames сопоставляет прежние имена новым для идентификаторов, которые
+// есть в обоих снимках под разными именами.
+func renames(from, to map[int]string) map[string]string {
+	result := make(map[string]string)
+	for id, old := range from {
+		if name, ok := to[id]; ok && name != old {
+			result[old] = name
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedIDs(m map[int]string) []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// renamed возвращает копию конфигурации с переименованными пользователями
+// и буквами.
+func (cfg *Config) renamed(users, letters map[string]string) *Config {
+	rename := func(names map[string]string, name string) string {
+		if renamed, ok := names[name]; ok {
+			return renamed
+		}
+		return name
+	}
+
+	result := &Config{Version: cfg.Version, Settings: cfg.Settings}
+	for _, letter := range cfg.Letters {
+		letter.Char = rename(letters, letter.Char)
+		result.Letters = append(result.Letters, letter)
+	}
+	for _, user := range cfg.Users {
+		copied := UserConfig{Name: rename(users, user.Name), Rules: user.Rules}
+		for _, letter := range user.Letters {
+			copied.Letters = append(copied.Letters, rename(letters, letter))
+		}
+		if len(user.Expires) > 0 {
+			copied.Expires = make(map[string]time.Time, len(user.Expires))
+			for letter, until := range user.Expires {
+				copied.Expires[rename(letters, letter)] = until
+			}
+		}
+		result.Users = append(result.Users, copied)
+	}
+	for _, edge := range cfg.Edges {
+		edge.From = rename(users, edge.From)
+		if edge.User != "" {
+			edge.User = rename(users, edge.User)
+		} else {
+			edge.Letter = rename(letters, edge.Letter)
+		}
+		result.Edges = append(result.Edges, edge)
+	}
+	return result
+}
+
+// RollbackToSnapshot возвращает матрицу доступа к состоянию снимка в одной
+// транзакции. Переименованные пользователи и буквы получают прежние имена,
+// удалённые создаются заново (пароли при этом не восстанавливаются).
+// Настройки не меняются.
+func RollbackToSnapshot(db *sql.DB, snapshotID int, dryRun bool) ([]Change, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	changes, err := RollbackToSnapshotTx(tx, snapshotID)
+	if err != nil || dryRun {
+		return changes, err
+	}
+	return changes, tx.Commit()
+}
+
+// RollbackToSnapshotTx выполняет откат к снимку в транзакции tx.
+func RollbackToSnapshotTx(tx *sql.Tx, snapshotID int) ([]Change, error) {
+	snapshot, err := GetSnapshot(tx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	current, err := CurrentSnapshot(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range sortedIDs(snapshot.Users) {
+		name := snapshot.Users[id]
+		now, ok := current.Users[id]
+		if !ok || now == name {
+			continue
+		}
+		// Имя могло быть занято другим пользователем; тогда права
+		// восстанавливаются импортом без переименования
+		if _, err := GetUserID(tx, name); err == nil {
+			continue
+		}
+		if err := UpdateUserName(tx, id, name); err != nil {
+			return nil, err
+		}
+		changes = append(changes, Change{Kind: ChangeUpdate, Object: fmt.Sprintf("пользователь %s → %s", now, name)})
+	}
+	for _, id := range sortedIDs(snapshot.Letters) {
+		char := snapshot.Letters[id]
+		now, ok := current.Letters[id]
+		if !ok || now == char {
+			continue
+		}
+		if _, err := GetLetterID(tx, char); err == nil {
+			continue
+		}
+		if err := UpdateLetter(tx, id, char); err != nil {
+			return nil, err
+		}
+		changes = append(changes, Change{Kind: ChangeUpdate, Object: fmt.Sprintf("буква %s → %s", now, char)})
+	}
+
+	cfg := *snapshot.Config
+	cfg.Settings, err = settingsMap(tx)
+	if err != nil {
+		return nil, err
+	}
+	more, err := ImportTx(tx, &cfg, ImportReplace)
+	if err != nil {
+		return nil, err
+	}
+	return append(changes, more...), nil
+}
+
+func settingsMap(db Querier) (map[string]string, error) {
+	rows, err := db.Query("SELECT key, value FROM settings")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		settings[key] = value
+	}
+	return settings, rows.Err()
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Init(filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// grantTemporary создаёт пользователя с бессрочным правом на "а"
+// и временным на "б".
+func grantTemporary(t *testing.T, db *sql.DB, until time.Time) (userID, letterID int) {
+	t.Helper()
+	if err := Create(db, "ivan", "а"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	userID, err := FindUser(db, "ivan")
+	if err != nil {
+		t.Fatalf("FindUser: %v", err)
+	}
+	letterID, err = EnsureLetterExists(db, "б")
+	if err != nil {
+		t.Fatalf("EnsureLetterExists: %v", err)
+	}
+	if err := GrantUntil(db, userID, letterID, until); err != nil {
+		t.Fatalf("GrantUntil: %v", err)
+	}
+	return userID, letterID
+}
+
+func diffWithCurrent(t *testing.T, db *sql.DB, snapshotID int) []Change {
+	t.Helper()
+	from, err := GetSnapshot(db, snapshotID)
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	to, err := CurrentSnapshot(db)
+	if err != nil {
+		t.Fatalf("CurrentSnapshot: %v", err)
+	}
+	return SnapshotDiff(from, to)
+}
+
+func TestSnapshotDiffUnchanged(t *testing.T) {
+	db := openTestDB(t)
+	grantTemporary(t, db, time.Now().Add(time.Hour))
+
+	snapshotID, err := CreateSnapshot(db, "до")
+	if err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	if changes := diffWithCurrent(t, db, snapshotID); len(changes) != 0 {
+		t.Errorf("SnapshotDiff = %v, want no changes", changes)
+	}
+}
+
+func TestSnapshotDiffRenamedKeepsExpiry(t *testing.T) {
+	db := openTestDB(t)
+	userID, letterID := grantTemporary(t, db, time.Now().Add(time.Hour))
+
+	snapshotID, err := CreateSnapshot(db, "до")
+	if err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	if err := UpdateUserName(db, userID, "petr"); err != nil {
+		t.Fatalf("UpdateUserName: %v", err)
+	}
+	if _, err := db.Exec("UPDATE letters SET char = 'в' WHERE id = ?", letterID); err != nil {
+		t.Fatalf("rename letter: %v", err)
+	}
+
+	want := []Change{
+		{Kind: ChangeUpdate, Object: "пользователь ivan → petr"},
+		{Kind: ChangeUpdate, Object: "буква б → в"},
+	}
+	changes := diffWithCurrent(t, db, snapshotID)
+	if len(changes) != len(want) {
+		t.Fatalf("SnapshotDiff = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %v, want %v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestSnapshotDiffExpiryChanged(t *testing.T) {
+	db := openTestDB(t)
+	userID, letterID := grantTemporary(t, db, time.Now().Add(time.Hour))
+
+	snapshotID, err := CreateSnapshot(db, "до")
+	if err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	if err := Grant(db, userID, letterID); err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+
+	changes := diffWithCurrent(t, db, snapshotID)
+	if len(changes) != 1 || changes[0].Kind != ChangeUpdate {
+		t.Errorf("SnapshotDiff = %v, want one expiry change", changes)
+	}
+}
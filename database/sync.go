@@ -0,0 +1,201 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SyncState - конфигурация базы сразу после последней декларативной
+// синхронизации. С ней сравнивается текущее состояние, чтобы найти
+// изменения, сделанные в обход файла политики.
+type SyncState struct {
+	AppliedAt time.Time
+	Source    string
+	Config    *Config
+}
+
+// GetSyncState возвращает состояние последней синхронизации или
+// sql.ErrNoRows, если синхронизаций не было.
+func GetSyncState(db Querier) (*SyncState, error) {
+	var appliedAt int64
+	var source, data string
+	err := db.QueryRow("SELECT applied_at, source, config FROM sync_state WHERE id = 1").Scan(&appliedAt, &source, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		return nil, fmt.Errorf("состояние синхронизации повреждено: %v", err)
+	}
+	return &SyncState{AppliedAt: time.Unix(appliedAt, 0), Source: source, Config: &cfg}, nil
+}
+
+// SaveSyncState запоминает текущую конфигурацию базы как результат
+// синхронизации из source.
+func SaveSyncState(db Querier, source string) error {
+	cfg, err := Export(db)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`INSERT INTO sync_state (id, applied_at, source, config) VALUES (1, ?, ?, ?)
+		 ON CONFLICT(id) DO UPDATE SET applied_at = excluded.applied_at, source = excluded.source, config = excluded.config`,
+		time.Now().Unix(), source, string(data),
+	)
+	return err
+}
+
+// Diff сравнивает две выгрузки и возвращает изменения, переводящие from
+// в to, в том же виде, что и Import в режиме replace.
+func Diff(from, to *Config) []Change {
+	var changes []Change
+	change := func(kind ChangeKind, format string, args ...any) {
+		changes = append(changes, Change{Kind: kind, Object: fmt.Sprintf(format, args...)})
+	}
+
+	for _, key := range unionKeys(from.Settings, to.Settings) {
+		old, had := from.Settings[key]
+		value, has := to.Settings[key]
+		switch {
+		case !had:
+			change(ChangeAdd, "настройка %s = %s", key, value)
+		case !has:
+			change(ChangeRemove, "настройка %s (значение по умолчанию)", key)
+		case old != value:
+			change(ChangeUpdate, "настройка %s: %s → %s", key, old, value)
+		}
+	}
+
+	oldUsers := make(map[string]UserConfig, len(from.Users))
+	for _, user := range from.Users {
+		oldUsers[user.Name] = user
+	}
+	newUsers := make(map[string]bool, len(to.Users))
+	for _, user := range to.Users {
+		newUsers[user.Name] = true
+	}
+	for _, user := range from.Users {
+		if !newUsers[user.Name] {
+			change(ChangeRemove, "пользователь %s", user.Name)
+		}
+	}
+
+	oldLetters := make(map[string]LetterConfig, len(from.Letters))
+	for _, letter := range from.Letters {
+		oldLetters[letter.Char] = letter
+	}
+	newLetters := make(map[string]bool, len(to.Letters))
+	for _, letter := range to.Letters {
+		newLetters[letter.Char] = true
+		old, ok := oldLetters[letter.Char]
+		switch {
+		case !ok:
+			change(ChangeAdd, "буква %s (регистр: %s)", letter.Char, casePolicyName(letter.Case))
+		case old.Case != letter.Case:
+			change(ChangeUpdate, "регистр буквы %s: %s → %s", letter.Char, casePolicyName(old.Case), casePolicyName(letter.Case))
+		}
+	}
+
+	for _, user := range to.Users {
+		old, ok := oldUsers[user.Name]
+		if !ok {
+			change(ChangeAdd, "пользователь %s", user.Name)
+		}
+		added, removed := diffLists(old.Letters, user.Letters)
+		for _, letter := range added {
+			until, temporary := user.Expires[letter]
+			change(ChangeAdd, "право %s: %s%s", user.Name, letter, expirySuffix(until, temporary))
+		}
+		for _, letter := range removed {
+			change(ChangeRemove, "право %s: %s", user.Name, letter)
+		}
+		isAdded := make(map[string]bool, len(added))
+		for _, letter := range added {
+			isAdded[letter] = true
+		}
+		for _, letter := range user.Letters {
+			oldUntil, wasTemporary := old.Expires[letter]
+			until, temporary := user.Expires[letter]
+			if !isAdded[letter] && (temporary != wasTemporary || !until.Equal(oldUntil)) {
+				change(ChangeUpdate, "срок права %s: %s: %s → %s", user.Name, letter,
+					expiryName(oldUntil, wasTemporary), expiryName(until, temporary))
+			}
+		}
+		added, removed = diffLists(old.Rules, user.Rules)
+		for _, expr := range added {
+			change(ChangeAdd, "правило %s: %s", user.Name, expr)
+		}
+		for _, expr := range removed {
+			change(ChangeRemove, "правило %s: %s", user.Name, expr)
+		}
+	}
+
+	oldEdges := make(map[EdgeConfig]bool, len(from.Edges))
+	for _, edge := range from.Edges {
+		oldEdges[edge] = true
+	}
+	newEdges := make(map[EdgeConfig]bool, len(to.Edges))
+	for _, edge := range to.Edges {
+		newEdges[edge] = true
+		if !oldEdges[edge] {
+			change(ChangeAdd, "ребро %s", edge)
+		}
+	}
+	for _, edge := range from.Edges {
+		// Рёбра удалённых пользователей и букв удаляются вместе с ними
+		if !newEdges[edge] && newUsers[edge.From] &&
+			(edge.User == "" || newUsers[edge.User]) && (edge.Letter == "" || newLetters[edge.Letter]) {
+			change(ChangeRemove, "ребро %s", edge)
+		}
+	}
+
+	for _, letter := range from.Letters {
+		if !newLetters[letter.Char] {
+			change(ChangeRemove, "буква %s", letter.Char)
+		}
+	}
+	return changes
+}
+
+func unionKeys(a, b map[string]string) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffLists возвращает элементы, которые есть только в next (added),
+// и только в prev (removed), в порядке списков.
+func diffLists(prev, next []string) (added, removed []string) {
+	inPrev := make(map[string]bool, len(prev))
+	for _, item := range prev {
+		inPrev[item] = true
+	}
+	inNext := make(map[string]bool, len(next))
+	for _, item := range next {
+		inNext[item] = true
+		if !inPrev[item] {
+			added = append(added, item)
+		}
+	}
+	for _, item := range prev {
+		if !inNext[item] {
+			removed = append(removed, item)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"fmt"
+)
+
+// Метки рёбер модели Take-Grant, хранящихся в tg_edges. Право чтения
+// по-прежнему хранится в user_letters.
+const (
+	EdgeTake  = "t"
+	EdgeGrant = "g"
+)
+
+// Виды вершин, в которые может вести ребро take/grant.
+const (
+	TargetUser   = "user"
+	TargetLetter = "letter"
+)
+
+type TakeGrantEdge struct {
+	From     string
+	To       string
+	ToLetter bool
+	Label    string
+}
+
+func checkEdge(targetKind, label string) error {
+	if targetKind != TargetUser && targetKind != TargetLetter {
+		return fmt.Errorf("неизвестный вид вершины '%s'", targetKind)
+	}
+	if label != EdgeTake && label != EdgeGrant {
+		return fmt.Errorf("неизвестная метка ребра '%s' (допустимы t и g)", label)
+	}
+	return nil
+}
+
+func AddTakeGrantEdge(db Querier, fromUserID int, targetKind string, targetID int, label string) error {
+	if err := checkEdge(targetKind, label); err != nil {
+		return err
+	}
+	_, err := db.Exec(
+		"INSERT OR IGNORE INTO tg_edges (src_user, dst_kind, dst_id, label) VALUES (?, ?, ?, ?)",
+		fromUserID, targetKind, targetID, label,
+	)
+	return err
+}
+
+func RemoveTakeGrantEdge(db Querier, fromUserID int, targetKind string, targetID int, label string) error {
+	if err := checkEdge(targetKind, label); err != nil {
+		return err
+	}
+	_, err := db.Exec(
+		"DELETE FROM tg_edges WHERE src_user = ? AND dst_kind = ? AND dst_id = ? AND label = ?",
+		fromUserID, targetKind, targetID, label,
+	)
+	return err
+}
+
+func GetTakeGrantEdges(db Querier) ([]TakeGrantEdge, error) {
+	rows, err := db.Query(`
+        SELECT u.name, COALESCE(du.name, l.char), e.dst_kind, e.label
+        FROM tg_edges e
+        JOIN users u ON u.id = e.src_user
+        LEFT JOIN users du ON e.dst_kind = 'user' AND du.id = e.dst_id
+        LEFT JOIN letters l ON e.dst_kind = 'letter' AND l.id = e.dst_id
+        WHERE du.id IS NOT NULL OR l.id IS NOT NULL
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edges []TakeGrantEdge
+	for rows.Next() {
+		var edge TakeGrantEdge
+		var kind string
+		if err := rows.Scan(&edge.From, &edge.To, &kind, &edge.Label); err != nil {
+			return nil, err
+		}
+		edge.ToLetter = kind == TargetLetter
+		edges = append(edges, edge)
+	}
+	return edges, nil
+}
@@ -0,0 +1,124 @@
+// Package filter применяет права доступа пользователя к тексту. Текст
+// проверяется по кластерам графем в NFC; запрещённые символы удаляются,
+// маскируются, выделяются или приводят к отклонению всего текста.
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"laba3/grapheme"
+	"strings"
+)
+
+// Mode - способ обработки запрещённых символов.
+type Mode string
+
+const (
+	// ModeDelete - запрещённые символы удаляются.
+	ModeDelete Mode = "delete"
+	// ModeMask - каждый запрещённый символ заменяется заполнителем.
+	ModeMask Mode = "mask"
+	// ModeHighlight - текст не меняется, запрещённые символы выделяются
+	// при отображении (см. Filter.Segments).
+	ModeHighlight Mode = "highlight"
+	// ModeReject - текст с хотя бы одним запрещённым символом отклоняется.
+	ModeReject Mode = "reject"
+)
+
+var Modes = []Mode{ModeDelete, ModeMask, ModeHighlight, ModeReject}
+
+// DefaultPlaceholder - заполнитель для режима маскирования по умолчанию.
+const DefaultPlaceholder = "*"
+
+func ParseMode(value string) (Mode, error) {
+	for _, mode := range Modes {
+		if string(mode) == value {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("неизвестный режим фильтрации '%s' (допустимы delete, mask, highlight, reject)", value)
+}
+
+// Allowed сообщает, разрешён ли пользователю кластер графем.
+type Allowed func(cluster string) bool
+
+// Segment - непрерывный участок текста, все символы которого либо
+// разрешены, либо запрещены.
+type Segment struct {
+	Text      string
+	Forbidden bool
+}
+
+// RejectedError возвращается в режиме ModeReject, если текст содержит
+// запрещённые символы.
+type RejectedError struct {
+	Forbidden []string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("текст отклонён: запрещённые символы %s", strings.Join(e.Forbidden, " "))
+}
+
+// Stats - статистика обработки текста в кластерах графем.
+type Stats struct {
+	Kept    int
+	Dropped int
+	// Forbidden - сколько раз встретился каждый запрещённый символ.
+	Forbidden map[string]int
+}
+
+// process обрабатывает текст в заданном режиме: текст читается из r
+// потоком, результат пишется в w. В режиме ModeHighlight текст не
+// меняется, для отображения используется Filter.Segments. В режиме
+// ModeReject результат накапливается в памяти и записывается, только если
+// текст не отклонён.
+func process(r io.Reader, allowed Allowed, mode Mode, placeholder string, w io.Writer) (Stats, error) {
+	stats := Stats{Forbidden: make(map[string]int)}
+	var rejected []string
+
+	out := bufio.NewWriter(w)
+	var held bytes.Buffer
+	var dst io.Writer = out
+	if mode == ModeReject {
+		dst = &held
+	}
+
+	err := grapheme.EachReader(r, func(cluster string) error {
+		if allowed(cluster) {
+			stats.Kept++
+			_, err := io.WriteString(dst, cluster)
+			return err
+		}
+
+		stats.Dropped++
+		if stats.Forbidden[cluster] == 0 {
+			rejected = append(rejected, cluster)
+		}
+		stats.Forbidden[cluster]++
+
+		switch mode {
+		case ModeMask:
+			_, err := io.WriteString(dst, placeholder)
+			return err
+		case ModeHighlight:
+			_, err := io.WriteString(dst, cluster)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	if mode == ModeReject {
+		if len(rejected) > 0 {
+			return stats, &RejectedError{Forbidden: rejected}
+		}
+		if _, err := held.WriteTo(out); err != nil {
+			return stats, err
+		}
+	}
+	return stats, out.Flush()
+}
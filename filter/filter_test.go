@@ -0,0 +1,281 @@
+package filter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"laba3/grapheme"
+	"laba3/rules"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	shortI       = "\u0439"       // й
+	shortIDecomp = "\u0438\u0306" // и + комбинируемое бреве
+	flagRU       = "\U0001F1F7\U0001F1FA"
+	flagUS       = "\U0001F1FA\U0001F1F8"
+	family       = "\U0001F468\u200D\U0001F469\u200D\U0001F467"
+)
+
+// newFilter возвращает фильтр, разрешающий только letters; пробельные
+// символы проверяются наравне с остальными.
+func newFilter(letters ...string) *Filter {
+	var set rules.LetterSet
+	for _, letter := range letters {
+		set.Add(letter, rules.CaseExact)
+	}
+	return New(set, nil, rules.WhitespaceNone)
+}
+
+func TestApplyDecomposed(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowed   []string
+		input     string
+		mode      Mode
+		want      string
+		kept      int
+		dropped   int
+		forbidden map[string]int
+	}{
+		{
+			name:    "й разрешена в обеих формах",
+			allowed: []string{shortI},
+			input:   shortI + shortIDecomp,
+			mode:    ModeDelete,
+			want:    shortI + shortI,
+			kept:    2,
+		},
+		{
+			name:      "право на и не даёт права на и+бреве",
+			allowed:   []string{"и"},
+			input:     "и" + shortIDecomp,
+			mode:      ModeDelete,
+			want:      "и",
+			kept:      1,
+			dropped:   1,
+			forbidden: map[string]int{shortI: 1},
+		},
+		{
+			name:      "и+бреве маскируется одним заполнителем",
+			allowed:   []string{"а"},
+			input:     "а" + shortIDecomp + "а",
+			mode:      ModeMask,
+			want:      "а*а",
+			kept:      2,
+			dropped:   1,
+			forbidden: map[string]int{shortI: 1},
+		},
+		{
+			name:      "флаг - один символ",
+			allowed:   []string{flagRU},
+			input:     flagRU + flagUS + flagRU,
+			mode:      ModeMask,
+			want:      flagRU + "*" + flagRU,
+			kept:      2,
+			dropped:   1,
+			forbidden: map[string]int{flagUS: 1},
+		},
+		{
+			name:      "ZWJ-последовательность удаляется целиком",
+			allowed:   []string{"x"},
+			input:     "x" + family + "x" + family,
+			mode:      ModeDelete,
+			want:      "xx",
+			kept:      2,
+			dropped:   2,
+			forbidden: map[string]int{family: 2},
+		},
+		{
+			name:      "ZWJ-последовательность при выделении не меняется",
+			allowed:   []string{"x"},
+			input:     family + "x",
+			mode:      ModeHighlight,
+			want:      family + "x",
+			kept:      1,
+			dropped:   1,
+			forbidden: map[string]int{family: 1},
+		},
+		{
+			name:    "разрешённая ZWJ-последовательность",
+			allowed: []string{family},
+			input:   family,
+			mode:    ModeReject,
+			want:    family,
+			kept:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stats, err := newFilter(tt.allowed...).Apply(tt.input, tt.mode, DefaultPlaceholder)
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("результат %q, want %q", got, tt.want)
+			}
+			if stats.Kept != tt.kept || stats.Dropped != tt.dropped {
+				t.Errorf("Kept/Dropped = %d/%d, want %d/%d", stats.Kept, stats.Dropped, tt.kept, tt.dropped)
+			}
+			if tt.forbidden == nil {
+				tt.forbidden = map[string]int{}
+			}
+			if !reflect.DeepEqual(stats.Forbidden, tt.forbidden) {
+				t.Errorf("Forbidden = %q, want %q", stats.Forbidden, tt.forbidden)
+			}
+		})
+	}
+}
+
+func TestApplyRejectDecomposed(t *testing.T) {
+	_, stats, err := newFilter("x").Apply("x"+shortIDecomp+flagRU, ModeReject, "")
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("ожидалась RejectedError, получено %v", err)
+	}
+	if want := []string{shortI, flagRU}; !reflect.DeepEqual(rejected.Forbidden, want) {
+		t.Errorf("Forbidden = %q, want %q", rejected.Forbidden, want)
+	}
+	if stats.Kept != 1 || stats.Dropped != 2 {
+		t.Errorf("Kept/Dropped = %d/%d, want 1/2", stats.Kept, stats.Dropped)
+	}
+}
+
+func TestSegmentsDecomposed(t *testing.T) {
+	got := newFilter("а").Segments("аа" + shortIDecomp + family + "а")
+	want := []Segment{
+		{Text: "аа"},
+		{Text: shortI + family, Forbidden: true},
+		{Text: "а"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Segments = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyModes(t *testing.T) {
+	var letters rules.LetterSet
+	letters.Add("а", rules.CaseInsensitive)
+	letters.Add("б", rules.CaseExact)
+	digits, err := rules.Parse("category:digit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := New(letters, []rules.Rule{digits}, rules.WhitespaceStandard)
+
+	const input = "Аб в1 Б"
+	tests := []struct {
+		mode        Mode
+		placeholder string
+		want        string
+	}{
+		{ModeDelete, "", "Аб 1 "},
+		{ModeMask, "#", "Аб #1 #"},
+		{ModeMask, "", "Аб 1 "},
+		{ModeHighlight, "", input},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			got, stats, err := f.Apply(input, tt.mode, tt.placeholder)
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("результат %q, want %q", got, tt.want)
+			}
+			if stats.Kept != 5 || stats.Dropped != 2 {
+				t.Errorf("Kept/Dropped = %d/%d, want 5/2", stats.Kept, stats.Dropped)
+			}
+			if want := map[string]int{"в": 1, "Б": 1}; !reflect.DeepEqual(stats.Forbidden, want) {
+				t.Errorf("Forbidden = %v, want %v", stats.Forbidden, want)
+			}
+		})
+	}
+
+	if got, _, err := f.Apply("аА 12", ModeReject, ""); err != nil || got != "аА 12" {
+		t.Errorf("Apply(reject) = %q, %v; want текст без изменений", got, err)
+	}
+	_, _, err = f.Apply(input, ModeReject, "")
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || !reflect.DeepEqual(rejected.Forbidden, []string{"в", "Б"}) {
+		t.Errorf("Apply(reject) error = %v, want RejectedError [в Б]", err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	f := newFilter("а", "\n")
+	input := strings.Repeat("аб\n", 100000)
+
+	var out bytes.Buffer
+	stats, err := f.Copy(&out, strings.NewReader(input), ModeDelete, "")
+	if err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if want := strings.Repeat("а\n", 100000); out.String() != want {
+		t.Errorf("результат длиной %d, want %d", out.Len(), len(want))
+	}
+	if stats.Kept != 200000 || stats.Dropped != 100000 {
+		t.Errorf("Kept/Dropped = %d/%d, want 200000/100000", stats.Kept, stats.Dropped)
+	}
+
+	// В режиме отклонения ничего не записывается
+	out.Reset()
+	_, err = f.Copy(&out, strings.NewReader(input), ModeReject, "")
+	if err == nil || out.Len() != 0 {
+		t.Errorf("Copy(reject) записал %d байт, error = %v", out.Len(), err)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range Modes {
+		if got, err := ParseMode(string(mode)); err != nil || got != mode {
+			t.Errorf("ParseMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+	if _, err := ParseMode("drop"); err == nil {
+		t.Errorf("ParseMode(drop): ожидалась ошибка")
+	}
+}
+
+// benchText - текст для тестов производительности: кириллица, латиница,
+// пробелы и составные символы, около 1 МБ.
+var benchText = strings.Repeat("Съешь же ещё этих мягких французских булок, "+
+	"да выпей чаю. The quick brown fox "+shortIDecomp+flagRU+family+"\n", 8000)
+
+func benchFilter() *Filter {
+	var letters rules.LetterSet
+	for _, letter := range grapheme.Split("абвгдеёжзийклмнопрстуфхцчшщъыьэюя") {
+		letters.Add(letter, rules.CaseInsensitive)
+	}
+	return New(letters, nil, rules.WhitespaceStandard)
+}
+
+func BenchmarkApply(b *testing.B) {
+	f := benchFilter()
+	b.SetBytes(int64(len(benchText)))
+	for b.Loop() {
+		if _, _, err := f.Apply(benchText, ModeMask, DefaultPlaceholder); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCopy(b *testing.B) {
+	f := benchFilter()
+	b.SetBytes(int64(len(benchText)))
+	for b.Loop() {
+		if _, err := f.Copy(io.Discard, strings.NewReader(benchText), ModeDelete, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSegments(b *testing.B) {
+	f := benchFilter()
+	b.SetBytes(int64(len(benchText)))
+	for b.Loop() {
+		f.Segments(benchText)
+	}
+}
@@ -0,0 +1,91 @@
+package filter
+
+import (
+	"database/sql"
+	"io"
+	"laba3/database"
+	"laba3/grapheme"
+	"laba3/rules"
+	"strings"
+)
+
+// Filter - права одного пользователя на символы: явно выданные буквы с
+// политикой регистра, атрибутные правила и политика пробельных символов.
+// Filter не изменяется после создания и может использоваться из
+// нескольких горутин.
+type Filter struct {
+	letters    rules.LetterSet
+	rules      []rules.Rule
+	whitespace rules.WhitespacePolicy
+}
+
+func New(letters rules.LetterSet, accessRules []rules.Rule, whitespace rules.WhitespacePolicy) *Filter {
+	return &Filter{
+		letters:    letters,
+		rules:      accessRules,
+		whitespace: whitespace,
+	}
+}
+
+// Load строит фильтр по правам пользователя из базы данных.
+func Load(db *sql.DB, userID int) (*Filter, error) {
+	letters, err := database.GetPermissionSet(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessRules, err := database.GetRules(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]rules.Rule, 0, len(accessRules))
+	for _, accessRule := range accessRules {
+		list = append(list, accessRule.Rule)
+	}
+
+	whitespace, err := database.GetWhitespacePolicy(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(letters, list, whitespace), nil
+}
+
+// Allowed сообщает, разрешён ли пользователю кластер графем.
+func (f *Filter) Allowed(cluster string) bool {
+	return f.whitespace.Passes(cluster) ||
+		f.letters.Contains(cluster) ||
+		rules.MatchAny(f.rules, cluster)
+}
+
+// Apply обрабатывает строку и возвращает результат со статистикой.
+func (f *Filter) Apply(input string, mode Mode, placeholder string) (string, Stats, error) {
+	var result strings.Builder
+	stats, err := f.Copy(&result, strings.NewReader(input), mode, placeholder)
+	if err != nil {
+		return "", stats, err
+	}
+	return result.String(), stats, nil
+}
+
+// Copy читает текст из r потоком и пишет результат обработки в w. Память
+// не зависит от размера текста, кроме режима ModeReject, в котором
+// результат накапливается до конца чтения.
+func (f *Filter) Copy(w io.Writer, r io.Reader, mode Mode, placeholder string) (Stats, error) {
+	return process(r, f.Allowed, mode, placeholder, w)
+}
+
+// Segments разбивает текст на чередующиеся участки разрешённых и
+// запрещённых символов.
+func (f *Filter) Segments(input string) []Segment {
+	var segments []Segment
+	grapheme.Each(input, func(cluster string) {
+		forbidden := !f.Allowed(cluster)
+		if n := len(segments); n > 0 && segments[n-1].Forbidden == forbidden {
+			segments[n-1].Text += cluster
+			return
+		}
+		segments = append(segments, Segment{Text: cluster, Forbidden: forbidden})
+	})
+	return segments
+}
@@ -0,0 +1,108 @@
+// Package grapheme разбивает текст на кластеры графем (UAX #29) и приводит
+// его к нормальной форме NFC. Объекты доступа - это кластеры графем, а не
+// отдельные руны: "й", записанная как "и" и комбинируемая бреве, или флаг
+// из двух региональных индикаторов считаются одним символом.
+package grapheme
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/go-text/typesetting/segmenter"
+	"golang.org/x/text/unicode/norm"
+)
+
+// chunkSize - число символов, которое EachReader сегментирует за один раз.
+const chunkSize = 16 * 1024
+
+// Normalize приводит текст к NFC. Все буквы в базе данных и весь
+// фильтруемый текст хранятся и сравниваются в этой форме.
+func Normalize(s string) string {
+	return norm.NFC.String(s)
+}
+
+// Split нормализует текст и возвращает его кластеры графем по порядку.
+func Split(s string) []string {
+	var clusters []string
+	Each(s, func(cluster string) {
+		clusters = append(clusters, cluster)
+	})
+	return clusters
+}
+
+// Each нормализует текст и вызывает fn для каждого кластера графем.
+func Each(s string, fn func(cluster string)) {
+	s = Normalize(s)
+	if s == "" {
+		return
+	}
+
+	var seg segmenter.Segmenter
+	seg.Init([]rune(s))
+	iter := seg.GraphemeIterator()
+	for iter.Next() {
+		fn(string(iter.Grapheme().Text))
+	}
+}
+
+// EachReader читает текст потоком, нормализует его и вызывает fn для
+// каждого кластера графем. Текст сегментируется частями; последний кластер
+// части переносится в следующую, так как он может продолжаться за её
+// границей. Ошибка fn прерывает чтение и возвращается вызывающему.
+func EachReader(r io.Reader, fn func(cluster string) error) error {
+	reader := bufio.NewReader(norm.NFC.Reader(r))
+
+	var seg segmenter.Segmenter
+	var runes []rune
+	for {
+		eof := false
+		for n := 0; n < chunkSize; n++ {
+			char, _, err := reader.ReadRune()
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			if err != nil {
+				return err
+			}
+			runes = append(runes, char)
+		}
+		if len(runes) == 0 {
+			return nil
+		}
+
+		var clusters [][]rune
+		seg.Init(runes)
+		iter := seg.GraphemeIterator()
+		for iter.Next() {
+			clusters = append(clusters, iter.Grapheme().Text)
+		}
+
+		var carry []rune
+		if !eof {
+			carry = clusters[len(clusters)-1]
+			clusters = clusters[:len(clusters)-1]
+		}
+		for _, cluster := range clusters {
+			if err := fn(string(cluster)); err != nil {
+				return err
+			}
+		}
+		if eof {
+			return nil
+		}
+		runes = append([]rune(nil), carry...)
+	}
+}
+
+// Count возвращает число кластеров графем в тексте.
+func Count(s string) int {
+	n := 0
+	Each(s, func(string) { n++ })
+	return n
+}
+
+// IsSingle сообщает, состоит ли текст ровно из одного кластера графем.
+func IsSingle(s string) bool {
+	return Count(s) == 1
+}
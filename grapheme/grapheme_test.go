@@ -0,0 +1,108 @@
+package grapheme
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Исходный текст записан escape-последовательностями, чтобы составные
+// и разложенные формы не путались при редактировании.
+const (
+	shortI       = "\u0439"               // й
+	shortIDecomp = "\u0438\u0306"         // и + комбинируемое бреве
+	eAcute       = "\u00e9"               // é
+	eAcuteDecomp = "e\u0301"              // e + комбинируемый акут
+	hangul       = "\uac01"               // 각
+	hangulDecomp = "\u1100\u1161\u11a8"   // чамо хангыля
+	flagRU       = "\U0001F1F7\U0001F1FA" // два региональных индикатора
+	flagUS       = "\U0001F1FA\U0001F1F8"
+	family       = "\U0001F468\u200d\U0001F469\u200d\U0001F467" // последовательность с ZWJ
+	thumbsUpDark = "\U0001F44D\U0001F3FF"                       // эмодзи с модификатором тона
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{shortIDecomp, shortI},
+		{shortI, shortI},
+		{eAcuteDecomp, eAcute},
+		{hangulDecomp, hangul},
+		{"a\u0323\u0301", "\u1ea1\u0301"}, // точка снизу и акут: составляется только ạ
+		{"Йод йод", "Йод йод"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.input); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"пустая строка", "", nil},
+		{"й и и+бреве", shortI + shortIDecomp, []string{shortI, shortI}},
+		{"и+бреве между буквами", "а" + shortIDecomp + "б", []string{"а", shortI, "б"}},
+		{"флаг", flagRU, []string{flagRU}},
+		{"два флага подряд", flagRU + flagUS, []string{flagRU, flagUS}},
+		{"флаг и одиночный индикатор", flagRU + "\U0001F1FA", []string{flagRU, "\U0001F1FA"}},
+		{"ZWJ-последовательность", family + "x", []string{family, "x"}},
+		{"модификатор тона", thumbsUpDark, []string{thumbsUpDark}},
+		{"CRLF", "a\r\nb", []string{"a", "\r\n", "b"}},
+		{"хангыль из чамо", hangulDecomp, []string{hangul}},
+		{"ZWJ между буквами", "a\u200db", []string{"a\u200d", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Split(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSingle(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{shortI, true},
+		{shortIDecomp, true},
+		{flagRU, true},
+		{family, true},
+		{thumbsUpDark, true},
+		{"", false},
+		{"аб", false},
+		{flagRU + flagUS, false},
+	}
+	for _, tt := range tests {
+		if got := IsSingle(tt.input); got != tt.want {
+			t.Errorf("IsSingle(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+// Кластеры, пересекающие границу части, не должны разрываться.
+func TestEachReaderChunkBoundary(t *testing.T) {
+	for _, tail := range []string{shortIDecomp, flagRU, family} {
+		for shift := 0; shift < 4; shift++ {
+			text := strings.Repeat("a", chunkSize-shift) + tail + strings.Repeat("b", 3) + tail
+			var got []string
+			err := EachReader(strings.NewReader(text), func(cluster string) error {
+				got = append(got, cluster)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("EachReader: %v", err)
+			}
+			if want := Split(text); !reflect.DeepEqual(got, want) {
+				t.Errorf("сдвиг %d, %q: EachReader дал %d кластеров, Split - %d", shift, tail, len(got), len(want))
+			}
+		}
+	}
+}
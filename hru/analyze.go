@@ -0,0 +1,237 @@
+package hru
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Query описывает проверяемую утечку: может ли право Right попасть к
+// субъекту Subject. Если Object пуст, подходит любой объект.
+type Query struct {
+	Right   string
+	Subject string
+	Object  string
+}
+
+type Limits struct {
+	MaxDepth  int
+	MaxStates int
+}
+
+// Step - один шаг контрпримера: команда и её фактические параметры.
+type Step struct {
+	Command string
+	Args    []string
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("%s(%s)", s.Command, strings.Join(s.Args, ", "))
+}
+
+type Result struct {
+	Leaked bool
+	// Subject и Object - ячейка, в которую попало право.
+	Subject string
+	Object  string
+	Trace   []Step
+	// Explored - число рассмотренных состояний.
+	Explored int
+	// Complete равен true, если пространство состояний исследовано
+	// полностью в пределах MaxDepth и лимит MaxStates не был достигнут.
+	Complete bool
+}
+
+type node struct {
+	state  *State
+	parent int
+	step   Step
+	depth  int
+}
+
+// Analyze выполняет поиск в ширину по состояниям, достижимым из initial
+// применением команд, и ищет первое состояние, в котором право из запроса
+// появилось в ячейке, где его не было изначально. Найденная
+// последовательность команд возвращается как контрпример.
+func Analyze(initial *State, commands []Command, q Query, lim Limits) Result {
+	if lim.MaxDepth <= 0 {
+		lim.MaxDepth = 4
+	}
+	if lim.MaxStates <= 0 {
+		lim.MaxStates = 100000
+	}
+
+	nodes := []node{{state: initial, parent: -1}}
+	visited := map[string]bool{initial.key(): true}
+	result := Result{Complete: true}
+
+	for i := 0; i < len(nodes); i++ {
+		current := nodes[i]
+		result.Explored++
+
+		if object, ok := leak(initial, current.state, q); ok {
+			result.Leaked = true
+			result.Subject = q.Subject
+			result.Object = object
+			result.Trace = trace(nodes, i)
+			return result
+		}
+
+		if current.depth >= lim.MaxDepth {
+			continue
+		}
+
+		for _, cmd := range commands {
+			for _, args := range bindings(current.state, cmd) {
+				next := current.state.Run(cmd, args)
+				if next == nil {
+					continue
+				}
+				key := next.key()
+				if visited[key] {
+					continue
+				}
+				if len(visited) >= lim.MaxStates {
+					result.Complete = false
+					continue
+				}
+				visited[key] = true
+				nodes = append(nodes, node{
+					state:  next,
+					parent: i,
+					step:   Step{Command: cmd.Name, Args: args},
+					depth:  current.depth + 1,
+				})
+			}
+		}
+	}
+
+	return result
+}
+
+func leak(initial, state *State, q Query) (string, bool) {
+	if !state.IsSubject(q.Subject) {
+		return "", false
+	}
+	if q.Object != "" {
+		if state.Has(q.Right, q.Subject, q.Object) && !initial.Has(q.Right, q.Subject, q.Object) {
+			return q.Object, true
+		}
+		return "", false
+	}
+	for _, object := range state.Objects() {
+		if state.Has(q.Right, q.Subject, object) && !initial.Has(q.Right, q.Subject, object) {
+			return object, true
+		}
+	}
+	return "", false
+}
+
+func trace(nodes []node, i int) []Step {
+	var steps []Step
+	for ; nodes[i].parent >= 0; i = nodes[i].parent {
+		steps = append(steps, nodes[i].step)
+	}
+	for l, r := 0, len(steps)-1; l < r; l, r = l+1, r-1 {
+		steps[l], steps[r] = steps[r], steps[l]
+	}
+	return steps
+}
+
+// bindings перечисляет наборы фактических параметров команды. Параметры,
+// которые команда создаёт, получают новое уникальное имя. Параметры из
+// условий сначала связываются с ячейками, уже содержащими нужное право,
+// а оставшиеся пробегают всех субъектов или все объекты.
+func bindings(state *State, cmd Command) [][]string {
+	created := make(map[string]bool)
+	subjectParams := make(map[string]bool)
+	for _, cond := range cmd.Conditions {
+		subjectParams[cond.Subject] = true
+	}
+	for _, op := range cmd.Operations {
+		switch op.Kind {
+		case CreateSubject:
+			created[op.Subject] = true
+		case CreateObject:
+			created[op.Object] = true
+		case EnterRight, DeleteRight, DestroySubject:
+			subjectParams[op.Subject] = true
+		}
+	}
+
+	bound := make(map[string]string)
+	taken := make(map[string]bool)
+	for _, param := range cmd.Params {
+		if created[param] && bound[param] == "" {
+			name := state.freshName(taken)
+			taken[name] = true
+			bound[param] = name
+		}
+	}
+
+	var result [][]string
+	cells := state.cells()
+	subjects := sortedKeys(state.subjects)
+	objects := state.Objects()
+
+	var fill func(i int)
+	fill = func(i int) {
+		if i == len(cmd.Params) {
+			args := make([]string, len(cmd.Params))
+			for j, param := range cmd.Params {
+				args[j] = bound[param]
+			}
+			result = append(result, args)
+			return
+		}
+		param := cmd.Params[i]
+		if _, ok := bound[param]; ok {
+			fill(i + 1)
+			return
+		}
+		candidates := objects
+		if subjectParams[param] {
+			candidates = subjects
+		}
+		for _, name := range candidates {
+			bound[param] = name
+			fill(i + 1)
+		}
+		delete(bound, param)
+	}
+
+	var match func(i int)
+	match = func(i int) {
+		if i == len(cmd.Conditions) {
+			fill(0)
+			return
+		}
+		cond := cmd.Conditions[i]
+		for _, c := range cells {
+			if !state.rights[c][cond.Right] {
+				continue
+			}
+			var newly []string
+			ok := true
+			for _, pair := range [][2]string{{cond.Subject, c.subject}, {cond.Object, c.object}} {
+				if value, exists := bound[pair[0]]; exists {
+					if value != pair[1] {
+						ok = false
+						break
+					}
+					continue
+				}
+				bound[pair[0]] = pair[1]
+				newly = append(newly, pair[0])
+			}
+			if ok {
+				match(i + 1)
+			}
+			for _, param := range newly {
+				delete(bound, param)
+			}
+		}
+	}
+	match(0)
+
+	return result
+}
@@ -0,0 +1,313 @@
+// Package hru реализует модель Харрисона-Руззо-Ульмана (HRU) и анализ
+// безопасности матрицы доступа: поиск достижимых состояний, в которых
+// право может «утечь» к заданному субъекту.
+package hru
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+	"sort"
+	"strings"
+)
+
+// ReadRight - право, которым в базе данных описывается связь user_letters.
+const ReadRight = "r"
+
+type OpKind int
+
+const (
+	EnterRight OpKind = iota
+	DeleteRight
+	CreateSubject
+	CreateObject
+	DestroySubject
+	DestroyObject
+)
+
+// Condition - условие команды вида "R in [S, O]". Поля Subject и Object
+// содержат имена формальных параметров команды.
+type Condition struct {
+	Right   string
+	Subject string
+	Object  string
+}
+
+// Operation - примитивная операция HRU. Для операций создания и удаления
+// используется только поле Subject (или Object).
+type Operation struct {
+	Kind    OpKind
+	Right   string
+	Subject string
+	Object  string
+}
+
+type Command struct {
+	Name       string
+	Params     []string
+	Conditions []Condition
+	Operations []Operation
+}
+
+// Grant - право Right субъекта Subject на объект Object.
+type Grant struct {
+	Right   string
+	Subject string
+	Object  string
+}
+
+type cell struct {
+	subject string
+	object  string
+}
+
+// State - состояние системы: множества субъектов, объектов и матрица доступа.
+// Субъекты одновременно являются объектами, как и в классической модели.
+type State struct {
+	subjects map[string]bool
+	objects  map[string]bool
+	rights   map[cell]map[string]bool
+}
+
+func NewState() *State {
+	return &State{
+		subjects: make(map[string]bool),
+		objects:  make(map[string]bool),
+		rights:   make(map[cell]map[string]bool),
+	}
+}
+
+// FromDatabase строит начальное состояние по текущей матрице доступа:
+// пользователи становятся субъектами, буквы - объектами, а записи
+// user_letters - правами ReadRight.
+func FromDatabase(db *sql.DB) (*State, error) {
+	users, err := database.GetAllUsers(db)
+	if err != nil {
+		return nil, err
+	}
+	letters, err := database.GetAllLetters(db)
+	if err != nil {
+		return nil, err
+	}
+
+	state := NewState()
+	for _, user := range users {
+		state.AddSubject(user)
+	}
+	for _, letter := range letters {
+		state.AddObject(letter)
+	}
+
+	for _, user := range users {
+		userID, err := database.FindUser(db, user)
+		if err != nil {
+			return nil, err
+		}
+		permissions, err := database.GetPermissions(db, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, letter := range permissions {
+			state.Enter(ReadRight, user, letter)
+		}
+	}
+
+	return state, nil
+}
+
+func (s *State) AddSubject(name string) {
+	s.subjects[name] = true
+	s.objects[name] = true
+}
+
+func (s *State) AddObject(name string) {
+	s.objects[name] = true
+}
+
+func (s *State) IsSubject(name string) bool {
+	return s.subjects[name]
+}
+
+func (s *State) IsObject(name string) bool {
+	return s.objects[name]
+}
+
+func (s *State) Has(right, subject, object string) bool {
+	return s.rights[cell{subject, object}][right]
+}
+
+// Enter добавляет право в ячейку [subject, object] без проверки
+// существования субъекта и объекта. Используется при построении
+// начального состояния.
+func (s *State) Enter(right, subject, object string) {
+	c := cell{subject, object}
+	if s.rights[c] == nil {
+		s.rights[c] = make(map[string]bool)
+	}
+	s.rights[c][right] = true
+}
+
+// AddGrants добавляет права к состоянию, создавая недостающих субъектов
+// и объекты.
+func (s *State) AddGrants(grants []Grant) {
+	for _, g := range grants {
+		if !s.subjects[g.Subject] {
+			s.AddSubject(g.Subject)
+		}
+		if !s.objects[g.Object] {
+			s.AddObject(g.Object)
+		}
+		s.Enter(g.Right, g.Subject, g.Object)
+	}
+}
+
+func (s *State) Objects() []string {
+	return sortedKeys(s.objects)
+}
+
+func (s *State) cells() []cell {
+	cells := make([]cell, 0, len(s.rights))
+	for c := range s.rights {
+		cells = append(cells, c)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].subject != cells[j].subject {
+			return cells[i].subject < cells[j].subject
+		}
+		return cells[i].object < cells[j].object
+	})
+	return cells
+}
+
+func (s *State) clone() *State {
+	c := NewState()
+	for name := range s.subjects {
+		c.subjects[name] = true
+	}
+	for name := range s.objects {
+		c.objects[name] = true
+	}
+	for key, set := range s.rights {
+		copied := make(map[string]bool, len(set))
+		for right := range set {
+			copied[right] = true
+		}
+		c.rights[key] = copied
+	}
+	return c
+}
+
+// key возвращает каноническое представление состояния для отсечения повторов.
+func (s *State) key() string {
+	var b strings.Builder
+	b.WriteString("S:")
+	b.WriteString(strings.Join(sortedKeys(s.subjects), ","))
+	b.WriteString("|O:")
+	b.WriteString(strings.Join(sortedKeys(s.objects), ","))
+
+	entries := make([]string, 0, len(s.rights))
+	for c, set := range s.rights {
+		if len(set) == 0 {
+			continue
+		}
+		entries = append(entries, c.subject+"\x00"+c.object+"\x00"+strings.Join(sortedKeys(set), ","))
+	}
+	sort.Strings(entries)
+	b.WriteString("|M:")
+	b.WriteString(strings.Join(entries, ";"))
+	return b.String()
+}
+
+func (s *State) freshName(taken map[string]bool) string {
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("new%d", i)
+		if !s.objects[name] && !taken[name] {
+			return name
+		}
+	}
+}
+
+func (s *State) apply(op Operation, args map[string]string) bool {
+	subject := args[op.Subject]
+	object := args[op.Object]
+
+	switch op.Kind {
+	case EnterRight:
+		if !s.subjects[subject] || !s.objects[object] {
+			return false
+		}
+		s.Enter(op.Right, subject, object)
+	case DeleteRight:
+		if !s.subjects[subject] || !s.objects[object] {
+			return false
+		}
+		delete(s.rights[cell{subject, object}], op.Right)
+	case CreateSubject:
+		if s.objects[subject] {
+			return false
+		}
+		s.AddSubject(subject)
+	case CreateObject:
+		if s.objects[object] {
+			return false
+		}
+		s.AddObject(object)
+	case DestroySubject:
+		if !s.subjects[subject] {
+			return false
+		}
+		delete(s.subjects, subject)
+		delete(s.objects, subject)
+		for c := range s.rights {
+			if c.subject == subject || c.object == subject {
+				delete(s.rights, c)
+			}
+		}
+	case DestroyObject:
+		if !s.objects[object] || s.subjects[object] {
+			return false
+		}
+		delete(s.objects, object)
+		for c := range s.rights {
+			if c.object == object {
+				delete(s.rights, c)
+			}
+		}
+	}
+	return true
+}
+
+// Run выполняет команду с фактическими параметрами args. Если условия
+// команды не выполнены или одна из операций недопустима, возвращается nil.
+func (s *State) Run(cmd Command, args []string) *State {
+	if len(args) != len(cmd.Params) {
+		return nil
+	}
+	bound := make(map[string]string, len(args))
+	for i, param := range cmd.Params {
+		bound[param] = args[i]
+	}
+
+	for _, cond := range cmd.Conditions {
+		if !s.Has(cond.Right, bound[cond.Subject], bound[cond.Object]) {
+			return nil
+		}
+	}
+
+	next := s.clone()
+	for _, op := range cmd.Operations {
+		if !next.apply(op, bound) {
+			return nil
+		}
+	}
+	return next
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,225 @@
+package hru
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const grantSpec = `
+# владелец может передать право чтения
+right own ivan doc
+
+command grant_read(s, o, t)
+if own in [s, o]
+then
+  enter r into [t, o]
+end
+`
+
+const transferSpec = `
+command transfer_own(s, o, t)
+if own in [s, o]
+then
+  enter own into [t, o]
+end
+
+command read_own(s, o)
+if own in [s, o]
+then
+  enter r into [s, o]
+end
+`
+
+func mustParse(t *testing.T, text string) *Spec {
+	t.Helper()
+	spec, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return spec
+}
+
+func TestParse(t *testing.T) {
+	spec := mustParse(t, grantSpec)
+
+	wantInitial := []Grant{{Right: "own", Subject: "ivan", Object: "doc"}}
+	if !reflect.DeepEqual(spec.Initial, wantInitial) {
+		t.Errorf("Initial = %v, want %v", spec.Initial, wantInitial)
+	}
+	wantCommands := []Command{{
+		Name:       "grant_read",
+		Params:     []string{"s", "o", "t"},
+		Conditions: []Condition{{Right: "own", Subject: "s", Object: "o"}},
+		Operations: []Operation{{Kind: EnterRight, Right: "r", Subject: "t", Object: "o"}},
+	}}
+	if !reflect.DeepEqual(spec.Commands, wantCommands) {
+		t.Errorf("Commands = %+v, want %+v", spec.Commands, wantCommands)
+	}
+}
+
+func TestParseOperations(t *testing.T) {
+	spec := mustParse(t, `
+command lifecycle(s, o, x, y)
+if r in [s, o]
+and w in [s, o]
+then
+  create subject x
+  create object y
+  delete r from [s, o]
+  destroy subject x
+  destroy object y
+end
+`)
+	if len(spec.Commands) != 1 {
+		t.Fatalf("команд: %d, want 1", len(spec.Commands))
+	}
+	cmd := spec.Commands[0]
+	if len(cmd.Conditions) != 2 {
+		t.Errorf("условий: %d, want 2", len(cmd.Conditions))
+	}
+	var kinds []OpKind
+	for _, op := range cmd.Operations {
+		kinds = append(kinds, op.Kind)
+	}
+	want := []OpKind{CreateSubject, CreateObject, DeleteRight, DestroySubject, DestroyObject}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("операции %v, want %v", kinds, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"плохая строка right", "right own ivan", "строка 1"},
+		{"нет заголовка", "enter r into [s, o]", "ожидается 'command"},
+		{"условие после then", "command c(s, o)\nthen\nif r in [s, o]\nend", "условие после 'then'"},
+		{"неизвестный параметр", "command c(s)\nif r in [s, o]\nthen\nenter r into [s, s]\nend", "неизвестный параметр 'o'"},
+		{"неизвестная операция", "command c(s, o)\nthen\ncopy r to [s, o]\nend", "неизвестная операция"},
+		{"нет end", "command c(s, o)\nthen\nenter r into [s, o]", "не завершена"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.text))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want содержащую %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// replay выполняет контрпример и проверяет, что он действительно приводит
+// к утечке.
+func replay(t *testing.T, state *State, commands []Command, steps []Step) *State {
+	t.Helper()
+	byName := make(map[string]Command, len(commands))
+	for _, cmd := range commands {
+		byName[cmd.Name] = cmd
+	}
+	for _, step := range steps {
+		next := state.Run(byName[step.Command], step.Args)
+		if next == nil {
+			t.Fatalf("шаг %s не выполняется", step)
+		}
+		state = next
+	}
+	return state
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      string
+		grants    []Grant
+		query     Query
+		leaked    bool
+		wantTrace []Step
+	}{
+		{
+			name:      "передача права чтения владельцем",
+			spec:      grantSpec,
+			query:     Query{Right: "r", Subject: "petr", Object: "doc"},
+			leaked:    true,
+			wantTrace: []Step{{Command: "grant_read", Args: []string{"ivan", "doc", "petr"}}},
+		},
+		{
+			name:   "передача владения и чтение",
+			spec:   transferSpec,
+			grants: []Grant{{Right: "own", Subject: "ivan", Object: "doc"}},
+			query:  Query{Right: "r", Subject: "petr"},
+			leaked: true,
+			wantTrace: []Step{
+				{Command: "transfer_own", Args: []string{"ivan", "doc", "petr"}},
+				{Command: "read_own", Args: []string{"petr", "doc"}},
+			},
+		},
+		{
+			name:   "без владельцев право не передаётся",
+			spec:   transferSpec,
+			grants: []Grant{{Right: "r", Subject: "ivan", Object: "doc"}},
+			query:  Query{Right: "r", Subject: "petr", Object: "doc"},
+		},
+		{
+			name:   "право уже есть в начальном состоянии",
+			spec:   grantSpec,
+			grants: []Grant{{Right: "r", Subject: "petr", Object: "doc"}},
+			query:  Query{Right: "r", Subject: "petr", Object: "doc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := mustParse(t, tt.spec)
+			state := NewState()
+			state.AddSubject("ivan")
+			state.AddSubject("petr")
+			state.AddObject("doc")
+			state.AddGrants(spec.Initial)
+			state.AddGrants(tt.grants)
+
+			result := Analyze(state, spec.Commands, tt.query, Limits{MaxDepth: 3})
+			if result.Leaked != tt.leaked {
+				t.Fatalf("Leaked = %v, want %v (trace %v)", result.Leaked, tt.leaked, result.Trace)
+			}
+			if !tt.leaked {
+				if !result.Complete {
+					t.Errorf("поиск не завершён: рассмотрено %d состояний", result.Explored)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(result.Trace, tt.wantTrace) {
+				t.Errorf("Trace = %v, want %v", result.Trace, tt.wantTrace)
+			}
+			final := replay(t, state, spec.Commands, result.Trace)
+			if !final.Has(tt.query.Right, result.Subject, result.Object) {
+				t.Errorf("после контрпримера нет права %s в [%s, %s]", tt.query.Right, result.Subject, result.Object)
+			}
+		})
+	}
+}
+
+func TestAnalyzeStateLimit(t *testing.T) {
+	spec := mustParse(t, `
+command spawn(s, x)
+if r in [s, s]
+then
+  create object x
+  enter r into [s, x]
+end
+`)
+	state := NewState()
+	state.AddGrants([]Grant{{Right: "r", Subject: "ivan", Object: "ivan"}})
+	state.AddSubject("petr")
+
+	result := Analyze(state, spec.Commands, Query{Right: "r", Subject: "petr"}, Limits{MaxDepth: 10, MaxStates: 5})
+	if result.Leaked {
+		t.Fatalf("неожиданная утечка: %v", result.Trace)
+	}
+	if result.Complete {
+		t.Errorf("Complete = true при достигнутом лимите состояний")
+	}
+}
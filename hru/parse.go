@@ -0,0 +1,165 @@
+package hru
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Spec - содержимое файла команд: сами команды и дополнительные права,
+// добавляемые к начальному состоянию из базы данных.
+//
+// Формат файла:
+//
+//	# комментарий
+//	right own ivan a
+//
+//	command grant_read(s, o, t)
+//	if own in [s, o]
+//	and r in [s, o]
+//	then
+//	  enter r into [t, o]
+//	end
+//
+// Доступные операции: enter R into [S, O], delete R from [S, O],
+// create subject X, create object X, destroy subject X, destroy object X.
+type Spec struct {
+	Commands []Command
+	Initial  []Grant
+}
+
+var (
+	headerPattern    = regexp.MustCompile(`^command\s+(\w+)\s*\(([^)]*)\)$`)
+	conditionPattern = regexp.MustCompile(`^(?:if|and)\s+(\S+)\s+in\s+\[\s*(\w+)\s*,\s*(\w+)\s*\]$`)
+	enterPattern     = regexp.MustCompile(`^enter\s+(\S+)\s+into\s+\[\s*(\w+)\s*,\s*(\w+)\s*\]$`)
+	deletePattern    = regexp.MustCompile(`^delete\s+(\S+)\s+from\s+\[\s*(\w+)\s*,\s*(\w+)\s*\]$`)
+	lifecyclePattern = regexp.MustCompile(`^(create|destroy)\s+(subject|object)\s+(\w+)$`)
+)
+
+func Parse(r io.Reader) (*Spec, error) {
+	spec := &Spec{}
+	scanner := bufio.NewScanner(r)
+
+	var current *Command
+	inBody := false
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if current == nil {
+			if fields := strings.Fields(line); fields[0] == "right" {
+				if len(fields) != 4 {
+					return nil, fmt.Errorf("строка %d: ожидается 'right <право> <субъект> <объект>'", lineNo)
+				}
+				spec.Initial = append(spec.Initial, Grant{Right: fields[1], Subject: fields[2], Object: fields[3]})
+				continue
+			}
+
+			m := headerPattern.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("строка %d: ожидается 'command имя(параметры)'", lineNo)
+			}
+			current = &Command{Name: m[1]}
+			for _, param := range strings.Split(m[2], ",") {
+				if param = strings.TrimSpace(param); param != "" {
+					current.Params = append(current.Params, param)
+				}
+			}
+			inBody = false
+			continue
+		}
+
+		switch {
+		case line == "end":
+			if err := checkParams(current); err != nil {
+				return nil, fmt.Errorf("строка %d: %v", lineNo, err)
+			}
+			spec.Commands = append(spec.Commands, *current)
+			current = nil
+		case line == "then":
+			inBody = true
+		case conditionPattern.MatchString(line):
+			if inBody {
+				return nil, fmt.Errorf("строка %d: условие после 'then'", lineNo)
+			}
+			m := conditionPattern.FindStringSubmatch(line)
+			current.Conditions = append(current.Conditions, Condition{Right: m[1], Subject: m[2], Object: m[3]})
+		default:
+			op, err := parseOperation(line)
+			if err != nil {
+				return nil, fmt.Errorf("строка %d: %v", lineNo, err)
+			}
+			inBody = true
+			current.Operations = append(current.Operations, op)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		return nil, fmt.Errorf("команда '%s' не завершена словом 'end'", current.Name)
+	}
+	return spec, nil
+}
+
+func parseOperation(line string) (Operation, error) {
+	if m := enterPattern.FindStringSubmatch(line); m != nil {
+		return Operation{Kind: EnterRight, Right: m[1], Subject: m[2], Object: m[3]}, nil
+	}
+	if m := deletePattern.FindStringSubmatch(line); m != nil {
+		return Operation{Kind: DeleteRight, Right: m[1], Subject: m[2], Object: m[3]}, nil
+	}
+	if m := lifecyclePattern.FindStringSubmatch(line); m != nil {
+		switch m[1] + " " + m[2] {
+		case "create subject":
+			return Operation{Kind: CreateSubject, Subject: m[3]}, nil
+		case "create object":
+			return Operation{Kind: CreateObject, Object: m[3]}, nil
+		case "destroy subject":
+			return Operation{Kind: DestroySubject, Subject: m[3]}, nil
+		default:
+			return Operation{Kind: DestroyObject, Object: m[3]}, nil
+		}
+	}
+	return Operation{}, fmt.Errorf("неизвестная операция '%s'", line)
+}
+
+func checkParams(cmd *Command) error {
+	known := make(map[string]bool, len(cmd.Params))
+	for _, param := range cmd.Params {
+		known[param] = true
+	}
+
+	check := func(names ...string) error {
+		for _, name := range names {
+			if name != "" && !known[name] {
+				return fmt.Errorf("команда '%s': неизвестный параметр '%s'", cmd.Name, name)
+			}
+		}
+		return nil
+	}
+
+	for _, cond := range cmd.Conditions {
+		if err := check(cond.Subject, cond.Object); err != nil {
+			return err
+		}
+	}
+	for _, op := range cmd.Operations {
+		if err := check(op.Subject, op.Object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+// Package policy синхронизирует базу с декларативным файлом политики
+// доступа: вычисляет план изменений (plan), применяет его в одной
+// транзакции (apply) и находит изменения, сделанные в базе после
+// последней синхронизации (drift).
+package policy
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"laba3/database"
+	"laba3/grapheme"
+	"time"
+)
+
+// Version - версия формата файла политики.
+const Version = 1
+
+// Policy - желаемое состояние базы. Пользователи, буквы, права и правила,
+// которых нет в файле, удаляются. Настройки управляются, только если
+// раздел settings задан; рёбра Take-Grant файлом не управляются.
+type Policy struct {
+	Version  int               `json:"version" yaml:"version"`
+	Settings map[string]string `json:"settings,omitempty" yaml:"settings,omitempty"`
+	Roles    map[string]Role   `json:"roles,omitempty" yaml:"roles,omitempty"`
+	Letters  []Letter          `json:"letters" yaml:"letters"`
+	Users    []User            `json:"users" yaml:"users"`
+}
+
+// Role - именованный набор букв и правил. Роли существуют только в файле:
+// в базу записываются развёрнутые права пользователей.
+type Role struct {
+	Letters []string `json:"letters,omitempty" yaml:"letters,omitempty"`
+	Rules   []string `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+// Letter - буква; RenamedFrom задаёт прежний символ, чтобы буква
+// переименовывалась с сохранением прав, а не удалялась и создавалась.
+type Letter struct {
+	database.LetterConfig `yaml:",inline"`
+	RenamedFrom           string `json:"renamed_from,omitempty" yaml:"renamed_from,omitempty"`
+}
+
+// User - пользователь с явными буквами, правилами и ролями; RenamedFrom
+// задаёт прежнее имя.
+type User struct {
+	database.UserConfig `yaml:",inline"`
+	Roles               []string `json:"roles,omitempty" yaml:"roles,omitempty"`
+	RenamedFrom         string   `json:"renamed_from,omitempty" yaml:"renamed_from,omitempty"`
+}
+
+func Read(r io.Reader, format database.Format) (*Policy, error) {
+	var p Policy
+	if err := database.Decode(r, format, &p); err != nil {
+		return nil, err
+	}
+	if p.Version != Version {
+		return nil, fmt.Errorf("неподдерживаемая версия политики %d (ожидается %d)", p.Version, Version)
+	}
+	for _, user := range p.Users {
+		for _, role := range user.Roles {
+			if _, ok := p.Roles[role]; !ok {
+				return nil, fmt.Errorf("пользователь '%s': неизвестная роль '%s'", user.Name, role)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// Plan возвращает изменения, которые внесёт Apply. База не изменяется.
+func Plan(db *sql.DB, p *Policy) ([]database.Change, error) {
+	return run(db, p, "", true, nil)
+}
+
+// ErrPlanChanged - база изменилась после того, как был показан план,
+// и Apply внёс бы изменения, которых оператор не видел.
+var ErrPlanChanged = errors.New("база изменилась после показа плана: изменения не применены, проверьте новый план")
+
+// Apply приводит базу к политике в одной транзакции и запоминает
+// полученное состояние для поиска расхождений. source - имя файла
+// политики, planned - подтверждённый план (результат Plan): если
+// пересчитанный в транзакции план от него отличается, транзакция
+// откатывается с ошибкой ErrPlanChanged.
+func Apply(db *sql.DB, p *Policy, source string, planned []database.Change) ([]database.Change, error) {
+	return run(db, p, source, false, planned)
+}
+
+func run(db *sql.DB, p *Policy, source string, dryRun bool, planned []database.Change) ([]database.Change, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	changes, err := renameAll(tx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := database.Export(tx)
+	if err != nil {
+		return nil, err
+	}
+	more, err := database.ImportTx(tx, p.config(current), database.ImportReplace)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, more...)
+
+	if dryRun {
+		return changes, nil
+	}
+	if !samePlan(changes, planned) {
+		return nil, ErrPlanChanged
+	}
+	if err := database.SaveSyncState(tx, source); err != nil {
+		return nil, err
+	}
+	return changes, tx.Commit()
+}
+
+// samePlan сравнивает планы без учёта порядка изменений.
+func samePlan(a, b []database.Change) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[database.Change]int, len(a))
+	for _, change := range a {
+		counts[change]++
+	}
+	for _, change := range b {
+		if counts[change] == 0 {
+			return false
+		}
+		counts[change]--
+	}
+	return true
+}
+
+// renameAll выполняет переименования. Переименование пропускается, если
+// прежнего объекта уже нет: политика могла быть применена раньше.
+func renameAll(tx *sql.Tx, p *Policy) ([]database.Change, error) {
+	var changes []database.Change
+
+	for _, user := range p.Users {
+		if user.RenamedFrom == "" || user.RenamedFrom == user.Name {
+			continue
+		}
+		userID, err := database.GetUserID(tx, user.RenamedFrom)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := database.GetUserID(tx, user.Name); err == nil {
+			return nil, fmt.Errorf("нельзя переименовать '%s' в '%s': пользователь уже существует", user.RenamedFrom, user.Name)
+		}
+		if err := database.UpdateUserName(tx, userID, user.Name); err != nil {
+			return nil, err
+		}
+		changes = append(changes, database.Change{
+			Kind:   database.ChangeUpdate,
+			Object: fmt.Sprintf("пользователь %s → %s", user.RenamedFrom, user.Name),
+		})
+	}
+
+	for _, letter := range p.Letters {
+		from, to := grapheme.Normalize(letter.RenamedFrom), grapheme.Normalize(letter.Char)
+		if from == "" || from == to {
+			continue
+		}
+		letterID, err := database.GetLetterID(tx, from)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// UpdateLetter сам отказывает, если новая буква уже существует
+		if err := database.UpdateLetter(tx, letterID, to); err != nil {
+			return nil, err
+		}
+		changes = append(changes, database.Change{
+			Kind:   database.ChangeUpdate,
+			Object: fmt.Sprintf("буква %s → %s", from, to),
+		})
+	}
+	return changes, nil
+}
+
+// config разворачивает роли и дополняет политику тем, чем она не
+// управляет: настройками (если раздел не задан), сроками временных прав,
+// не заданными в файле, и рёбрами Take-Grant между остающимися
+// пользователями и буквами.
+func (p *Policy) config(current *database.Config) *database.Config {
+	cfg := &database.Config{Version: database.ConfigVersion, Settings: p.Settings}
+	if cfg.Settings == nil {
+		cfg.Settings = current.Settings
+	}
+
+	letters := make(map[string]bool)
+	for _, letter := range p.Letters {
+		cfg.Letters = append(cfg.Letters, letter.LetterConfig)
+		letters[grapheme.Normalize(letter.Char)] = true
+	}
+
+	expires := make(map[string]map[string]time.Time, len(current.Users))
+	for _, user := range current.Users {
+		expires[user.Name] = user.Expires
+	}
+
+	users := make(map[string]bool)
+	for _, user := range p.Users {
+		expanded := database.UserConfig{Name: user.Name}
+		expanded.Letters = append(expanded.Letters, user.Letters...)
+		expanded.Rules = append(expanded.Rules, user.Rules...)
+		for _, name := range user.Roles {
+			role := p.Roles[name]
+			expanded.Letters = append(expanded.Letters, role.Letters...)
+			expanded.Rules = append(expanded.Rules, role.Rules...)
+		}
+		for _, letter := range expanded.Letters {
+			letters[grapheme.Normalize(letter)] = true
+			until, ok := user.Expires[letter]
+			if !ok {
+				until, ok = expires[user.Name][grapheme.Normalize(letter)]
+			}
+			if ok {
+				if expanded.Expires == nil {
+					expanded.Expires = make(map[string]time.Time)
+				}
+				expanded.Expires[letter] = until
+			}
+		}
+		cfg.Users = append(cfg.Users, expanded)
+		users[user.Name] = true
+	}
+
+	for _, edge := range current.Edges {
+		if users[edge.From] && (edge.User == "" || users[edge.User]) && (edge.Letter == "" || letters[edge.Letter]) {
+			cfg.Edges = append(cfg.Edges, edge)
+		}
+	}
+	return cfg
+}
+
+// DriftReport - расхождение базы с состоянием после последней
+// синхронизации.
+type DriftReport struct {
+	AppliedAt time.Time
+	Source    string
+	Changes   []database.Change
+}
+
+// Drift сравнивает базу с состоянием после последнего Apply. Если
+// синхронизаций не было, возвращается sql.ErrNoRows.
+func Drift(db database.Querier) (*DriftReport, error) {
+	state, err := database.GetSyncState(db)
+	if err != nil {
+		return nil, err
+	}
+	current, err := database.Export(db)
+	if err != nil {
+		return nil, err
+	}
+	return &DriftReport{
+		AppliedAt: state.AppliedAt,
+		Source:    state.Source,
+		Changes:   database.Diff(state.Config, current),
+	}, nil
+}
+
+// Summary считает изменения по видам, как итоговая строка плана.
+func Summary(changes []database.Change) string {
+	counts := make(map[database.ChangeKind]int)
+	for _, change := range changes {
+		counts[change.Kind]++
+	}
+	return fmt.Sprintf("добавить %d, изменить %d, удалить %d",
+		counts[database.ChangeAdd], counts[database.ChangeUpdate], counts[database.ChangeRemove])
+}
@@ -0,0 +1,156 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Классы символов, которые могут быть объектами доступа.
+const (
+	ClassLetters = "letters"
+	ClassDigits  = "digits"
+	ClassPunct   = "punct"
+	ClassSymbols = "symbols"
+	ClassEmoji   = "emoji"
+	ClassSpace   = "space"
+)
+
+var AlphabetClasses = []string{ClassLetters, ClassDigits, ClassPunct, ClassSymbols, ClassEmoji, ClassSpace}
+
+// DefaultAlphabet - по умолчанию объектами могут быть любые символы:
+// буквы, цифры, знаки препинания, символы, эмодзи и пробельные символы.
+var DefaultAlphabet = strings.Join(AlphabetClasses, ",")
+
+// emoji - основные блоки эмодзи, а также модификаторы и соединители,
+// из которых составляются последовательности эмодзи.
+var emoji = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x200d, Hi: 0x200d, Stride: 1},
+		{Lo: 0x2600, Hi: 0x27bf, Stride: 1},
+		{Lo: 0x2b00, Hi: 0x2bff, Stride: 1},
+		{Lo: 0xfe0f, Hi: 0xfe0f, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1faff, Stride: 1},
+	},
+}
+
+func IsEmoji(r rune) bool {
+	return unicode.Is(emoji, r)
+}
+
+// Alphabet - набор классов символов, которые администратор может выдавать
+// в качестве объектов доступа.
+type Alphabet struct {
+	classes map[string]bool
+}
+
+func ParseAlphabet(value string) (Alphabet, error) {
+	alphabet := Alphabet{classes: make(map[string]bool)}
+	for _, class := range strings.Split(value, ",") {
+		class = strings.TrimSpace(class)
+		if class == "" {
+			continue
+		}
+		known := false
+		for _, c := range AlphabetClasses {
+			if c == class {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return Alphabet{}, fmt.Errorf("неизвестный класс символов '%s'", class)
+		}
+		alphabet.classes[class] = true
+	}
+	return alphabet, nil
+}
+
+func (a Alphabet) Has(class string) bool {
+	return a.classes[class]
+}
+
+func (a Alphabet) Classes() []string {
+	var classes []string
+	for _, class := range AlphabetClasses {
+		if a.classes[class] {
+			classes = append(classes, class)
+		}
+	}
+	return classes
+}
+
+func (a Alphabet) String() string {
+	return strings.Join(a.Classes(), ",")
+}
+
+// Allows сообщает, может ли кластер графем быть объектом доступа. Класс
+// определяется по базовому символу кластера.
+func (a Alphabet) Allows(cluster string) bool {
+	r := Base(cluster)
+	switch {
+	case IsEmoji(r):
+		return a.classes[ClassEmoji]
+	case unicode.IsLetter(r) || unicode.IsMark(r):
+		return a.classes[ClassLetters]
+	case unicode.IsNumber(r):
+		return a.classes[ClassDigits]
+	case unicode.IsPunct(r):
+		return a.classes[ClassPunct]
+	case unicode.IsSymbol(r):
+		return a.classes[ClassSymbols]
+	case unicode.IsSpace(r):
+		return a.classes[ClassSpace]
+	}
+	return false
+}
+
+// WhitespacePolicy определяет, какие пробельные символы пропускаются
+// фильтром без проверки прав.
+type WhitespacePolicy string
+
+const (
+	// WhitespaceNone - пробельные символы проверяются наравне с остальными.
+	WhitespaceNone WhitespacePolicy = "none"
+	// WhitespaceStandard - пропускаются пробел, \n, \t и \r.
+	WhitespaceStandard WhitespacePolicy = "standard"
+	// WhitespaceAll - пропускаются все пробельные символы Unicode.
+	WhitespaceAll WhitespacePolicy = "all"
+)
+
+var WhitespacePolicies = []WhitespacePolicy{WhitespaceNone, WhitespaceStandard, WhitespaceAll}
+
+func ParseWhitespacePolicy(value string) (WhitespacePolicy, error) {
+	for _, policy := range WhitespacePolicies {
+		if string(policy) == value {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("неизвестная политика пробельных символов '%s'", value)
+}
+
+// Passes сообщает, пропускается ли кластер графем без проверки прав:
+// все его символы должны подпадать под политику (так "\r\n" остаётся
+// одним пропускаемым кластером).
+func (p WhitespacePolicy) Passes(cluster string) bool {
+	if cluster == "" {
+		return false
+	}
+	for _, r := range cluster {
+		switch p {
+		case WhitespaceStandard:
+			if r != ' ' && r != '\n' && r != '\t' && r != '\r' {
+				return false
+			}
+		case WhitespaceAll:
+			if !unicode.IsSpace(r) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
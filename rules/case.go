@@ -0,0 +1,89 @@
+package rules
+
+import (
+	"fmt"
+	"laba3/grapheme"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+)
+
+// CasePolicy определяет, как выданное право на букву распространяется на
+// другие регистры той же буквы. Политика задаётся глобально и может быть
+// переопределена для отдельной буквы.
+type CasePolicy string
+
+const (
+	// CaseExact - право действует только на букву в том виде, в котором выдано.
+	CaseExact CasePolicy = "exact"
+	// CaseInsensitive - право действует на все регистры буквы (по Unicode
+	// case folding: "а" и "А", "ß" и "ẞ", "σ", "ς" и "Σ").
+	CaseInsensitive CasePolicy = "insensitive"
+	// CaseUpper - право действует только на заглавную форму буквы: право
+	// на "а" разрешает "А", но не "а". Сама выданная форма разрешена, только
+	// если она уже заглавная или у буквы нет заглавной формы из одного
+	// символа: право на "ß" разрешает "ß" (см. UpperCase).
+	CaseUpper CasePolicy = "upper"
+)
+
+var CasePolicies = []CasePolicy{CaseExact, CaseInsensitive, CaseUpper}
+
+func ParseCasePolicy(value string) (CasePolicy, error) {
+	for _, policy := range CasePolicies {
+		if string(policy) == value {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("неизвестная политика регистра '%s'", value)
+}
+
+// FoldCase возвращает ключ сравнения кластера без учёта регистра.
+func FoldCase(cluster string) string {
+	return grapheme.Normalize(cases.Fold().String(cluster))
+}
+
+// UpperCase возвращает заглавную форму кластера в NFC. Используется
+// простое отображение Unicode (символ в символ): полное превращает "ß"
+// в "SS", а такой ключ никогда не совпадёт с одним введённым кластером.
+// Если и простое отображение даёт не один кластер, кластер возвращается
+// без изменений.
+func UpperCase(cluster string) string {
+	cluster = grapheme.Normalize(cluster)
+	upper := grapheme.Normalize(strings.Map(unicode.ToUpper, cluster))
+	if len(grapheme.Split(upper)) != 1 {
+		return cluster
+	}
+	return upper
+}
+
+// LetterSet - набор выданных букв с учётом политики регистра каждой из них.
+// Нулевое значение - пустой набор.
+type LetterSet struct {
+	exact  map[string]bool
+	folded map[string]bool
+}
+
+func (s *LetterSet) Add(letter string, policy CasePolicy) {
+	if s.exact == nil {
+		s.exact = make(map[string]bool)
+		s.folded = make(map[string]bool)
+	}
+
+	switch policy {
+	case CaseInsensitive:
+		s.folded[FoldCase(letter)] = true
+	case CaseUpper:
+		s.exact[UpperCase(letter)] = true
+	default:
+		s.exact[letter] = true
+	}
+}
+
+// Contains сообщает, разрешён ли кластер графем хотя бы одним правом набора.
+func (s LetterSet) Contains(cluster string) bool {
+	if s.exact[cluster] {
+		return true
+	}
+	return len(s.folded) > 0 && s.folded[FoldCase(cluster)]
+}
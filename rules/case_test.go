@@ -0,0 +1,77 @@
+package rules
+
+import "testing"
+
+// Символы записаны escape-последовательностями, чтобы составные
+// и разложенные формы не путались при редактировании.
+const (
+	sharpS        = "\u00df"       // ß
+	capitalSharpS = "\u1e9e"       // ẞ
+	dottedI       = "\u0130"       // İ
+	dotlessI      = "\u0131"       // ı
+	shortI        = "\u0439"       // й
+	shortIUpper   = "\u0419"       // Й
+	shortIDecomp  = "\u0438\u0306" // и + комбинируемое бреве
+	alphaYpogegr  = "\u03b1\u0345" // α + комбинируемая ипогеграммени
+	alphaProsgegr = "\u1fbc"       // ᾼ
+)
+
+func TestUpperCase(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"кириллица", "а", "А"},
+		{"заглавная", "А", "А"},
+		{"эсцет без однобуквенной заглавной", sharpS, sharpS},
+		{"заглавная эсцет", capitalSharpS, capitalSharpS},
+		{"i без точки", dotlessI, "I"},
+		{"латинская i", "i", "I"},
+		{"I с точкой", dottedI, dottedI},
+		{"составная й", shortI, shortIUpper},
+		{"разложенная й", shortIDecomp, shortIUpper},
+		{"ипогеграммени", alphaYpogegr, alphaProsgegr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UpperCase(tt.input); got != tt.want {
+				t.Errorf("UpperCase(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLetterSetContains(t *testing.T) {
+	tests := []struct {
+		name    string
+		letter  string
+		policy  CasePolicy
+		allowed []string
+		denied  []string
+	}{
+		{"точно", "а", CaseExact, []string{"а"}, []string{"А"}},
+		{"заглавная", "а", CaseUpper, []string{"А"}, []string{"а"}},
+		{"заглавная эсцет", sharpS, CaseUpper, []string{sharpS}, []string{"S", "SS"}},
+		{"заглавная i без точки", dotlessI, CaseUpper, []string{"I"}, []string{dotlessI, dottedI}},
+		{"заглавная разложенная й", shortIDecomp, CaseUpper, []string{shortIUpper}, []string{shortI}},
+		{"любой регистр эсцет", sharpS, CaseInsensitive, []string{sharpS, capitalSharpS}, []string{"s", "S"}},
+		{"любой регистр I с точкой", dottedI, CaseInsensitive, []string{dottedI}, []string{"i", "I", dotlessI}},
+		{"любой регистр i", "i", CaseInsensitive, []string{"i", "I"}, []string{dottedI, dotlessI}},
+		{"любой регистр разложенная й", shortIDecomp, CaseInsensitive, []string{shortI, shortIUpper}, []string{"и", "И"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var set LetterSet
+			set.Add(tt.letter, tt.policy)
+			for _, cluster := range tt.allowed {
+				if !set.Contains(cluster) {
+					t.Errorf("Contains(%q) = false, want true", cluster)
+				}
+			}
+			for _, cluster := range tt.denied {
+				if set.Contains(cluster) {
+					t.Errorf("Contains(%q) = true, want false", cluster)
+				}
+			}
+		})
+	}
+}
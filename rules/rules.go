@@ -0,0 +1,204 @@
+// Package rules описывает атрибутные правила доступа к классам символов:
+// по письменности Unicode, категории, диапазону или регулярному выражению.
+//
+// Правило записывается как набор условий через пробел, все условия должны
+// выполняться одновременно:
+//
+//	script:Cyrillic category:lower   строчные кириллические буквы
+//	category:digit                   все цифры
+//	range:a-z                        диапазон символов (или range:U+0430-U+044F)
+//	!category:upper regex:[a-f]      отрицание условия и регулярное выражение
+//
+// Условие regex поглощает остаток строки, поэтому оно должно быть последним.
+//
+// Правила применяются к кластерам графем: условия script, category и range
+// проверяют базовый (первый) символ кластера, а regex - кластер целиком.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type condition struct {
+	negate bool
+	match  func(cluster string) bool
+}
+
+type Rule struct {
+	expr       string
+	conditions []condition
+}
+
+// Категории, доступные по коротким именам. Кроме них принимаются имена
+// общих категорий Unicode (Lu, Ll, Nd, P, S и т.д.).
+var categories = map[string]func(rune) bool{
+	"letter":  unicode.IsLetter,
+	"upper":   unicode.IsUpper,
+	"lower":   unicode.IsLower,
+	"digit":   unicode.IsDigit,
+	"number":  unicode.IsNumber,
+	"punct":   unicode.IsPunct,
+	"symbol":  unicode.IsSymbol,
+	"space":   unicode.IsSpace,
+	"mark":    unicode.IsMark,
+	"graphic": unicode.IsGraphic,
+}
+
+func Parse(expr string) (Rule, error) {
+	rule := Rule{expr: strings.TrimSpace(expr)}
+	rest := rule.expr
+
+	for rest != "" {
+		term := rest
+		if !strings.HasPrefix(strings.TrimPrefix(term, "!"), "regex:") {
+			if i := strings.IndexFunc(term, unicode.IsSpace); i >= 0 {
+				term = term[:i]
+			}
+		}
+		rest = strings.TrimSpace(rest[len(term):])
+
+		cond, err := parseCondition(term)
+		if err != nil {
+			return Rule{}, err
+		}
+		rule.conditions = append(rule.conditions, cond)
+	}
+
+	if len(rule.conditions) == 0 {
+		return Rule{}, fmt.Errorf("правило не может быть пустым")
+	}
+	return rule, nil
+}
+
+func parseCondition(term string) (condition, error) {
+	var cond condition
+	if strings.HasPrefix(term, "!") {
+		cond.negate = true
+		term = term[1:]
+	}
+
+	kind, value, ok := strings.Cut(term, ":")
+	if !ok || value == "" {
+		return cond, fmt.Errorf("условие '%s' должно иметь вид вид:значение", term)
+	}
+
+	var matchRune func(r rune) bool
+	switch kind {
+	case "script":
+		table, ok := unicode.Scripts[value]
+		if !ok {
+			return cond, fmt.Errorf("неизвестная письменность '%s'", value)
+		}
+		matchRune = func(r rune) bool { return unicode.Is(table, r) }
+	case "category":
+		if fn, ok := categories[value]; ok {
+			matchRune = fn
+			break
+		}
+		table, ok := unicode.Categories[value]
+		if !ok {
+			return cond, fmt.Errorf("неизвестная категория '%s'", value)
+		}
+		matchRune = func(r rune) bool { return unicode.Is(table, r) }
+	case "range":
+		lo, hi, err := parseRange(value)
+		if err != nil {
+			return cond, err
+		}
+		matchRune = func(r rune) bool { return r >= lo && r <= hi }
+	case "regex":
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return cond, fmt.Errorf("некорректное регулярное выражение: %v", err)
+		}
+		cond.match = re.MatchString
+	default:
+		return cond, fmt.Errorf("неизвестный вид условия '%s' (допустимы script, category, range, regex)", kind)
+	}
+
+	if matchRune != nil {
+		cond.match = func(cluster string) bool { return matchRune(Base(cluster)) }
+	}
+	return cond, nil
+}
+
+// parseRange разбирает диапазон вида "а-я" или "U+0430-U+044F".
+func parseRange(value string) (rune, rune, error) {
+	var bounds []rune
+	for _, part := range splitRange(value) {
+		r, err := parseRangeBound(part)
+		if err != nil {
+			return 0, 0, err
+		}
+		bounds = append(bounds, r)
+	}
+	if len(bounds) != 2 || bounds[0] > bounds[1] {
+		return 0, 0, fmt.Errorf("некорректный диапазон '%s'", value)
+	}
+	return bounds[0], bounds[1], nil
+}
+
+func splitRange(value string) []string {
+	runes := []rune(value)
+	// Разделителем считается дефис, не являющийся первым символом:
+	// так можно записать диапазон "--/" от дефиса до косой черты.
+	for i := 1; i < len(runes)-1; i++ {
+		if runes[i] == '-' {
+			return []string{string(runes[:i]), string(runes[i+1:])}
+		}
+	}
+	return []string{value}
+}
+
+func parseRangeBound(s string) (rune, error) {
+	if strings.HasPrefix(s, "U+") || strings.HasPrefix(s, "u+") {
+		code, err := strconv.ParseUint(s[2:], 16, 32)
+		if err != nil || code > unicode.MaxRune {
+			return 0, fmt.Errorf("некорректный код символа '%s'", s)
+		}
+		return rune(code), nil
+	}
+	runes := []rune(s)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("граница диапазона '%s' должна быть одним символом или кодом U+XXXX", s)
+	}
+	return runes[0], nil
+}
+
+// Base возвращает базовый (первый) символ кластера графем.
+func Base(cluster string) rune {
+	r, _ := utf8.DecodeRuneInString(cluster)
+	return r
+}
+
+// Match сообщает, удовлетворяет ли кластер графем всем условиям правила.
+func (rule Rule) Match(cluster string) bool {
+	if len(rule.conditions) == 0 || cluster == "" {
+		return false
+	}
+	for _, cond := range rule.conditions {
+		if cond.match(cluster) == cond.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule Rule) String() string {
+	return rule.expr
+}
+
+// MatchAny сообщает, разрешает ли кластер хотя бы одно из правил.
+func MatchAny(list []Rule, cluster string) bool {
+	for _, rule := range list {
+		if rule.Match(cluster) {
+			return true
+		}
+	}
+	return false
+}
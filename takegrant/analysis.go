@@ -0,0 +1,224 @@
+package takegrant
+
+import "strings"
+
+// takeClosure возвращает вершины, достижимые из id по пути со словом t→*,
+// включая саму вершину.
+func (g *Graph) takeClosure(id string) map[string]bool {
+	reached := map[string]bool{id: true}
+	queue := []string{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for to, labels := range g.edges[current] {
+			if !reached[to] && strings.ContainsRune(labels, Take) {
+				reached[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	return reached
+}
+
+// terminallySpans сообщает, охватывает ли субъект s вершину p терминально:
+// существует путь от s к p со словом t→* (включая пустой).
+func (g *Graph) terminallySpans(s, p string) bool {
+	return g.isSubject(s) && g.takeClosure(s)[p]
+}
+
+// initiallySpans сообщает, охватывает ли субъект s вершину p начально:
+// существует путь от s к p со словом t→* g→ (или s = p).
+func (g *Graph) initiallySpans(s, p string) bool {
+	if !g.isSubject(s) {
+		return false
+	}
+	if s == p {
+		return true
+	}
+	for v := range g.takeClosure(s) {
+		if g.HasEdge(v, p, Grant) {
+			return true
+		}
+	}
+	return false
+}
+
+// islands возвращает номер острова для каждого субъекта. Остров -
+// максимальный подграф из субъектов, связанных tg-путями.
+func (g *Graph) islands() map[string]int {
+	island := make(map[string]int)
+	next := 0
+	for _, id := range g.subjectIDs() {
+		if _, ok := island[id]; ok {
+			continue
+		}
+		island[id] = next
+		queue := []string{id}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, st := range g.tgSteps(current) {
+				if _, ok := island[st.to]; !ok && g.isSubject(st.to) {
+					island[st.to] = next
+					queue = append(queue, st.to)
+				}
+			}
+		}
+		next++
+	}
+	return island
+}
+
+// Состояния автомата, распознающего слова мостов:
+// t→*, t←*, t→* g→ t←* и t→* g← t←*. Слово t→⁺ t←⁺ без g мостом не
+// является, поэтому после t→ переход на t← запрещён.
+const (
+	bridgeStart    = iota // пустое слово
+	bridgeForward         // прочитано t→⁺
+	bridgeBackward        // прочитано t→* g t←* или t←⁺
+)
+
+// bridgeNext возвращает состояние автомата после перехода st или -1,
+// если слово перестаёт быть префиксом слова моста.
+func bridgeNext(state int, st step) int {
+	switch {
+	case state != bridgeBackward && st.label == Take && st.forward:
+		return bridgeForward
+	case state != bridgeBackward && st.label == Grant:
+		return bridgeBackward
+	case state != bridgeForward && st.label == Take && !st.forward:
+		return bridgeBackward
+	}
+	return -1
+}
+
+// bridges возвращает субъекты, связанные с s мостом: tg-путём, все
+// внутренние вершины которого являются объектами.
+func (g *Graph) bridges(s string) []string {
+	type position struct {
+		vertex string
+		state  int
+	}
+
+	var result []string
+	found := make(map[string]bool)
+	visited := map[position]bool{{s, bridgeStart}: true}
+	queue := []position{{s, bridgeStart}}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, st := range g.tgSteps(current.vertex) {
+			state := bridgeNext(current.state, st)
+			if state < 0 {
+				continue
+			}
+
+			if g.isSubject(st.to) {
+				if st.to != s && !found[st.to] {
+					found[st.to] = true
+					result = append(result, st.to)
+				}
+				continue
+			}
+
+			next := position{st.to, state}
+			if !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return result
+}
+
+// components объединяет острова, связанные мостами, и возвращает номер
+// компоненты для каждого субъекта.
+func (g *Graph) components() map[string]int {
+	island := g.islands()
+
+	parent := make(map[int]int)
+	var find func(int) int
+	find = func(i int) int {
+		if p, ok := parent[i]; ok && p != i {
+			root := find(p)
+			parent[i] = root
+			return root
+		}
+		return i
+	}
+
+	for _, s := range g.subjectIDs() {
+		for _, other := range g.bridges(s) {
+			a, b := find(island[s]), find(island[other])
+			if a != b {
+				parent[a] = b
+			}
+		}
+	}
+
+	component := make(map[string]int, len(island))
+	for id, i := range island {
+		component[id] = find(i)
+	}
+	return component
+}
+
+// CanShare проверяет предикат can_share(α, x, y): может ли вершина x
+// получить право α на вершину y.
+func (g *Graph) CanShare(right rune, x, y string) bool {
+	if g.HasEdge(x, y, right) {
+		return true
+	}
+
+	component := g.components()
+	subjects := g.subjectIDs()
+
+	var xPrimes []string
+	for _, s := range subjects {
+		if g.initiallySpans(s, x) {
+			xPrimes = append(xPrimes, s)
+		}
+	}
+	if len(xPrimes) == 0 {
+		return false
+	}
+
+	for _, s := range g.vertexIDs() {
+		if !g.HasEdge(s, y, right) {
+			continue
+		}
+		for _, sPrime := range subjects {
+			if !g.terminallySpans(sPrime, s) {
+				continue
+			}
+			for _, xPrime := range xPrimes {
+				if component[xPrime] == component[sPrime] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// CanSteal проверяет предикат can_steal(α, x, y): может ли x получить
+// право α на y без содействия владельцев этого права.
+func (g *Graph) CanSteal(right rune, x, y string) bool {
+	if g.HasEdge(x, y, right) {
+		return false
+	}
+
+	for _, xPrime := range g.subjectIDs() {
+		if !g.initiallySpans(xPrime, x) {
+			continue
+		}
+		for _, s := range g.vertexIDs() {
+			if g.HasEdge(s, y, right) && g.CanShare(Take, xPrime, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package takegrant
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// edge - ребро тестового графа.
+type edge struct {
+	from, to string
+	label    rune
+}
+
+// build строит граф: subjects - субъекты, остальные вершины рёбер -
+// объекты.
+func build(subjects []string, edges []edge) *Graph {
+	g := New()
+	for _, e := range edges {
+		g.AddObject(e.from, e.from)
+		g.AddObject(e.to, e.to)
+	}
+	for _, s := range subjects {
+		g.AddSubject(s, s)
+	}
+	for _, e := range edges {
+		g.AddEdge(e.from, e.to, e.label)
+	}
+	return g
+}
+
+func TestBridges(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges []edge
+		want  []string
+	}{
+		{"t→*", []edge{{"a", "o1", Take}, {"o1", "o2", Take}, {"o2", "b", Take}}, []string{"b"}},
+		{"t←*", []edge{{"b", "o", Take}, {"o", "a", Take}}, []string{"b"}},
+		{"t→* g→ t←*", []edge{{"a", "o", Take}, {"o", "p", Grant}, {"b", "p", Take}}, []string{"b"}},
+		{"t→* g← t←*", []edge{{"a", "o", Take}, {"p", "o", Grant}, {"b", "p", Take}}, []string{"b"}},
+		{"g→", []edge{{"a", "o", Grant}, {"b", "o", Take}}, []string{"b"}},
+		{"t→ t← не мост", []edge{{"a", "o", Take}, {"b", "o", Take}}, nil},
+		{"t→ t→ t← не мост", []edge{{"a", "o1", Take}, {"o1", "o2", Take}, {"b", "o2", Take}}, nil},
+		{"g→ g→ не мост", []edge{{"a", "o", Grant}, {"o", "b", Grant}}, nil},
+		{"t← t→ не мост", []edge{{"o", "a", Take}, {"o", "b", Take}}, nil},
+		{"t← g→ не мост", []edge{{"o", "a", Take}, {"o", "b", Grant}}, nil},
+		{"путь через субъекта не мост", []edge{{"a", "c", Take}, {"c", "o", Take}, {"b", "o", Take}}, []string{"c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := build([]string{"a", "b", "c"}, tt.edges)
+			got := g.bridges("a")
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("bridges(a) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIslands(t *testing.T) {
+	g := build([]string{"a", "b", "c", "d", "e"}, []edge{
+		{"a", "b", Take},
+		{"c", "b", Grant},
+		{"d", "o", Take},
+		{"o", "e", Take},
+	})
+	island := g.islands()
+	if island["a"] != island["b"] || island["b"] != island["c"] {
+		t.Errorf("a, b, c должны быть на одном острове: %v", island)
+	}
+	if island["d"] == island["a"] || island["e"] == island["a"] {
+		t.Errorf("d и e не связаны с a рёбрами между субъектами: %v", island)
+	}
+	// Путь через объект - мост, а не часть острова
+	if island["d"] == island["e"] {
+		t.Errorf("d и e связаны через объект и не образуют остров: %v", island)
+	}
+	component := g.components()
+	if component["d"] != component["e"] {
+		t.Errorf("мост d-e должен объединять острова: %v", component)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	g := build([]string{"a"}, []edge{
+		{"a", "o1", Take},
+		{"o1", "o2", Take},
+		{"o2", "x", Grant},
+		{"a", "y", Grant},
+		{"x", "z", Take},
+	})
+	tests := []struct {
+		p                     string
+		terminally, initially bool
+	}{
+		{"a", true, true},
+		{"o1", true, false},
+		{"o2", true, false},
+		{"x", false, true},
+		{"y", false, true},
+		{"z", false, false},
+	}
+	for _, tt := range tests {
+		if got := g.terminallySpans("a", tt.p); got != tt.terminally {
+			t.Errorf("terminallySpans(a, %s) = %v, want %v", tt.p, got, tt.terminally)
+		}
+		if got := g.initiallySpans("a", tt.p); got != tt.initially {
+			t.Errorf("initiallySpans(a, %s) = %v, want %v", tt.p, got, tt.initially)
+		}
+	}
+	if g.terminallySpans("o1", "o2") {
+		t.Errorf("объект не может охватывать вершины")
+	}
+}
+
+func TestCanShare(t *testing.T) {
+	tests := []struct {
+		name     string
+		subjects []string
+		edges    []edge
+		x, y     string
+		want     bool
+	}{
+		{"право уже есть", []string{"a"}, []edge{{"a", "y", Read}}, "a", "y", true},
+		{"взятие", []string{"a", "b"}, []edge{{"a", "b", Take}, {"b", "y", Read}}, "a", "y", true},
+		{"передача", []string{"a", "b"}, []edge{{"b", "a", Grant}, {"b", "y", Read}}, "a", "y", true},
+		{"мост t→*", []string{"a", "b"}, []edge{{"a", "o", Take}, {"o", "b", Take}, {"b", "y", Read}}, "a", "y", true},
+		{"мост t→ g→ t←", []string{"a", "b"},
+			[]edge{{"a", "o", Take}, {"o", "p", Grant}, {"b", "p", Take}, {"b", "y", Read}}, "a", "y", true},
+		{"владелец терминально охвачен", []string{"a"}, []edge{{"a", "o", Take}, {"o", "y", Read}}, "a", "y", true},
+		{"объект начально охвачен", []string{"a", "b"},
+			[]edge{{"a", "x", Grant}, {"a", "b", Take}, {"b", "y", Read}}, "x", "y", true},
+		{"нет связи", []string{"a", "b"}, []edge{{"b", "y", Read}}, "a", "y", false},
+		{"t→ t← не мост", []string{"a", "b"}, []edge{{"a", "o", Take}, {"b", "o", Take}, {"b", "y", Read}}, "a", "y", false},
+		{"чужое право на другой объект", []string{"a", "b"}, []edge{{"a", "b", Take}, {"b", "z", Read}}, "a", "y", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := build(tt.subjects, tt.edges)
+			g.AddObject("y", "y")
+			if got := g.CanShare(Read, tt.x, tt.y); got != tt.want {
+				t.Errorf("CanShare(r, %s, %s) = %v, want %v", tt.x, tt.y, got, tt.want)
+			}
+		})
+	}
+}
+
+// Граф из замечания: a -t→ o ←t- b и b -r→ y. Путь a-b имеет слово
+// t→ t←, которое мостом не является, поэтому a не может получить r на y.
+func TestCanShareTakeTakeBack(t *testing.T) {
+	g := New()
+	g.AddSubject(UserID("a"), "a")
+	g.AddSubject(UserID("b"), "b")
+	g.AddObject(LetterID("o"), "o")
+	g.AddObject(LetterID("y"), "y")
+	g.AddEdge(UserID("a"), LetterID("o"), Take)
+	g.AddEdge(UserID("b"), LetterID("o"), Take)
+	g.AddEdge(UserID("b"), LetterID("y"), Read)
+
+	if g.CanShare(Read, UserID("a"), LetterID("y")) {
+		t.Errorf("CanShare(r, u:a, l:y) = true, want false")
+	}
+	if !g.CanShare(Read, UserID("b"), LetterID("y")) {
+		t.Errorf("CanShare(r, u:b, l:y) = false, want true")
+	}
+}
+
+func TestCanSteal(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges []edge
+		want  bool
+	}{
+		{"взятие без участия владельца", []edge{{"a", "b", Take}, {"b", "y", Read}}, true},
+		{"передача требует участия владельца", []edge{{"b", "a", Grant}, {"b", "y", Read}}, false},
+		{"право уже есть", []edge{{"a", "y", Read}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := build([]string{"a", "b"}, tt.edges)
+			g.AddObject("y", "y")
+			if got := g.CanSteal(Read, "a", "y"); got != tt.want {
+				t.Errorf("CanSteal(r, a, y) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
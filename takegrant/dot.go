@@ -0,0 +1,48 @@
+package takegrant
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteDOT выводит граф в формате Graphviz DOT. Субъекты изображаются
+// закрашенными кругами, объекты - прямоугольниками.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintln(out, "digraph takegrant {")
+	fmt.Fprintln(out, "\trankdir=LR;")
+
+	for _, id := range g.vertexIDs() {
+		v := g.vertices[id]
+		if v.Subject {
+			fmt.Fprintf(out, "\t%s [label=%s, shape=circle, style=filled, fillcolor=lightblue];\n", dotQuote(id), dotQuote(v.Name))
+		} else {
+			fmt.Fprintf(out, "\t%s [label=%s, shape=box];\n", dotQuote(id), dotQuote(v.Name))
+		}
+	}
+
+	for _, from := range g.vertexIDs() {
+		targets := make([]string, 0, len(g.edges[from]))
+		for to := range g.edges[from] {
+			targets = append(targets, to)
+		}
+		sort.Strings(targets)
+		for _, to := range targets {
+			fmt.Fprintf(out, "\t%s -> %s [label=%s];\n", dotQuote(from), dotQuote(to), dotQuote(g.edges[from][to]))
+		}
+	}
+
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
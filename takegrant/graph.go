@@ -0,0 +1,187 @@
+// Package takegrant представляет матрицу доступа как граф модели
+// Take-Grant и вычисляет предикаты can_share и can_steal по теореме
+// Липтона-Снайдера.
+package takegrant
+
+import (
+	"database/sql"
+	"laba3/database"
+	"sort"
+	"strings"
+)
+
+// Метки рёбер графа.
+const (
+	Take  = 't'
+	Grant = 'g'
+	Read  = 'r'
+	Write = 'w'
+)
+
+type Vertex struct {
+	ID      string
+	Name    string
+	Subject bool
+}
+
+// Graph - ориентированный граф с множествами меток на рёбрах.
+type Graph struct {
+	vertices map[string]Vertex
+	edges    map[string]map[string]string
+}
+
+func New() *Graph {
+	return &Graph{
+		vertices: make(map[string]Vertex),
+		edges:    make(map[string]map[string]string),
+	}
+}
+
+// UserID и LetterID возвращают идентификаторы вершин для пользователя и
+// буквы: имена пользователей и символы могут совпадать, поэтому вершины
+// различаются префиксом.
+func UserID(name string) string {
+	return "u:" + name
+}
+
+func LetterID(letter string) string {
+	return "l:" + letter
+}
+
+// ParseVertex переводит запись вида "u:имя" или "l:символ" в идентификатор
+// вершины. Запись без префикса считается именем пользователя.
+func ParseVertex(ref string) string {
+	if strings.HasPrefix(ref, "u:") || strings.HasPrefix(ref, "l:") {
+		return ref
+	}
+	return UserID(ref)
+}
+
+// FromDatabase строит граф: пользователи - субъекты, буквы - объекты,
+// user_letters дают рёбра r, а таблица tg_edges - рёбра t и g.
+func FromDatabase(db *sql.DB) (*Graph, error) {
+	users, err := database.GetAllUsers(db)
+	if err != nil {
+		return nil, err
+	}
+	letters, err := database.GetAllLetters(db)
+	if err != nil {
+		return nil, err
+	}
+
+	g := New()
+	for _, user := range users {
+		g.AddSubject(UserID(user), user)
+	}
+	for _, letter := range letters {
+		g.AddObject(LetterID(letter), letter)
+	}
+
+	for _, user := range users {
+		userID, err := database.FindUser(db, user)
+		if err != nil {
+			return nil, err
+		}
+		permissions, err := database.GetPermissions(db, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, letter := range permissions {
+			g.AddEdge(UserID(user), LetterID(letter), Read)
+		}
+	}
+
+	edges, err := database.GetTakeGrantEdges(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, edge := range edges {
+		target := UserID(edge.To)
+		if edge.ToLetter {
+			target = LetterID(edge.To)
+		}
+		g.AddEdge(UserID(edge.From), target, rune(edge.Label[0]))
+	}
+
+	return g, nil
+}
+
+func (g *Graph) AddSubject(id, name string) {
+	g.vertices[id] = Vertex{ID: id, Name: name, Subject: true}
+}
+
+func (g *Graph) AddObject(id, name string) {
+	g.vertices[id] = Vertex{ID: id, Name: name}
+}
+
+// AddEdge добавляет метку на ребро from -> to.
+func (g *Graph) AddEdge(from, to string, label rune) {
+	if g.edges[from] == nil {
+		g.edges[from] = make(map[string]string)
+	}
+	if !strings.ContainsRune(g.edges[from][to], label) {
+		labels := []rune(g.edges[from][to] + string(label))
+		sort.Slice(labels, func(i, j int) bool { return labels[i] < labels[j] })
+		g.edges[from][to] = string(labels)
+	}
+}
+
+func (g *Graph) HasEdge(from, to string, label rune) bool {
+	return strings.ContainsRune(g.edges[from][to], label)
+}
+
+func (g *Graph) Vertex(id string) (Vertex, bool) {
+	v, ok := g.vertices[id]
+	return v, ok
+}
+
+func (g *Graph) isSubject(id string) bool {
+	return g.vertices[id].Subject
+}
+
+func (g *Graph) vertexIDs() []string {
+	ids := make([]string, 0, len(g.vertices))
+	for id := range g.vertices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (g *Graph) subjectIDs() []string {
+	var ids []string
+	for _, id := range g.vertexIDs() {
+		if g.isSubject(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// step - переход по ребру tg-пути: соседняя вершина, метка ребра и
+// направление обхода (forward - по направлению ребра).
+type step struct {
+	to      string
+	label   rune
+	forward bool
+}
+
+// tgSteps возвращает все переходы по рёбрам t и g из вершины в обе стороны.
+func (g *Graph) tgSteps(id string) []step {
+	var steps []step
+	for to, labels := range g.edges[id] {
+		for _, label := range labels {
+			if label == Take || label == Grant {
+				steps = append(steps, step{to: to, label: label, forward: true})
+			}
+		}
+	}
+	for from, targets := range g.edges {
+		for _, label := range targets[id] {
+			if label == Take || label == Grant {
+				steps = append(steps, step{to: from, label: label, forward: false})
+			}
+		}
+	}
+	return steps
+}
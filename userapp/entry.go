@@ -0,0 +1,182 @@
+package main
+
+import (
+	"laba3/filter"
+	"laba3/grapheme"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// pendingDelay - сколько поле ждёт продолжения незавершённого кластера.
+// Составные символы (флаги, последовательности с ZWJ) поступают от
+// системы ввода подряд, поэтому ожидание короткое.
+const pendingDelay = 300 * time.Millisecond
+
+// restrictedEntry - многострочное поле ввода, которое в режиме блокировки
+// не принимает запрещённые пользователю символы: ни с клавиатуры, ни при
+// вставке из буфера обмена.
+//
+// Проверяется кластер графем, а не отдельная руна: руны, которые сами по
+// себе запрещены, придерживаются в pending, пока вместе с предыдущим
+// текстом не образуют разрешённый кластер. Если кластер завершился
+// (следующая руна начала новый) или продолжения не было pendingDelay,
+// придержанные руны отбрасываются.
+type restrictedEntry struct {
+	widget.Entry
+
+	blocking  bool
+	rights    func() *filter.Filter
+	onBlocked func(cluster string)
+
+	pending      []rune
+	pendingTimer *time.Timer
+	pendingGen   int
+}
+
+// newRestrictedEntry создаёт поле ввода; rights возвращает действующий
+// фильтр прав и вызывается при каждой проверке.
+func newRestrictedEntry(rights func() *filter.Filter) *restrictedEntry {
+	entry := &restrictedEntry{rights: rights}
+	entry.MultiLine = true
+	entry.Wrapping = fyne.TextWrapWord
+	entry.ExtendBaseWidget(entry)
+	return entry
+}
+
+func (e *restrictedEntry) refuse(cluster string) bool {
+	if !e.blocking || e.rights().Allowed(cluster) {
+		return false
+	}
+	if e.onBlocked != nil {
+		e.onBlocked(cluster)
+	}
+	return true
+}
+
+// textBeforeCursor возвращает текст поля до курсора. При выделении
+// вводимый текст заменяет его, поэтому предыдущий текст не учитывается.
+func (e *restrictedEntry) textBeforeCursor() string {
+	if e.SelectedText() != "" {
+		return ""
+	}
+	text := []rune(e.Text)
+	return string(text[:min(e.CursorTextOffset(), len(text))])
+}
+
+// lastCluster возвращает последний кластер текста в NFC.
+func lastCluster(text string) string {
+	clusters := grapheme.Split(text)
+	if len(clusters) == 0 {
+		return ""
+	}
+	return clusters[len(clusters)-1]
+}
+
+func (e *restrictedEntry) TypedRune(r rune) {
+	if !e.blocking {
+		e.Entry.TypedRune(r)
+		return
+	}
+	e.stopPending()
+
+	before := e.textBeforeCursor()
+	// Руна начинает новый кластер: придержанный кластер завершён и так
+	// и не стал разрешённым
+	if len(e.pending) > 0 && grapheme.Count(before+string(e.pending)+string(r)) > grapheme.Count(before+string(e.pending)) {
+		e.dropPending()
+	}
+	e.pending = append(e.pending, r)
+
+	if e.rights().Allowed(lastCluster(before + string(e.pending))) {
+		for _, pending := range e.pending {
+			e.Entry.TypedRune(pending)
+		}
+		e.pending = nil
+		return
+	}
+
+	e.pendingGen++
+	gen := e.pendingGen
+	e.pendingTimer = time.AfterFunc(pendingDelay, func() {
+		fyne.Do(func() {
+			if e.pendingGen == gen {
+				e.dropPending()
+			}
+		})
+	})
+}
+
+func (e *restrictedEntry) stopPending() {
+	if e.pendingTimer != nil {
+		e.pendingTimer.Stop()
+		e.pendingTimer = nil
+	}
+}
+
+// dropPending отбрасывает придержанные руны и сообщает о запрещённом
+// кластере, который они образуют вместе с предыдущим текстом.
+func (e *restrictedEntry) dropPending() {
+	e.stopPending()
+	if len(e.pending) == 0 {
+		return
+	}
+	cluster := lastCluster(e.textBeforeCursor() + string(e.pending))
+	e.pending = nil
+	if e.onBlocked != nil {
+		e.onBlocked(cluster)
+	}
+}
+
+func (e *restrictedEntry) TypedKey(key *fyne.KeyEvent) {
+	// Backspace стирает придержанный кластер, которого ещё нет в поле
+	if key.Name == fyne.KeyBackspace && len(e.pending) > 0 {
+		e.stopPending()
+		e.pending = nil
+		return
+	}
+	e.dropPending()
+	if (key.Name == fyne.KeyReturn || key.Name == fyne.KeyEnter) && e.refuse("\n") {
+		return
+	}
+	e.Entry.TypedKey(key)
+}
+
+func (e *restrictedEntry) FocusLost() {
+	e.dropPending()
+	e.Entry.FocusLost()
+}
+
+// filteredClipboard подставляет при вставке отфильтрованный текст, не
+// меняя системный буфер обмена.
+type filteredClipboard struct {
+	content string
+}
+
+func (c filteredClipboard) Content() string   { return c.content }
+func (c filteredClipboard) SetContent(string) {}
+
+// TypedShortcut удаляет запрещённые символы из вставляемого текста.
+// Системный буфер обмена не изменяется.
+func (e *restrictedEntry) TypedShortcut(shortcut fyne.Shortcut) {
+	e.dropPending()
+	paste, ok := shortcut.(*fyne.ShortcutPaste)
+	if !ok || !e.blocking {
+		e.Entry.TypedShortcut(shortcut)
+		return
+	}
+
+	text := paste.Clipboard.Content()
+	rights := e.rights()
+	filtered, _, _ := rights.Apply(text, filter.ModeDelete, "")
+	if filtered != text && e.onBlocked != nil {
+		for _, segment := range rights.Segments(text) {
+			if segment.Forbidden {
+				e.onBlocked(grapheme.Split(segment.Text)[0])
+				break
+			}
+		}
+	}
+	e.Entry.TypedShortcut(&fyne.ShortcutPaste{Clipboard: filteredClipboard{content: filtered}})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"laba3/filter"
+	"laba3/grapheme"
+	"reflect"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+)
+
+// Символы записаны escape-последовательностями, чтобы составные
+// и разложенные формы не путались при редактировании.
+const (
+	shortI       = "\u0439"                                     // й
+	shortIDecomp = "\u0438\u0306"                               // и + комбинируемое бреве
+	flagRU       = "\U0001F1F7\U0001F1FA"                       // два региональных индикатора
+	family       = "\U0001F468\u200d\U0001F469\u200d\U0001F467" // последовательность с ZWJ
+)
+
+// newTestEntry создаёт поле в режиме блокировки с правами на letters
+// и возвращает его вместе со списком заблокированных кластеров.
+func newTestEntry(t *testing.T, letters ...string) (*restrictedEntry, *[]string) {
+	t.Helper()
+	test.NewTempApp(t)
+	rights := testRights(letters...)
+	entry := newRestrictedEntry(func() *filter.Filter { return rights.filter })
+	entry.blocking = true
+	var blocked []string
+	entry.onBlocked = func(cluster string) {
+		blocked = append(blocked, cluster)
+	}
+	return entry, &blocked
+}
+
+func typeRunes(entry *restrictedEntry, text string) {
+	for _, r := range text {
+		entry.TypedRune(r)
+	}
+}
+
+func TestRestrictedEntryTyping(t *testing.T) {
+	tests := []struct {
+		name        string
+		letters     []string
+		typed       string
+		want        string
+		wantBlocked []string
+	}{
+		{"одиночные руны", []string{"а", "б"}, "абв", "аб", nil},
+		{"разложенная й", []string{shortI}, shortIDecomp, shortI, nil},
+		{"флаг", []string{flagRU}, flagRU + flagRU, flagRU + flagRU, nil},
+		{"последовательность с ZWJ", []string{family}, family, family, nil},
+		{"запрещённый перед разрешённым", []string{"а"}, "xа", "а", []string{"x"}},
+		{"разрешена основа, но не й", []string{"и", "а"}, shortIDecomp + "а", "иа", []string{shortI}},
+		{"незавершённый флаг", []string{flagRU, "а"}, "\U0001F1F7а", "а", []string{"\U0001F1F7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, blocked := newTestEntry(t, tt.letters...)
+			typeRunes(entry, tt.typed)
+			if got := grapheme.Normalize(entry.Text); got != tt.want {
+				t.Errorf("Text = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(*blocked, tt.wantBlocked) {
+				t.Errorf("blocked = %q, want %q", *blocked, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+// Придержанный кластер, который так и не стал разрешённым, отбрасывается
+// при потере фокуса, а Backspace стирает его без сообщения.
+func TestRestrictedEntryPending(t *testing.T) {
+	entry, blocked := newTestEntry(t, "а")
+	typeRunes(entry, "аx")
+	entry.FocusLost()
+	if entry.Text != "а" || !reflect.DeepEqual(*blocked, []string{"x"}) {
+		t.Errorf("after FocusLost: Text = %q, blocked = %q", entry.Text, *blocked)
+	}
+
+	*blocked = nil
+	typeRunes(entry, "x")
+	entry.TypedKey(&fyne.KeyEvent{Name: fyne.KeyBackspace})
+	typeRunes(entry, "а")
+	if entry.Text != "аа" || len(*blocked) != 0 {
+		t.Errorf("after Backspace: Text = %q, blocked = %q", entry.Text, *blocked)
+	}
+}
+
+// Вставка фильтрует текст локально и не меняет буфер обмена.
+func TestRestrictedEntryPaste(t *testing.T) {
+	entry, blocked := newTestEntry(t, "а", "б")
+	clipboard := test.NewClipboard()
+	clipboard.SetContent("аxб")
+
+	entry.TypedShortcut(&fyne.ShortcutPaste{Clipboard: clipboard})
+	if entry.Text != "аб" {
+		t.Errorf("Text = %q, want %q", entry.Text, "аб")
+	}
+	if got := clipboard.Content(); got != "аxб" {
+		t.Errorf("clipboard = %q, want it unchanged", got)
+	}
+	if !reflect.DeepEqual(*blocked, []string{"x"}) {
+		t.Errorf("blocked = %q, want [x]", *blocked)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"laba3/charset"
+	"laba3/filter"
+	"os"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+)
+
+// previewLimit - сколько символов результата показывается в окне. Полный
+// результат доступен через сохранение в файл и копирование.
+const previewLimit = 100000
+
+var textFileFilter = storage.NewExtensionFileFilter([]string{".txt", ".md", ".csv", ".log"})
+
+// progressReader сообщает долю прочитанных байт файла.
+type progressReader struct {
+	r          io.Reader
+	size       int64
+	read       int64
+	reported   int
+	onProgress func(value float64)
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.read += int64(n)
+	// Прогресс сообщается не чаще, чем раз на процент размера файла.
+	if p.size > 0 {
+		if percent := int(p.read * 100 / p.size); percent > p.reported {
+			p.reported = percent
+			p.onProgress(float64(p.read) / float64(p.size))
+		}
+	}
+	return n, err
+}
+
+func (tp *TextProcessor) showOpenFileDialog() {
+	tp.markActivity()
+	open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, tp.mainWindow)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		tp.processFile(reader.URI(), reader)
+	}, tp.mainWindow)
+	open.SetFilter(textFileFilter)
+	open.Show()
+}
+
+func (tp *TextProcessor) showSaveFileDialog() {
+	tp.markActivity()
+	if tp.result == "" {
+		dialog.ShowInformation("Информация", "Нет результата для сохранения", tp.mainWindow)
+		return
+	}
+
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, tp.mainWindow)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if _, err := io.WriteString(writer, tp.result); err != nil {
+			dialog.ShowError(fmt.Errorf("ошибка сохранения файла: %v", err), tp.mainWindow)
+			return
+		}
+		dialog.ShowInformation("Готово", fmt.Sprintf("Результат сохранён в %s (UTF-8)", writer.URI().Name()), tp.mainWindow)
+	}, tp.mainWindow)
+	save.SetFileName("filtered.txt")
+	save.Show()
+}
+
+func (tp *TextProcessor) onFilesDropped(_ fyne.Position, uris []fyne.URI) {
+	if tp.currentUser == 0 || len(uris) == 0 {
+		return
+	}
+	tp.markActivity()
+
+	reader, err := storage.Reader(uris[0])
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("не удалось открыть файл %s: %v", uris[0].Name(), err), tp.mainWindow)
+		return
+	}
+	tp.processFile(uris[0], reader)
+}
+
+// processFile фильтрует файл в фоновой горутине: определяет кодировку,
+// обрабатывает текст потоком и показывает прогресс чтения. Если сеанс
+// завершился до окончания обработки, результат отбрасывается.
+func (tp *TextProcessor) processFile(uri fyne.URI, reader io.ReadCloser) {
+	if tp.busy {
+		reader.Close()
+		dialog.ShowInformation("Информация", "Дождитесь окончания обработки предыдущего файла", tp.mainWindow)
+		return
+	}
+
+	var size int64
+	if uri.Scheme() == "file" {
+		if info, err := os.Stat(uri.Path()); err == nil {
+			size = info.Size()
+		}
+	}
+
+	tp.busy = true
+	tp.progress.SetValue(0)
+	tp.progress.Show()
+	tp.fileInfo.SetText(fmt.Sprintf("Обработка файла %s...", uri.Name()))
+
+	userID, sessionID := tp.currentUser, tp.sessionID
+	current := func() bool {
+		return tp.currentUser == userID && tp.sessionID == sessionID
+	}
+
+	textFilter, mode, placeholder := tp.rights().filter, tp.mode, tp.placeholder
+	go func() {
+		defer reader.Close()
+
+		counter := &progressReader{r: reader, size: size, onProgress: func(value float64) {
+			fyne.Do(func() {
+				if current() {
+					tp.progress.SetValue(value)
+				}
+			})
+		}}
+
+		var result strings.Builder
+		var stats filter.Stats
+		decoded, encoding, err := charset.NewReader(counter)
+		if err == nil {
+			stats, err = textFilter.Copy(&result, decoded, mode, placeholder)
+		}
+
+		fyne.Do(func() {
+			// Сеанс завершён, пока файл обрабатывался
+			if !current() {
+				return
+			}
+
+			tp.busy = false
+			tp.progress.Hide()
+			if err != nil {
+				tp.fileInfo.SetText("")
+				dialog.ShowError(fmt.Errorf("ошибка обработки файла %s: %v", uri.Name(), err), tp.mainWindow)
+				return
+			}
+			tp.fileInfo.SetText(fmt.Sprintf("Файл: %s (%s), сохранено символов: %d, запрещённых: %d",
+				uri.Name(), encoding, stats.Kept, stats.Dropped))
+			tp.showResult(result.String())
+		})
+	}()
+}
@@ -4,8 +4,11 @@ import (
 	"database/sql"
 	"fmt"
 	"laba3/database"
+	"laba3/filter"
+	"laba3/grapheme"
 	"log"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -16,13 +19,55 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
+// liveDelay - пауза в наборе текста, после которой выполняется фильтрация
+// при вводе.
+const liveDelay = 300 * time.Millisecond
+
+// noticeDuration - сколько показывается уведомление об изменении прав.
+const noticeDuration = 10 * time.Second
+
 type TextProcessor struct {
-	db           *sql.DB
-	mainWindow   fyne.Window
-	currentUser  int
-	username     string
-	accessRights map[rune]bool
-	autoRefresh  *time.Timer
+	db          *sql.DB
+	mainWindow  fyne.Window
+	currentUser int
+	username    string
+	snapshot    atomic.Pointer[rightsSnapshot]
+	mode        filter.Mode
+	placeholder string
+	autoRefresh *time.Timer
+
+	// Сеанс в базе данных и учёт бездействия
+	userRevision int
+	sessionID    int
+	lastActivity time.Time
+
+	// Фильтрация при вводе и блокировка запрещённых символов
+	live        bool
+	blockTyping bool
+	liveTimer   *time.Timer
+	liveFilter  func()
+
+	// Виджеты рабочей области, обновляемые при изменении прав
+	userProfile   *widget.Label
+	rightsInfo    *widget.Label
+	rulesInfo     *widget.Label
+	refreshStatus *widget.Label
+	notice        *widget.Label
+	noticeTimer   *time.Timer
+
+	// Запросы доступа: символы, ввод которых был заблокирован, и
+	// последние известные состояния запросов
+	blocked         []string
+	requestStatuses map[int]string
+	requestsInfo    *widget.Label
+
+	// Результат последней фильтрации и виджеты, в которых он показывается
+	result     string
+	busy       bool
+	output     *widget.Label
+	outputRich *widget.RichText
+	progress   *widget.ProgressBar
+	fileInfo   *widget.Label
 }
 
 func CreateTextProcessor(db *sql.DB) *TextProcessor {
@@ -33,11 +78,14 @@ func CreateTextProcessor(db *sql.DB) *TextProcessor {
 	window.Resize(fyne.NewSize(800, 600))
 
 	textProcessor := &TextProcessor{
-		db:           db,
-		mainWindow:   window,
-		accessRights: make(map[rune]bool),
+		db:          db,
+		mainWindow:  window,
+		mode:        filter.ModeDelete,
+		placeholder: filter.DefaultPlaceholder,
 	}
 
+	textProcessor.snapshot.Store(noRights())
+	window.SetOnDropped(textProcessor.onFilesDropped)
 	textProcessor.displayAuthScreen()
 
 	return textProcessor
@@ -50,12 +98,18 @@ func (tp *TextProcessor) Launch() {
 func (tp *TextProcessor) displayAuthScreen() {
 	usernameInput := widget.NewEntry()
 	usernameInput.SetPlaceHolder("Ваше имя пользователя")
+	passwordInput := widget.NewPasswordEntry()
+	passwordInput.SetPlaceHolder("Пароль (если задан администратором)")
+
 	usernameInput.OnSubmitted = func(text string) {
-		tp.authenticateUser(text)
+		tp.authenticateUser(text, passwordInput.Text)
+	}
+	passwordInput.OnSubmitted = func(text string) {
+		tp.authenticateUser(usernameInput.Text, text)
 	}
 
 	authButton := widget.NewButton("Подтвердить вход", func() {
-		tp.authenticateUser(usernameInput.Text)
+		tp.authenticateUser(usernameInput.Text, passwordInput.Text)
 	})
 	authButton.Importance = widget.HighImportance
 
@@ -70,6 +124,7 @@ func (tp *TextProcessor) displayAuthScreen() {
 		welcomeText,
 		instructionText,
 		usernameInput,
+		passwordInput,
 		authButton,
 	)
 
@@ -77,7 +132,7 @@ func (tp *TextProcessor) displayAuthScreen() {
 	tp.mainWindow.SetContent(centeredContent)
 }
 
-func (tp *TextProcessor) authenticateUser(name string) {
+func (tp *TextProcessor) authenticateUser(name string, password string) {
 	if strings.TrimSpace(name) == "" {
 		dialog.ShowError(fmt.Errorf("необходимо указать имя пользователя"), tp.mainWindow)
 		return
@@ -93,148 +148,365 @@ func (tp *TextProcessor) authenticateUser(name string) {
 		return
 	}
 
+	if err := database.CheckPassword(tp.db, userID, password); err != nil {
+		dialog.ShowError(err, tp.mainWindow)
+		return
+	}
+
+	name, revision, err := database.GetUserRevision(tp.db, userID)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("ошибка подключения к базе: %v", err), tp.mainWindow)
+		return
+	}
+
+	sessionID, err := database.StartSession(tp.db, userID)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("не удалось начать сеанс: %v", err), tp.mainWindow)
+		return
+	}
+
 	tp.currentUser = userID
 	tp.username = name
+	tp.userRevision = revision
+	tp.sessionID = sessionID
+	tp.lastActivity = time.Now()
 
 	tp.loadAccessRights()
 
-	log.Printf("Сессия пользователя %s активна. Доступные символы: %v", name, tp.getAccessList())
+	log.Printf("Сессия пользователя %s активна. Доступные символы: %v", name, tp.rights().accessList())
 
 	tp.displayWorkArea()
 
 	tp.initAutoRefresh()
 }
 
-func (tp *TextProcessor) loadAccessRights() {
-	rights, err := database.GetPermissions(tp.db, tp.currentUser)
-	if err != nil {
-		log.Printf("Ошибка загрузки прав доступа: %v", err)
-		return
+// endSession завершает сеанс и возвращает приложение к экрану входа.
+// Непустая причина показывается пользователю.
+func (tp *TextProcessor) endSession(reason string) {
+	if tp.autoRefresh != nil {
+		tp.autoRefresh.Stop()
 	}
-
-	tp.accessRights = make(map[rune]bool)
-	for _, right := range rights {
-		if len(right) > 0 {
-			tp.accessRights[[]rune(right)[0]] = true
+	if tp.sessionID != 0 {
+		if err := database.EndSession(tp.db, tp.sessionID); err != nil {
+			log.Printf("Ошибка завершения сеанса: %v", err)
 		}
 	}
+
+	tp.currentUser = 0
+	tp.username = ""
+	tp.sessionID = 0
+	tp.result = ""
+	tp.busy = false
+	tp.blocked = nil
+	tp.requestStatuses = nil
+	tp.setRights(noRights())
+	tp.rightsInfo = nil
+	tp.requestsInfo = nil
+	tp.userProfile = nil
+	tp.displayAuthScreen()
+
+	if reason != "" {
+		dialog.ShowInformation("Сеанс завершён", reason, tp.mainWindow)
+	}
 }
 
-func (tp *TextProcessor) getAccessList() []string {
-	allowed := make([]string, 0, len(tp.accessRights))
-	for char := range tp.accessRights {
-		allowed = append(allowed, string(char))
+// renameUser обновляет имя пользователя, изменённое администратором.
+func (tp *TextProcessor) renameUser(name string, revision int) {
+	log.Printf("Пользователь %s переименован в %s", tp.username, name)
+	tp.username = name
+	tp.userRevision = revision
+	if tp.userProfile == nil {
+		return
 	}
-	return allowed
+	tp.userProfile.SetText(fmt.Sprintf("Текущий пользователь: %s", name))
+	tp.showNotice(fmt.Sprintf("Администратор изменил ваше имя пользователя на %s", name))
 }
 
-func (tp *TextProcessor) initAutoRefresh() {
-	if tp.autoRefresh != nil {
-		tp.autoRefresh.Stop()
+// markActivity отмечает действие пользователя для учёта бездействия.
+func (tp *TextProcessor) markActivity() {
+	tp.lastActivity = time.Now()
+}
+
+// updateInterface обновляет рабочую область на месте после изменения прав:
+// введённый текст и результат не теряются, при фильтрации при вводе текст
+// обрабатывается заново, а изменения прав показываются в уведомлении.
+func (tp *TextProcessor) updateInterface(previous *rightsSnapshot) {
+	if tp.rightsInfo == nil {
+		return
 	}
 
-	tp.autoRefresh = time.AfterFunc(2*time.Second, func() {
-		previousRights := make(map[rune]bool)
-		for k, v := range tp.accessRights {
-			previousRights[k] = v
-		}
+	tp.showRightsInfo()
+	if tp.live {
+		tp.liveFilter()
+	}
+	tp.notifyRightsChange(previous, tp.rights())
+}
 
-		tp.loadAccessRights()
+func (tp *TextProcessor) showRightsInfo() {
+	rights := tp.rights()
+	tp.rightsInfo.SetText(fmt.Sprintf("Разрешенные символы: %s", strings.Join(rights.accessList(), ", ")))
+	if len(rights.rules) == 0 {
+		tp.rulesInfo.SetText("Правила доступа: нет")
+	} else {
+		tp.rulesInfo.SetText(fmt.Sprintf("Правила доступа: %s", strings.Join(rights.ruleList(), "; ")))
+	}
+	tp.refreshStatus.SetText(fmt.Sprintf("Автообновление прав: активно (интервал %v), версия прав: %d", refreshInterval, rights.version))
+}
 
-		modified := len(tp.accessRights) != len(previousRights)
-		if !modified {
-			for char := range tp.accessRights {
-				if !previousRights[char] {
-					modified = true
-					break
-				}
-			}
-		}
+// notifyRightsChange показывает над рабочей областью, какие символы были
+// выданы или отозваны.
+func (tp *TextProcessor) notifyRightsChange(previous, current *rightsSnapshot) {
+	added, revoked := rightsChange(previous, current)
 
-		if modified {
-			log.Printf("Обновлены права доступа для %s: %v", tp.username, tp.getAccessList())
-			tp.updateInterface()
-		}
+	var parts []string
+	if len(added) > 0 {
+		parts = append(parts, fmt.Sprintf("выданы: %s", strings.Join(added, ", ")))
+	}
+	if len(revoked) > 0 {
+		parts = append(parts, fmt.Sprintf("отозваны: %s", strings.Join(revoked, ", ")))
+	}
+	if strings.Join(previous.ruleList(), "\n") != strings.Join(current.ruleList(), "\n") {
+		parts = append(parts, "изменены правила доступа")
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "изменены настройки доступа")
+	}
 
-		tp.initAutoRefresh()
-	})
+	tp.showNotice(fmt.Sprintf("Права обновлены (версия %d): %s", current.version, strings.Join(parts, "; ")))
 }
 
-func (tp *TextProcessor) updateInterface() {
-	if tp.mainWindow.Content() != nil {
-		tp.displayWorkArea()
+// showNotice показывает уведомление над рабочей областью; уведомление
+// скрывается через noticeDuration.
+func (tp *TextProcessor) showNotice(text string) {
+	tp.notice.SetText(text)
+	tp.notice.Show()
+
+	if tp.noticeTimer != nil {
+		tp.noticeTimer.Stop()
 	}
+	notice := tp.notice
+	tp.noticeTimer = time.AfterFunc(noticeDuration, func() {
+		fyne.Do(notice.Hide)
+	})
 }
 
 func (tp *TextProcessor) displayWorkArea() {
-	textInput := widget.NewMultiLineEntry()
+	textInput := newRestrictedEntry(func() *filter.Filter {
+		return tp.rights().filter
+	})
 	textInput.SetPlaceHolder("Введите ваш текст здесь для обработки...")
-	textInput.Wrapping = fyne.TextWrapWord
+	textInput.blocking = tp.blockTyping
+
+	// Разметка вводимого текста: запрещённые символы выделяются цветом
+	inputMarks := widget.NewRichText()
+	inputMarks.Wrapping = fyne.TextWrapWord
+	inputStatus := widget.NewLabel("")
+	if !tp.live {
+		inputMarks.Hide()
+		inputStatus.Hide()
+	}
 
-	resultsDisplay := widget.NewLabel("")
-	resultsDisplay.Wrapping = fyne.TextWrapWord
-	resultsDisplay.TextStyle = fyne.TextStyle{Bold: true}
+	textInput.onBlocked = func(cluster string) {
+		tp.rememberBlocked(cluster)
+		inputStatus.SetText(fmt.Sprintf("Символ '%s' запрещён и не был введён", cluster))
+		inputStatus.Show()
+	}
+
+	tp.liveFilter = func() {
+		text := textInput.Text
+		textFilter := tp.rights().filter
+		segments := textFilter.Segments(text)
+		inputMarks.Segments = highlightSegments(segments)
+		inputMarks.Refresh()
+
+		forbidden := 0
+		for _, segment := range segments {
+			if segment.Forbidden {
+				forbidden += grapheme.Count(segment.Text)
+			}
+		}
+		inputStatus.SetText(fmt.Sprintf("Запрещённых символов: %d", forbidden))
+
+		processedText, _, err := textFilter.Apply(text, tp.mode, tp.placeholder)
+		if err != nil {
+			processedText = ""
+		}
+		tp.showResult(processedText)
+	}
+
+	// Фильтрация при вводе запускается после паузы в наборе текста
+	textInput.OnChanged = func(string) {
+		tp.markActivity()
+		if !tp.live {
+			return
+		}
+		if tp.liveTimer != nil {
+			tp.liveTimer.Stop()
+		}
+		tp.liveTimer = time.AfterFunc(liveDelay, func() {
+			fyne.Do(tp.liveFilter)
+		})
+	}
+
+	liveCheck := widget.NewCheck("Фильтровать при вводе", func(checked bool) {
+		tp.live = checked
+		if checked {
+			inputMarks.Show()
+			inputStatus.Show()
+			tp.liveFilter()
+		} else {
+			inputMarks.Hide()
+			inputStatus.Hide()
+		}
+	})
+	liveCheck.SetChecked(tp.live)
+
+	blockCheck := widget.NewCheck("Не допускать ввод запрещённых символов", func(checked bool) {
+		tp.blockTyping = checked
+		textInput.blocking = checked
+	})
+	blockCheck.SetChecked(tp.blockTyping)
+
+	tp.output = widget.NewLabel("")
+	tp.output.Wrapping = fyne.TextWrapWord
+	tp.output.Selectable = true
+
+	tp.outputRich = widget.NewRichText()
+	tp.outputRich.Wrapping = fyne.TextWrapWord
+	tp.outputRich.Hide()
+
+	tp.progress = widget.NewProgressBar()
+	tp.progress.Hide()
+	tp.fileInfo = widget.NewLabel("")
+
+	placeholderEntry := widget.NewEntry()
+	placeholderEntry.SetText(tp.placeholder)
+	placeholderEntry.OnChanged = func(text string) {
+		tp.placeholder = text
+	}
+
+	modeSelect := widget.NewSelect(filterModeOptions(), nil)
+	modeSelect.OnChanged = func(selected string) {
+		tp.markActivity()
+		for _, mode := range filter.Modes {
+			if filterModeNames[mode] == selected {
+				tp.mode = mode
+			}
+		}
+		if tp.mode == filter.ModeMask {
+			placeholderEntry.Enable()
+		} else {
+			placeholderEntry.Disable()
+		}
+	}
+	modeSelect.SetSelected(filterModeNames[tp.mode])
 
 	processAction := widget.NewButton("Выполнить фильтрацию", func() {
+		tp.markActivity()
 		inputText := textInput.Text
 		if strings.TrimSpace(inputText) == "" {
 			dialog.ShowInformation("Информация", "Пожалуйста, введите текст для обработки", tp.mainWindow)
 			return
 		}
 
-		processedText := tp.applyFilter(inputText)
-		resultsDisplay.SetText(processedText)
+		processedText, _, err := tp.rights().filter.Apply(inputText, tp.mode, tp.placeholder)
+		if err != nil {
+			tp.showResult("")
+			dialog.ShowError(err, tp.mainWindow)
+			return
+		}
+		tp.showResult(processedText)
 	})
 	processAction.Importance = widget.HighImportance
 
 	resetAction := widget.NewButton("Сбросить все", func() {
 		textInput.SetText("")
-		resultsDisplay.SetText("")
+		tp.fileInfo.SetText("")
+		tp.showResult("")
+	})
+
+	openFile := widget.NewButton("Открыть файл...", tp.showOpenFileDialog)
+	saveFile := widget.NewButton("Сохранить результат...", tp.showSaveFileDialog)
+	copyResult := widget.NewButton("Копировать", func() {
+		fyne.CurrentApp().Clipboard().SetContent(tp.result)
 	})
 
 	reloadRights := widget.NewButton("Перезагрузить права", func() {
-		tp.loadAccessRights()
-		tp.updateInterface()
-		dialog.ShowInformation("Готово", "Права доступа успешно перезагружены", tp.mainWindow)
+		if previous := tp.loadAccessRights(); previous != nil {
+			tp.updateInterface(previous)
+			return
+		}
+		dialog.ShowInformation("Готово", "Права доступа не изменились", tp.mainWindow)
+	})
+
+	requestAccess := widget.NewButton("Запросить доступ...", func() {
+		tp.markActivity()
+		tp.showAccessRequestDialog(textInput.Text)
 	})
 
 	endSession := widget.NewButton("Завершить сеанс", func() {
-		if tp.autoRefresh != nil {
-			tp.autoRefresh.Stop()
-		}
-		tp.currentUser = 0
-		tp.username = ""
-		tp.accessRights = make(map[rune]bool)
-		tp.displayAuthScreen()
+		tp.endSession("")
 	})
 
-	userProfile := widget.NewLabel(fmt.Sprintf("Текущий пользователь: %s", tp.username))
+	tp.userProfile = widget.NewLabel(fmt.Sprintf("Текущий пользователь: %s", tp.username))
+
+	tp.rightsInfo = widget.NewLabel("")
+	tp.rightsInfo.Wrapping = fyne.TextWrapWord
+	tp.rulesInfo = widget.NewLabel("")
+	tp.refreshStatus = widget.NewLabel("")
+	tp.showRightsInfo()
 
-	rightsInfo := widget.NewLabel(fmt.Sprintf("Разрешенные символы: %s", strings.Join(tp.getAccessList(), ", ")))
+	tp.requestsInfo = widget.NewLabel("")
+	tp.requestsInfo.Wrapping = fyne.TextWrapWord
+	tp.loadAccessRequests()
 
-	autoRefreshStatus := widget.NewLabel("Автообновление прав: активно (интервал 2 сек)")
+	tp.notice = widget.NewLabel("")
+	tp.notice.Importance = widget.WarningImportance
+	tp.notice.Wrapping = fyne.TextWrapWord
+	tp.notice.Hide()
 
 	headerSection := container.NewVBox(
-		userProfile,
-		rightsInfo,
-		autoRefreshStatus,
+		tp.userProfile,
+		tp.rightsInfo,
+		tp.rulesInfo,
+		tp.refreshStatus,
+		tp.requestsInfo,
+		tp.notice,
 		widget.NewSeparator(),
 	)
 
 	inputSection := container.NewVBox(
 		widget.NewLabel("Исходный текст:"),
 		textInput,
+		container.NewHBox(liveCheck, blockCheck),
+		inputStatus,
+		inputMarks,
+	)
+
+	modePanel := container.NewHBox(
+		widget.NewLabel("Запрещённые символы:"),
+		modeSelect,
+		widget.NewLabel("Заполнитель:"),
+		placeholderEntry,
 	)
 
 	controlPanel := container.NewHBox(
 		processAction,
 		resetAction,
 		reloadRights,
+		requestAccess,
+	)
+
+	fileSection := container.NewVBox(
+		container.NewHBox(openFile, widget.NewLabel("или перетащите текстовый файл в окно")),
+		tp.progress,
+		tp.fileInfo,
 	)
 
 	outputSection := container.NewVBox(
-		widget.NewLabel("Обработанный текст:"),
-		resultsDisplay,
+		container.NewHBox(widget.NewLabel("Обработанный текст:"), copyResult, saveFile),
+		tp.output,
+		tp.outputRich,
 	)
 
 	footerSection := container.NewVBox(
@@ -245,36 +517,80 @@ func (tp *TextProcessor) displayWorkArea() {
 	mainContent := container.NewVBox(
 		headerSection,
 		inputSection,
+		fileSection,
+		modePanel,
 		controlPanel,
 		outputSection,
 		footerSection,
 	)
 
+	tp.showResult(tp.result)
+
 	scrollableContent := container.NewScroll(mainContent)
 	tp.mainWindow.SetContent(scrollableContent)
 }
 
-func (tp *TextProcessor) applyFilter(input string) string {
-	var filtered strings.Builder
+var filterModeNames = map[filter.Mode]string{
+	filter.ModeDelete:    "Удалять",
+	filter.ModeMask:      "Маскировать",
+	filter.ModeHighlight: "Подсвечивать",
+	filter.ModeReject:    "Отклонять текст",
+}
 
-	for _, char := range input {
-		if char == ' ' || char == '\n' || char == '\t' || char == '\r' {
-			filtered.WriteRune(char)
-			continue
-		}
+func filterModeOptions() []string {
+	options := make([]string, len(filter.Modes))
+	for i, mode := range filter.Modes {
+		options[i] = filterModeNames[mode]
+	}
+	return options
+}
 
-		if tp.accessRights[char] {
-			filtered.WriteRune(char)
-		}
+// showResult сохраняет результат фильтрации и показывает его начало. В
+// режиме подсветки результат совпадает с исходным текстом, а запрещённые
+// символы выделяются цветом.
+func (tp *TextProcessor) showResult(text string) {
+	tp.result = text
+
+	preview := text
+	if runes := []rune(text); len(runes) > previewLimit {
+		preview = string(runes[:previewLimit]) + "\n…"
 	}
 
-	return filtered.String()
+	if tp.mode == filter.ModeHighlight {
+		tp.outputRich.Segments = highlightSegments(tp.rights().filter.Segments(preview))
+		tp.outputRich.Refresh()
+		tp.outputRich.Show()
+		tp.output.Hide()
+		return
+	}
+	tp.output.SetText(preview)
+	tp.output.Show()
+	tp.outputRich.Hide()
+}
+
+// highlightSegments выделяет запрещённые участки текста цветом ошибки.
+func highlightSegments(segments []filter.Segment) []widget.RichTextSegment {
+	result := make([]widget.RichTextSegment, 0, len(segments))
+	for _, segment := range segments {
+		style := widget.RichTextStyleInline
+		if segment.Forbidden {
+			style.ColorName = theme.ColorNameError
+			style.TextStyle = fyne.TextStyle{Bold: true, Underline: true}
+		}
+		result = append(result, &widget.TextSegment{Text: segment.Text, Style: style})
+	}
+	return result
 }
 
 func (tp *TextProcessor) Shutdown() {
 	if tp.autoRefresh != nil {
 		tp.autoRefresh.Stop()
 	}
+	if tp.sessionID != 0 {
+		if err := database.EndSession(tp.db, tp.sessionID); err != nil {
+			log.Printf("Ошибка завершения сеанса: %v", err)
+		}
+	}
 }
 
 func main() {
@@ -290,4 +606,3 @@ func main() {
 
 	textProcessor.Launch()
 }
-
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"laba3/database"
+	"laba3/grapheme"
+	"log"
+	"strings"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// requestsShown - сколько последних запросов доступа показывается в
+// рабочей области.
+const requestsShown = 5
+
+const requestTimeLayout = "02.01.2006 15:04"
+
+// rememberBlocked запоминает символ, который пользователь пытался ввести
+// без права, чтобы подставить его в запрос доступа.
+func (tp *TextProcessor) rememberBlocked(cluster string) {
+	if strings.TrimSpace(cluster) == "" {
+		return
+	}
+	for _, blocked := range tp.blocked {
+		if blocked == cluster {
+			return
+		}
+	}
+	tp.blocked = append(tp.blocked, cluster)
+}
+
+// forbiddenClusters возвращает запрещённые символы текста и символы,
+// ввод которых был заблокирован, без повторов и пробельных символов.
+func (tp *TextProcessor) forbiddenClusters(text string) []string {
+	seen := make(map[string]bool)
+	var clusters []string
+	add := func(cluster string) {
+		if strings.TrimSpace(cluster) != "" && !seen[cluster] {
+			seen[cluster] = true
+			clusters = append(clusters, cluster)
+		}
+	}
+	for _, segment := range tp.rights().filter.Segments(text) {
+		if segment.Forbidden {
+			grapheme.Each(segment.Text, add)
+		}
+	}
+	for _, cluster := range tp.blocked {
+		add(cluster)
+	}
+	return clusters
+}
+
+// parseRequestedLetters разбирает список символов, разделённых пробелами
+// или запятыми; слитно записанные символы тоже разделяются.
+func parseRequestedLetters(input string) []string {
+	var letters []string
+	fields := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	for _, field := range fields {
+		letters = append(letters, grapheme.Split(field)...)
+	}
+	return letters
+}
+
+// showAccessRequestDialog предлагает запросить доступ к символам; список
+// заранее заполнен запрещёнными символами из поля ввода.
+func (tp *TextProcessor) showAccessRequestDialog(text string) {
+	lettersEntry := widget.NewEntry()
+	lettersEntry.SetText(strings.Join(tp.forbiddenClusters(text), " "))
+	lettersEntry.SetPlaceHolder("Символы через пробел")
+
+	justificationEntry := widget.NewMultiLineEntry()
+	justificationEntry.SetPlaceHolder("Зачем нужен доступ")
+	justificationEntry.SetMinRowsVisible(3)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Символы", lettersEntry),
+		widget.NewFormItem("Обоснование", justificationEntry),
+	}
+	form := dialog.NewForm("Запрос доступа", "Отправить", "Отмена", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		tp.markActivity()
+
+		letters := parseRequestedLetters(lettersEntry.Text)
+		if len(letters) == 0 {
+			dialog.ShowError(fmt.Errorf("укажите символы, к которым нужен доступ"), tp.mainWindow)
+			return
+		}
+
+		var sent []string
+		var errs []string
+		for _, letter := range letters {
+			if _, err := database.RequestAccess(tp.db, tp.currentUser, letter, justificationEntry.Text); err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			sent = append(sent, letter)
+		}
+		if len(sent) > 0 {
+			log.Printf("Пользователь %s запросил доступ к символам: %v", tp.username, sent)
+			tp.blocked = nil
+			tp.loadAccessRequests()
+		}
+
+		if len(errs) > 0 {
+			message := strings.Join(errs, "\n")
+			if len(sent) > 0 {
+				message = fmt.Sprintf("Отправлены запросы на: %s\n\n%s", strings.Join(sent, ", "), message)
+			}
+			dialog.ShowError(fmt.Errorf("%s", message), tp.mainWindow)
+			return
+		}
+		dialog.ShowInformation("Запрос отправлен",
+			fmt.Sprintf("Запрошен доступ к символам: %s. Решение администратора появится в разделе «Мои запросы доступа».", strings.Join(sent, ", ")),
+			tp.mainWindow)
+	}, tp.mainWindow)
+	form.Resize(fyne.NewSize(450, 300))
+	form.Show()
+}
+
+// loadAccessRequests перечитывает запросы пользователя из главного потока.
+func (tp *TextProcessor) loadAccessRequests() {
+	requests, err := database.GetUserAccessRequests(tp.db, tp.currentUser)
+	if err != nil {
+		log.Printf("Ошибка загрузки запросов доступа: %v", err)
+		return
+	}
+	tp.showAccessRequests(requests)
+}
+
+// requestStatusText описывает состояние запроса для пользователя.
+func requestStatusText(request database.AccessRequest) string {
+	switch request.Status {
+	case database.RequestApproved:
+		if request.ExpiresAt.IsZero() {
+			return "одобрен"
+		}
+		return "одобрен до " + request.ExpiresAt.Format(requestTimeLayout)
+	case database.RequestRejected:
+		if request.Comment != "" {
+			return "отклонён: " + request.Comment
+		}
+		return "отклонён"
+	}
+	return "ожидает рассмотрения"
+}
+
+// showAccessRequests показывает последние запросы и сообщает о решениях,
+// принятых с прошлой проверки.
+func (tp *TextProcessor) showAccessRequests(requests []database.AccessRequest) {
+	statuses := make(map[int]string, len(requests))
+	var decided []string
+	for _, request := range requests {
+		statuses[request.ID] = request.Status
+		if previous, ok := tp.requestStatuses[request.ID]; ok && previous != request.Status {
+			decided = append(decided, fmt.Sprintf("'%s' %s", request.Letter, requestStatusText(request)))
+		}
+	}
+	tp.requestStatuses = statuses
+
+	if tp.requestsInfo == nil {
+		return
+	}
+	if len(decided) > 0 {
+		tp.showNotice("Рассмотрены запросы доступа: " + strings.Join(decided, "; "))
+	}
+
+	if len(requests) == 0 {
+		tp.requestsInfo.SetText("Мои запросы доступа: нет")
+		return
+	}
+	lines := []string{"Мои запросы доступа:"}
+	for i, request := range requests {
+		if i == requestsShown {
+			lines = append(lines, fmt.Sprintf("  и ещё %d", len(requests)-requestsShown))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  '%s' от %s - %s", request.Letter,
+			request.RequestedAt.Format(requestTimeLayout), requestStatusText(request)))
+	}
+	tp.requestsInfo.SetText(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"laba3/database"
+	"laba3/filter"
+	"laba3/grapheme"
+	"laba3/rules"
+	"log"
+	"sort"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// refreshInterval - период проверки прав пользователя в базе данных.
+const refreshInterval = 2 * time.Second
+
+// rightsSnapshot - снимок прав пользователя. Снимок не изменяется после
+// создания: при обновлении прав строится новый и атомарно подменяет
+// текущий, поэтому фоновые горутины (обработка файлов, фильтрация при
+// вводе) читают права без блокировок. Версия увеличивается при каждом
+// изменении прав.
+type rightsSnapshot struct {
+	version    uint64
+	userID     int
+	letters    map[string]rules.CasePolicy
+	rules      []rules.Rule
+	whitespace rules.WhitespacePolicy
+	filter     *filter.Filter
+}
+
+// noRights - права до входа и после завершения сеанса: запрещено всё.
+func noRights() *rightsSnapshot {
+	return &rightsSnapshot{
+		letters: map[string]rules.CasePolicy{},
+		filter:  filter.New(rules.LetterSet{}, nil, rules.WhitespaceNone),
+	}
+}
+
+func loadRights(db *sql.DB, userID int) (*rightsSnapshot, error) {
+	rights, err := database.GetPermissions(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessRules, err := database.GetRules(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := database.GetCasePolicies(db)
+	if err != nil {
+		return nil, err
+	}
+
+	whitespace, err := database.GetWhitespacePolicy(db)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &rightsSnapshot{
+		userID:     userID,
+		letters:    make(map[string]rules.CasePolicy),
+		rules:      make([]rules.Rule, 0, len(accessRules)),
+		whitespace: whitespace,
+	}
+
+	var letterSet rules.LetterSet
+	for _, right := range rights {
+		if len(right) > 0 {
+			letter := grapheme.Normalize(right)
+			snapshot.letters[letter] = policies[right]
+			letterSet.Add(letter, policies[right])
+		}
+	}
+	for _, accessRule := range accessRules {
+		snapshot.rules = append(snapshot.rules, accessRule.Rule)
+	}
+
+	snapshot.filter = filter.New(letterSet, snapshot.rules, whitespace)
+	return snapshot, nil
+}
+
+// sameRights сообщает, совпадают ли права двух снимков.
+func (s *rightsSnapshot) sameRights(other *rightsSnapshot) bool {
+	if s.userID != other.userID || s.whitespace != other.whitespace ||
+		len(s.letters) != len(other.letters) || len(s.rules) != len(other.rules) {
+		return false
+	}
+	for letter, policy := range s.letters {
+		if otherPolicy, ok := other.letters[letter]; !ok || otherPolicy != policy {
+			return false
+		}
+	}
+	for i, rule := range s.rules {
+		if rule.String() != other.rules[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+// rightsChange возвращает символы, выданные и отозванные между снимками.
+// Смена политики регистра буквы считается отзывом и выдачей.
+func rightsChange(previous, current *rightsSnapshot) (added, revoked []string) {
+	for letter, policy := range current.letters {
+		if old, ok := previous.letters[letter]; !ok || old != policy {
+			added = append(added, letter)
+		}
+	}
+	for letter, policy := range previous.letters {
+		if now, ok := current.letters[letter]; !ok || now != policy {
+			revoked = append(revoked, letter)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(revoked)
+	return added, revoked
+}
+
+func (s *rightsSnapshot) accessList() []string {
+	allowed := make([]string, 0, len(s.letters))
+	for letter, policy := range s.letters {
+		switch policy {
+		case rules.CaseInsensitive:
+			letter += " (любой регистр)"
+		case rules.CaseUpper:
+			letter += " (только заглавная)"
+		}
+		allowed = append(allowed, letter)
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+func (s *rightsSnapshot) ruleList() []string {
+	list := make([]string, 0, len(s.rules))
+	for _, rule := range s.rules {
+		list = append(list, rule.String())
+	}
+	return list
+}
+
+// rights возвращает текущий снимок прав; безопасно из любой горутины.
+func (tp *TextProcessor) rights() *rightsSnapshot {
+	return tp.snapshot.Load()
+}
+
+// setRights публикует новый снимок, если права изменились, и сообщает об
+// этом. Снимки публикуются только из главного потока Fyne.
+func (tp *TextProcessor) setRights(next *rightsSnapshot) bool {
+	current := tp.rights()
+	if current.sameRights(next) {
+		return false
+	}
+	next.version = current.version + 1
+	tp.snapshot.Store(next)
+	return true
+}
+
+// loadAccessRights перечитывает права и возвращает предыдущий снимок,
+// если права изменились, иначе nil.
+func (tp *TextProcessor) loadAccessRights() *rightsSnapshot {
+	snapshot, err := loadRights(tp.db, tp.currentUser)
+	if err != nil {
+		log.Printf("Ошибка загрузки прав доступа: %v", err)
+		return nil
+	}
+
+	previous := tp.rights()
+	if !tp.setRights(snapshot) {
+		return nil
+	}
+	return previous
+}
+
+// sessionStatus - состояние сеанса и учётной записи, прочитанное из базы.
+type sessionStatus struct {
+	deleted     bool
+	name        string
+	revision    int
+	terminated  bool
+	idleTimeout time.Duration
+}
+
+// checkSession записывает время последней активности и читает состояние
+// сеанса: удалён или переименован ли пользователь, завершён ли сеанс
+// администратором и допустимое время бездействия. Вызывается из горутины
+// таймера.
+func (tp *TextProcessor) checkSession(userID, sessionID int, lastActivity time.Time) sessionStatus {
+	var status sessionStatus
+
+	name, revision, err := database.GetUserRevision(tp.db, userID)
+	switch {
+	case err == sql.ErrNoRows:
+		status.deleted = true
+		return status
+	case err != nil:
+		log.Printf("Ошибка проверки пользователя: %v", err)
+	default:
+		status.name, status.revision = name, revision
+	}
+
+	if err := database.TouchSession(tp.db, sessionID, lastActivity); err != nil {
+		log.Printf("Ошибка обновления сеанса: %v", err)
+	}
+
+	status.terminated, err = database.SessionTerminated(tp.db, sessionID)
+	if err != nil {
+		log.Printf("Ошибка проверки сеанса: %v", err)
+	}
+
+	status.idleTimeout, err = database.GetIdleTimeout(tp.db)
+	if err != nil {
+		log.Printf("Ошибка загрузки времени бездействия: %v", err)
+		status.idleTimeout = database.DefaultIdleTimeout
+	}
+	return status
+}
+
+// initAutoRefresh периодически перечитывает права. Чтение из базы идёт
+// в горутине таймера, а публикация снимка и обновление интерфейса -
+// в главном потоке Fyne.
+func (tp *TextProcessor) initAutoRefresh() {
+	if tp.autoRefresh != nil {
+		tp.autoRefresh.Stop()
+	}
+
+	userID, sessionID, lastActivity := tp.currentUser, tp.sessionID, tp.lastActivity
+	tp.autoRefresh = time.AfterFunc(refreshInterval, func() {
+		snapshot, err := loadRights(tp.db, userID)
+		status := tp.checkSession(userID, sessionID, lastActivity)
+		requests, requestsErr := database.GetUserAccessRequests(tp.db, userID)
+
+		fyne.Do(func() {
+			// Сеанс завершён, пока права читались из базы
+			if tp.currentUser != userID || tp.sessionID != sessionID {
+				return
+			}
+
+			if status.deleted {
+				tp.endSession("Ваша учётная запись удалена администратором")
+				return
+			}
+			if status.terminated {
+				tp.endSession("Сеанс завершён администратором")
+				return
+			}
+			if status.idleTimeout > 0 && time.Since(tp.lastActivity) > status.idleTimeout {
+				tp.endSession(fmt.Sprintf("Сеанс завершён после %v бездействия", status.idleTimeout))
+				return
+			}
+			if status.name != "" && status.revision != tp.userRevision {
+				tp.renameUser(status.name, status.revision)
+			}
+
+			previous := tp.rights()
+			if err != nil {
+				log.Printf("Ошибка загрузки прав доступа: %v", err)
+			} else if tp.setRights(snapshot) {
+				log.Printf("Обновлены права доступа для %s (версия %d): %v", tp.username, snapshot.version, snapshot.accessList())
+				tp.updateInterface(previous)
+			}
+			if requestsErr != nil {
+				log.Printf("Ошибка загрузки запросов доступа: %v", requestsErr)
+			} else {
+				tp.showAccessRequests(requests)
+			}
+
+			tp.initAutoRefresh()
+		})
+	})
+}
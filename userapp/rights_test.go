@@ -0,0 +1,91 @@
+package main
+
+import (
+	"laba3/filter"
+	"laba3/rules"
+	"sync"
+	"testing"
+)
+
+// testRights строит снимок прав на буквы letters.
+func testRights(letters ...string) *rightsSnapshot {
+	snapshot := &rightsSnapshot{
+		userID:     1,
+		letters:    make(map[string]rules.CasePolicy),
+		whitespace: rules.WhitespaceNone,
+	}
+	var letterSet rules.LetterSet
+	for _, letter := range letters {
+		snapshot.letters[letter] = rules.CaseExact
+		letterSet.Add(letter, rules.CaseExact)
+	}
+	snapshot.filter = filter.New(letterSet, nil, rules.WhitespaceNone)
+	return snapshot
+}
+
+// Права публикуются из одного потока, пока несколько горутин фильтруют
+// текст. Каждая фильтрация должна видеть один целый снимок. Тест
+// рассчитан на запуск с -race.
+func TestRightsConcurrentRefresh(t *testing.T) {
+	tp := &TextProcessor{}
+	tp.snapshot.Store(noRights())
+
+	const input = "абвабв"
+	valid := map[string]bool{
+		"":     true, // noRights
+		"аа":   true, // только а
+		"бвбв": true, // б и в
+	}
+
+	const readers = 8
+	stop := make(chan struct{})
+	errs := make(chan string, readers)
+	var wg sync.WaitGroup
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				rights := tp.rights()
+				got, stats, err := rights.filter.Apply(input, filter.ModeDelete, "")
+				if err != nil {
+					errs <- err.Error()
+					return
+				}
+				if !valid[got] || stats.Kept+stats.Dropped != 6 {
+					errs <- "результат " + got + " не соответствует ни одному снимку"
+					return
+				}
+			}
+		}()
+	}
+
+	var version uint64
+	for i := range 2000 {
+		next := testRights("а")
+		if i%2 == 1 {
+			next = testRights("б", "в")
+		}
+		if !tp.setRights(next) {
+			t.Errorf("шаг %d: права не изменились", i)
+			break
+		}
+		if v := tp.rights().version; v != version+1 {
+			t.Errorf("шаг %d: версия %d, want %d", i, v, version+1)
+			break
+		}
+		version++
+	}
+	close(stop)
+	wg.Wait()
+
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}